@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"teamacedia/minestalker/internal/config"
+	"teamacedia/minestalker/internal/db"
+	"teamacedia/minestalker/internal/importer"
+	"teamacedia/minestalker/internal/models"
+)
+
+// runDoctor checks the database for integrity problems and optionally repairs them.
+// Usage: minestalker doctor [-fix]
+func runDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	fix := fs.Bool("fix", false, "repair the problems found instead of only reporting them")
+	fs.Parse(args)
+
+	openDB()
+
+	report, err := db.RunDoctor(*fix)
+	if err != nil {
+		log.Fatalf("Doctor failed: %v", err)
+	}
+	fmt.Print(db.FormatDoctorReport(report))
+
+	// Let scripts tell a clean database from one that still needs attention
+	if report.HasIssues() && !*fix {
+		os.Exit(1)
+	}
+}
+
+// runImport loads historical sightings or snapshots exported by another tracker.
+// Usage: minestalker import -kind sightings|snapshots [-format csv|jsonl] [-dry-run] <file>
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	kind := fs.String("kind", "sightings", "what the file contains: sightings or snapshots")
+	format := fs.String("format", "", "csv or jsonl, guessed from the file extension when empty")
+	dryRun := fs.Bool("dry-run", false, "validate and summarise without writing anything")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		log.Fatalf("Usage: minestalker import -kind sightings|snapshots [-format csv|jsonl] [-dry-run] <file>")
+	}
+	path := fs.Arg(0)
+
+	if *format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			*format = "csv"
+		case ".jsonl", ".ndjson":
+			*format = "jsonl"
+		default:
+			log.Fatalf("Cannot guess the format of %s, pass -format", path)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var summary db.ImportSummary
+	var invalid []importer.RecordError
+
+	switch *kind {
+	case "sightings":
+		var sightings []models.ImportedSighting
+		sightings, invalid, err = importer.ReadSightings(file, *format)
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", path, err)
+		}
+		openDB()
+		summary, err = db.ImportSightings(sightings, *dryRun)
+
+	case "snapshots":
+		var snapshots []models.Snapshot
+		snapshots, invalid, err = importer.ReadSnapshots(file, *format)
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", path, err)
+		}
+		openDB()
+		summary, err = db.ImportSnapshots(snapshots, *dryRun)
+
+	default:
+		log.Fatalf("Unknown kind %q, expected sightings or snapshots", *kind)
+	}
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	for _, recErr := range invalid {
+		fmt.Printf("Skipped invalid record, %v\n", recErr)
+	}
+	fmt.Printf("Invalid records:          %d\n", len(invalid))
+	fmt.Print(db.FormatImportSummary(summary))
+}
+
+// openDB initializes the database for a subcommand. config.ini is optional here
+// so maintenance commands still work on a machine that only has the database.
+func openDB() {
+	busyTimeout := 5000
+	if cfg, err := config.LoadConfig("config.ini"); err == nil {
+		busyTimeout = cfg.BusyTimeout
+	}
+
+	if err := db.InitDB(dbPath, busyTimeout); err != nil {
+		log.Fatalf("Failed to initialize DB: %v", err)
+	}
+}
+
+// runAPIKey manages keys of the HTTP API.
+// Usage: minestalker apikey issue -name <name> [-scopes read:public,...] [-rate <requests per minute>]
+//
+//	minestalker apikey revoke <id>
+//	minestalker apikey list
+func runAPIKey(args []string) {
+	if len(args) == 0 {
+		log.Fatalf("Usage: minestalker apikey issue|revoke|list")
+	}
+
+	switch args[0] {
+	case "issue":
+		fs := flag.NewFlagSet("apikey issue", flag.ExitOnError)
+		name := fs.String("name", "", "who or what the key is for")
+		scopes := fs.String("scopes", db.ScopeReadPublic, "comma separated scopes: "+strings.Join(db.Scopes, ", "))
+		rate := fs.Int("rate", 0, "requests per minute, 0 for APIKeyRateLimit from config.ini")
+		fs.Parse(args[1:])
+		if *name == "" {
+			log.Fatalf("Usage: minestalker apikey issue -name <name> [-scopes read:public,...] [-rate <requests per minute>]")
+		}
+
+		openDB()
+		key, plain, err := db.IssueAPIKey(*name, strings.Split(*scopes, ","), *rate)
+		if err != nil {
+			log.Fatalf("Failed to issue API key: %v", err)
+		}
+		fmt.Printf("Issued API key %d for %s with scopes %s\n", key.ID, key.Name, strings.Join(key.Scopes, ", "))
+		fmt.Printf("%s\n", plain)
+		fmt.Println("Store it now, it cannot be shown again.")
+
+	case "revoke":
+		if len(args) != 2 {
+			log.Fatalf("Usage: minestalker apikey revoke <id>")
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			log.Fatalf("Invalid API key ID %q", args[1])
+		}
+
+		openDB()
+		if err := db.RevokeAPIKey(id); err != nil {
+			log.Fatalf("Failed to revoke API key: %v", err)
+		}
+		fmt.Printf("Revoked API key %d\n", id)
+
+	case "list":
+		openDB()
+		keys, err := db.GetAPIKeys()
+		if err != nil {
+			log.Fatalf("Failed to list API keys: %v", err)
+		}
+		for _, key := range keys {
+			rate, status := "default", "active"
+			if key.RateLimit > 0 {
+				rate = fmt.Sprintf("%d/min", key.RateLimit)
+			}
+			if key.RevokedAt != nil {
+				status = "revoked " + key.RevokedAt.Format(time.DateTime)
+			}
+			fmt.Printf("%-4d %-16s %-30s %-32s %-10s %s\n", key.ID, key.Prefix+"…", key.Name,
+				strings.Join(key.Scopes, ","), rate, status)
+		}
+
+	default:
+		log.Fatalf("Unknown apikey command %q, expected issue, revoke or list", args[0])
+	}
+}
@@ -0,0 +1,46 @@
+package analytics
+
+import (
+	"sort"
+	"strings"
+	"teamacedia/minestalker/internal/models"
+)
+
+// GameTotal is the number of servers and players of one game.
+type GameTotal struct {
+	Game    string
+	Servers int
+	Players int
+}
+
+// GameTotals groups servers by lowercased game, most players first. Servers
+// that do not report a game are grouped under the empty name.
+func GameTotals(servers []models.ServerStatus) []GameTotal {
+	byGame := map[string]*GameTotal{}
+	for _, server := range servers {
+		game := strings.ToLower(server.Game)
+		total := byGame[game]
+		if total == nil {
+			total = &GameTotal{Game: game}
+			byGame[game] = total
+		}
+		total.Servers++
+		total.Players += server.Players
+	}
+
+	totals := make([]GameTotal, 0, len(byGame))
+	for _, total := range byGame {
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		a, b := totals[i], totals[j]
+		if a.Players != b.Players {
+			return a.Players > b.Players
+		}
+		if a.Servers != b.Servers {
+			return a.Servers > b.Servers
+		}
+		return a.Game < b.Game
+	})
+	return totals
+}
@@ -0,0 +1,104 @@
+package analytics
+
+import (
+	"sort"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// The helpers below treat a []models.Interval as a set of instants. Inputs
+// may overlap or be unordered; outputs are sorted, disjoint and non-empty.
+
+// normalize sorts intervals and merges the ones that overlap or touch.
+func normalize(intervals []models.Interval) []models.Interval {
+	sorted := make([]models.Interval, 0, len(intervals))
+	for _, iv := range intervals {
+		if iv.End.After(iv.Start) {
+			sorted = append(sorted, iv)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	var merged []models.Interval
+	for _, iv := range sorted {
+		if n := len(merged); n > 0 && !iv.Start.After(merged[n-1].End) {
+			if iv.End.After(merged[n-1].End) {
+				merged[n-1].End = iv.End
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// clip restricts intervals to [from, to].
+func clip(intervals []models.Interval, from, to time.Time) []models.Interval {
+	return intersect(intervals, []models.Interval{{Start: from, End: to}})
+}
+
+// intersect returns the instants covered by both a and b.
+func intersect(a, b []models.Interval) []models.Interval {
+	a, b = normalize(a), normalize(b)
+
+	var out []models.Interval
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		start, end := later(a[i].Start, b[j].Start), earlier(a[i].End, b[j].End)
+		if end.After(start) {
+			out = append(out, models.Interval{Start: start, End: end})
+		}
+		if a[i].End.Before(b[j].End) {
+			i++
+		} else {
+			j++
+		}
+	}
+	return out
+}
+
+// subtract returns the instants of a not covered by b.
+func subtract(a, b []models.Interval) []models.Interval {
+	a, b = normalize(a), normalize(b)
+
+	var out []models.Interval
+	j := 0
+	for _, iv := range a {
+		start := iv.Start
+		for j < len(b) && !b[j].End.After(start) {
+			j++
+		}
+		for k := j; k < len(b) && b[k].Start.Before(iv.End); k++ {
+			if b[k].Start.After(start) {
+				out = append(out, models.Interval{Start: start, End: b[k].Start})
+			}
+			start = later(start, b[k].End)
+		}
+		if iv.End.After(start) {
+			out = append(out, models.Interval{Start: start, End: iv.End})
+		}
+	}
+	return out
+}
+
+// total sums the length of disjoint intervals.
+func total(intervals []models.Interval) time.Duration {
+	var d time.Duration
+	for _, iv := range intervals {
+		d += iv.End.Sub(iv.Start)
+	}
+	return d
+}
+
+func earlier(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,87 @@
+package analytics
+
+import (
+	"slices"
+	"teamacedia/minestalker/internal/models"
+	"testing"
+	"time"
+)
+
+// day is the origin of the test times, which are given in hours after it.
+var day = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func at(hours float64) time.Time {
+	return day.Add(time.Duration(hours * float64(time.Hour)))
+}
+
+func span(from, to float64) models.Interval {
+	return models.Interval{Start: at(from), End: at(to)}
+}
+
+func hours(h float64) time.Duration {
+	return time.Duration(h * float64(time.Hour))
+}
+
+func sameIntervals(a, b []models.Interval) bool {
+	return slices.EqualFunc(a, b, func(x, y models.Interval) bool {
+		return x.Start.Equal(y.Start) && x.End.Equal(y.End)
+	})
+}
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		name string
+		in   []models.Interval
+		want []models.Interval
+	}{
+		{"empty", nil, nil},
+		{"unordered", []models.Interval{span(3, 4), span(1, 2)}, []models.Interval{span(1, 2), span(3, 4)}},
+		{"overlapping", []models.Interval{span(1, 3), span(2, 4)}, []models.Interval{span(1, 4)}},
+		{"touching", []models.Interval{span(1, 2), span(2, 3)}, []models.Interval{span(1, 3)}},
+		{"contained", []models.Interval{span(1, 5), span(2, 3)}, []models.Interval{span(1, 5)}},
+		{"empty and reversed dropped", []models.Interval{span(1, 1), span(3, 2), span(4, 5)}, []models.Interval{span(4, 5)}},
+	}
+
+	for _, c := range cases {
+		if got := normalize(c.in); !sameIntervals(got, c.want) {
+			t.Errorf("%s: normalize = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestIntersectSubtract(t *testing.T) {
+	cases := []struct {
+		name          string
+		a, b          []models.Interval
+		intersect     []models.Interval
+		subtract      []models.Interval
+		intersectTime time.Duration
+	}{
+		{"disjoint", []models.Interval{span(1, 2)}, []models.Interval{span(3, 4)},
+			nil, []models.Interval{span(1, 2)}, 0},
+		{"touching", []models.Interval{span(1, 2)}, []models.Interval{span(2, 3)},
+			nil, []models.Interval{span(1, 2)}, 0},
+		{"overlapping", []models.Interval{span(1, 3)}, []models.Interval{span(2, 4)},
+			[]models.Interval{span(2, 3)}, []models.Interval{span(1, 2)}, hours(1)},
+		{"hole in the middle", []models.Interval{span(1, 5)}, []models.Interval{span(2, 3)},
+			[]models.Interval{span(2, 3)}, []models.Interval{span(1, 2), span(3, 5)}, hours(1)},
+		{"covered", []models.Interval{span(2, 3)}, []models.Interval{span(1, 5)},
+			[]models.Interval{span(2, 3)}, nil, hours(1)},
+		{"several on both sides", []models.Interval{span(0, 2), span(4, 8)}, []models.Interval{span(1, 5), span(6, 7)},
+			[]models.Interval{span(1, 2), span(4, 5), span(6, 7)}, []models.Interval{span(0, 1), span(5, 6), span(7, 8)}, hours(3)},
+		{"unnormalized input", []models.Interval{span(3, 6), span(1, 4)}, []models.Interval{span(2, 3), span(2, 3)},
+			[]models.Interval{span(2, 3)}, []models.Interval{span(1, 2), span(3, 6)}, hours(1)},
+	}
+
+	for _, c := range cases {
+		if got := intersect(c.a, c.b); !sameIntervals(got, c.intersect) {
+			t.Errorf("%s: intersect = %v, want %v", c.name, got, c.intersect)
+		}
+		if got := total(intersect(c.a, c.b)); got != c.intersectTime {
+			t.Errorf("%s: total of intersect = %v, want %v", c.name, got, c.intersectTime)
+		}
+		if got := subtract(c.a, c.b); !sameIntervals(got, c.subtract) {
+			t.Errorf("%s: subtract = %v, want %v", c.name, got, c.subtract)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package analytics
+
+import (
+	"sort"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// favouriteServerCount is how many of the most played servers are favourites.
+const favouriteServerCount = 3
+
+// PlayerStats summarises a player's sessions.
+type PlayerStats struct {
+	TotalPlaytime  time.Duration
+	Sessions       int
+	ActiveDays     int     // Distinct local days with a session
+	SessionsPerDay float64 // Sessions over the days from the first to the last session
+	AvgSession     time.Duration
+	FirstSeen      *time.Time
+	LastSeen       *time.Time
+	Servers        []ServerPlaytime // Most played first
+	Favourites     []ServerPlaytime
+	// Heatmap is the playtime per local weekday (Sunday first) and hour.
+	Heatmap [7][24]time.Duration
+}
+
+// ServerPlaytime is the time a player spent on one server.
+type ServerPlaytime struct {
+	Address  string
+	Port     int
+	Name     string
+	Game     string
+	Playtime time.Duration
+	Sessions int
+	LastSeen time.Time
+}
+
+// PlayerActivity computes PlayerStats from a player's sightings, with days and
+// hours taken in loc. Open sightings last until now. When from and to are not
+// zero, sessions are clipped to [from, to] and ones outside it ignored.
+func PlayerActivity(sightings []models.PlayerSighting, now time.Time, loc *time.Location, from, to time.Time) PlayerStats {
+	var stats PlayerStats
+	type serverKey struct {
+		address string
+		port    int
+	}
+	servers := map[serverKey]*ServerPlaytime{}
+	days := map[string]bool{}
+
+	for _, sighting := range sightings {
+		start, end := sighting.ConnectedAt, now
+		if sighting.DisconnectedAt != nil {
+			end = *sighting.DisconnectedAt
+		}
+		if !from.IsZero() {
+			start, end = later(start, from), earlier(end, to)
+		}
+		if end.Before(start) {
+			continue
+		}
+
+		key := serverKey{sighting.Address, sighting.Port}
+		server := servers[key]
+		if server == nil {
+			server = &ServerPlaytime{Address: sighting.Address, Port: sighting.Port, Name: sighting.ServerName, Game: sighting.Game}
+			servers[key] = server
+		}
+		server.Playtime += end.Sub(start)
+		server.Sessions++
+		if end.After(server.LastSeen) {
+			server.LastSeen = end
+		}
+
+		stats.TotalPlaytime += end.Sub(start)
+		stats.Sessions++
+		days[start.In(loc).Format(time.DateOnly)] = true
+		if stats.FirstSeen == nil || start.Before(*stats.FirstSeen) {
+			stats.FirstSeen = &start
+		}
+		if stats.LastSeen == nil || end.After(*stats.LastSeen) {
+			stats.LastSeen = &end
+		}
+
+		addToHeatmap(&stats.Heatmap, start, end, loc)
+	}
+
+	if stats.Sessions == 0 {
+		return stats
+	}
+
+	stats.ActiveDays = len(days)
+	stats.AvgSession = stats.TotalPlaytime / time.Duration(stats.Sessions)
+	stats.SessionsPerDay = float64(stats.Sessions) / float64(daysBetween(*stats.FirstSeen, *stats.LastSeen, loc))
+
+	for _, server := range servers {
+		stats.Servers = append(stats.Servers, *server)
+	}
+	sort.Slice(stats.Servers, func(i, j int) bool {
+		if stats.Servers[i].Playtime != stats.Servers[j].Playtime {
+			return stats.Servers[i].Playtime > stats.Servers[j].Playtime
+		}
+		return stats.Servers[i].Address < stats.Servers[j].Address
+	})
+	stats.Favourites = stats.Servers[:min(favouriteServerCount, len(stats.Servers))]
+
+	return stats
+}
+
+// addToHeatmap spreads [start, end] over the local weekday and hour slots it
+// touches. Slots are split on local hour boundaries, so zones with a
+// non-whole-hour offset and DST changes land in the right slot.
+func addToHeatmap(heatmap *[7][24]time.Duration, start, end time.Time, loc *time.Location) {
+	for t := start; t.Before(end); {
+		local := t.In(loc)
+		next := time.Date(local.Year(), local.Month(), local.Day(), local.Hour()+1, 0, 0, 0, loc)
+		if !next.After(t) {
+			// The wall clock repeats an hour when DST ends
+			next = t.Truncate(time.Hour).Add(time.Hour)
+		}
+		next = earlier(next, end)
+		heatmap[local.Weekday()][local.Hour()] += next.Sub(t)
+		t = next
+	}
+}
+
+// daysBetween counts the local calendar days from first to last, inclusive.
+func daysBetween(first, last time.Time, loc *time.Location) int {
+	a, b := first.In(loc), last.In(loc)
+	startDay := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	endDay := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(endDay.Sub(startDay)/(24*time.Hour)) + 1
+}
@@ -0,0 +1,113 @@
+package analytics
+
+import (
+	"teamacedia/minestalker/internal/models"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// slot is a weekday and local hour of the heatmap.
+type slot struct {
+	day  time.Weekday
+	hour int
+}
+
+func TestHeatmapTimeZones(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2025, month, day, hour, min, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		name       string
+		loc        *time.Location
+		start, end time.Time
+		want       map[slot]time.Duration
+	}{
+		{"UTC", time.UTC, utc(1, 1, 10, 30), utc(1, 1, 12, 15), map[slot]time.Duration{
+			{time.Wednesday, 10}: 30 * time.Minute,
+			{time.Wednesday, 11}: time.Hour,
+			{time.Wednesday, 12}: 15 * time.Minute,
+		}},
+		{"half hour offset", time.FixedZone("IST", 5*3600+1800), utc(1, 1, 10, 0), utc(1, 1, 11, 0), map[slot]time.Duration{
+			{time.Wednesday, 15}: 30 * time.Minute,
+			{time.Wednesday, 16}: 30 * time.Minute,
+		}},
+		{"across local midnight", newYork, utc(1, 1, 4, 30), utc(1, 1, 5, 30), map[slot]time.Duration{
+			{time.Tuesday, 23}:  30 * time.Minute,
+			{time.Wednesday, 0}: 30 * time.Minute,
+		}},
+		// 02:00 is skipped, the clock jumps to 03:00
+		{"DST starts", berlin, utc(3, 30, 0, 30), utc(3, 30, 1, 30), map[slot]time.Duration{
+			{time.Sunday, 1}: 30 * time.Minute,
+			{time.Sunday, 3}: 30 * time.Minute,
+		}},
+		// 02:00 to 03:00 happens twice
+		{"DST ends", berlin, utc(10, 26, 0, 0), utc(10, 26, 1, 30), map[slot]time.Duration{
+			{time.Sunday, 2}: 90 * time.Minute,
+		}},
+	}
+
+	for _, c := range cases {
+		var heatmap [7][24]time.Duration
+		addToHeatmap(&heatmap, c.start, c.end, c.loc)
+
+		var sum time.Duration
+		for day := range heatmap {
+			for hour, d := range heatmap[day] {
+				sum += d
+				if want := c.want[slot{time.Weekday(day), hour}]; d != want {
+					t.Errorf("%s: %v %02d:00 has %v, want %v", c.name, time.Weekday(day), hour, d, want)
+				}
+			}
+		}
+		if sum != c.end.Sub(c.start) {
+			t.Errorf("%s: heatmap holds %v of a %v session", c.name, sum, c.end.Sub(c.start))
+		}
+	}
+}
+
+func TestPlayerActivityDays(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := func(start time.Time, d time.Duration) models.PlayerSighting {
+		end := start.Add(d)
+		return models.PlayerSighting{Address: "a", Port: 1, ConnectedAt: start, DisconnectedAt: &end}
+	}
+	// Two sessions either side of midnight UTC, the same evening in New York
+	sightings := []models.PlayerSighting{
+		closed(time.Date(2025, 1, 1, 23, 30, 0, 0, time.UTC), 15*time.Minute),
+		closed(time.Date(2025, 1, 2, 0, 30, 0, 0, time.UTC), 15*time.Minute),
+	}
+	now := time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		loc            *time.Location
+		activeDays     int
+		sessionsPerDay float64
+	}{
+		{time.UTC, 2, 1},
+		{newYork, 1, 2},
+	}
+
+	for _, c := range cases {
+		stats := PlayerActivity(sightings, now, c.loc, time.Time{}, time.Time{})
+		if stats.ActiveDays != c.activeDays || stats.SessionsPerDay != c.sessionsPerDay {
+			t.Errorf("%v: %d active days and %v sessions per day, want %d and %v",
+				c.loc, stats.ActiveDays, stats.SessionsPerDay, c.activeDays, c.sessionsPerDay)
+		}
+		if stats.TotalPlaytime != 30*time.Minute || stats.AvgSession != 15*time.Minute {
+			t.Errorf("%v: playtime %v, average %v", c.loc, stats.TotalPlaytime, stats.AvgSession)
+		}
+	}
+}
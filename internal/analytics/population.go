@@ -0,0 +1,80 @@
+package analytics
+
+import (
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// PopulationBucket summarises a server's player count over one bucket.
+type PopulationBucket struct {
+	Start time.Time
+	End   time.Time
+	// Samples is the number of snapshots in the bucket. Min, Max and Avg are
+	// only meaningful when it is not zero.
+	Samples       int
+	Min           int
+	Max           int
+	Avg           float64
+	UniquePlayers int // Distinct players seen at any point during the bucket
+}
+
+// Population splits [from, to) into buckets of the given resolution, aligned
+// to multiples of it since the zero time so buckets line up across requests,
+// and summarises the snapshot player counts and player sightings in each.
+// Open sightings are taken to last until to.
+func Population(counts []models.PlayerCount, sightings []models.PlayerSighting, from, to time.Time, resolution time.Duration) []PopulationBucket {
+	var buckets []PopulationBucket
+	for start := from.Truncate(resolution); start.Before(to); start = start.Add(resolution) {
+		buckets = append(buckets, PopulationBucket{Start: start, End: start.Add(resolution)})
+	}
+	if len(buckets) == 0 {
+		return buckets
+	}
+	first := buckets[0].Start
+
+	index := func(t time.Time) int {
+		return int(t.Sub(first) / resolution)
+	}
+
+	sums := make([]int, len(buckets))
+	for _, count := range counts {
+		i := index(count.Time)
+		if count.Time.Before(first) || i >= len(buckets) {
+			continue
+		}
+		b := &buckets[i]
+		if b.Samples == 0 || count.Players < b.Min {
+			b.Min = count.Players
+		}
+		b.Max = max(b.Max, count.Players)
+		b.Samples++
+		sums[i] += count.Players
+	}
+
+	players := make([]map[string]bool, len(buckets))
+	for _, sighting := range sightings {
+		end := to
+		if sighting.DisconnectedAt != nil {
+			end = *sighting.DisconnectedAt
+		}
+		// A sighting ending exactly on a bucket boundary does not reach into it
+		last := index(end)
+		if end.Equal(first.Add(time.Duration(last)*resolution)) && last > 0 {
+			last--
+		}
+		for i := max(index(later(sighting.ConnectedAt, first)), 0); i <= last && i < len(buckets); i++ {
+			if players[i] == nil {
+				players[i] = map[string]bool{}
+			}
+			players[i][sighting.Player] = true
+		}
+	}
+
+	for i := range buckets {
+		if buckets[i].Samples > 0 {
+			buckets[i].Avg = float64(sums[i]) / float64(buckets[i].Samples)
+		}
+		buckets[i].UniquePlayers = len(players[i])
+	}
+	return buckets
+}
@@ -0,0 +1,84 @@
+package analytics
+
+import (
+	"teamacedia/minestalker/internal/models"
+	"testing"
+	"time"
+)
+
+func TestPopulation(t *testing.T) {
+	sighting := func(player string, from float64, to *float64) models.PlayerSighting {
+		s := models.PlayerSighting{Player: player, ConnectedAt: at(from)}
+		if to != nil {
+			end := at(*to)
+			s.DisconnectedAt = &end
+		}
+		return s
+	}
+	closedAt := func(h float64) *float64 { return &h }
+
+	counts := []models.PlayerCount{
+		{Time: at(-1), Players: 9}, // Before the first bucket
+		{Time: at(0.25), Players: 2},
+		{Time: at(0.75), Players: 4},
+		{Time: at(1.5), Players: 3},
+		{Time: at(3.5), Players: 9}, // After the last bucket
+	}
+	sightings := []models.PlayerSighting{
+		sighting("alice", 0.5, closedAt(1)), // Ends on a boundary
+		sighting("carol", -2, closedAt(0.2)),
+		sighting("bob", 1.5, nil), // Open until to
+		sighting("alice", 2.1, closedAt(2.2)),
+		sighting("dave", -5, closedAt(-4)), // Before the first bucket
+	}
+
+	// from is not on a boundary, the first bucket still starts on one
+	got := Population(counts, sightings, at(0.5), at(3), time.Hour)
+	want := []PopulationBucket{
+		{Start: at(0), End: at(1), Samples: 2, Min: 2, Max: 4, Avg: 3, UniquePlayers: 2},
+		{Start: at(1), End: at(2), Samples: 1, Min: 3, Max: 3, Avg: 3, UniquePlayers: 1},
+		{Start: at(2), End: at(3), UniquePlayers: 2},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("%d buckets, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if !g.Start.Equal(w.Start) || !g.End.Equal(w.End) {
+			t.Errorf("bucket %d spans %v to %v, want %v to %v", i, g.Start, g.End, w.Start, w.End)
+		}
+		if g.Samples != w.Samples || g.Min != w.Min || g.Max != w.Max || g.Avg != w.Avg || g.UniquePlayers != w.UniquePlayers {
+			t.Errorf("bucket %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestPopulationBuckets(t *testing.T) {
+	cases := []struct {
+		name       string
+		from, to   float64
+		resolution time.Duration
+		starts     []float64
+	}{
+		{"aligned", 0, 3, time.Hour, []float64{0, 1, 2}},
+		{"partial last bucket", 0, 2.5, time.Hour, []float64{0, 1, 2}},
+		{"unaligned from", 1.5, 3, time.Hour, []float64{1, 2}},
+		{"coarser than the window", 1, 2, 6 * time.Hour, []float64{0}},
+		{"empty window", 2, 2, time.Hour, nil},
+		{"reversed window", 3, 2, time.Hour, nil},
+	}
+
+	for _, c := range cases {
+		got := Population(nil, nil, at(c.from), at(c.to), c.resolution)
+		if len(got) != len(c.starts) {
+			t.Errorf("%s: %d buckets, want %d", c.name, len(got), len(c.starts))
+			continue
+		}
+		for i, start := range c.starts {
+			if !got[i].Start.Equal(at(start)) || got[i].End.Sub(got[i].Start) != c.resolution {
+				t.Errorf("%s: bucket %d spans %v to %v, want a %v bucket from %v", c.name, i, got[i].Start, got[i].End, c.resolution, at(start))
+			}
+		}
+	}
+}
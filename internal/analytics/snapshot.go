@@ -0,0 +1,91 @@
+package analytics
+
+import (
+	"sort"
+	"teamacedia/minestalker/internal/models"
+)
+
+// SnapshotDiff is what changed on the server list between two snapshots.
+type SnapshotDiff struct {
+	Appeared    []models.Server // Listed in the later snapshot only
+	Disappeared []models.Server // Listed in the earlier snapshot only
+	Changed     []ServerChange  // Listed in both, with players joining or leaving
+}
+
+// ServerChange is the players who joined and left one server.
+type ServerChange struct {
+	Address string
+	Port    int
+	Name    string
+	Joined  []string
+	Left    []string
+}
+
+// DiffSnapshots compares the server lists of two snapshots. Servers are
+// matched on address and port; results are ordered by address and port.
+func DiffSnapshots(from, to models.Snapshot) SnapshotDiff {
+	type serverKey struct {
+		address string
+		port    int
+	}
+	before := map[serverKey]models.Server{}
+	for _, server := range from.Servers {
+		before[serverKey{server.Address, server.Port}] = server
+	}
+
+	var diff SnapshotDiff
+	for _, server := range to.Servers {
+		key := serverKey{server.Address, server.Port}
+		previous, ok := before[key]
+		if !ok {
+			diff.Appeared = append(diff.Appeared, server)
+			continue
+		}
+		delete(before, key)
+
+		joined, left := difference(server.PlayerList, previous.PlayerList), difference(previous.PlayerList, server.PlayerList)
+		if len(joined) > 0 || len(left) > 0 {
+			diff.Changed = append(diff.Changed, ServerChange{
+				Address: server.Address,
+				Port:    server.Port,
+				Name:    server.Name,
+				Joined:  joined,
+				Left:    left,
+			})
+		}
+	}
+	for _, server := range before {
+		diff.Disappeared = append(diff.Disappeared, server)
+	}
+
+	sortServers(diff.Appeared)
+	sortServers(diff.Disappeared)
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		a, b := diff.Changed[i], diff.Changed[j]
+		return a.Address < b.Address || (a.Address == b.Address && a.Port < b.Port)
+	})
+	return diff
+}
+
+// difference returns the names in a but not in b, sorted.
+func difference(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, name := range b {
+		in[name] = true
+	}
+	var out []string
+	for _, name := range a {
+		if !in[name] {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func sortServers(servers []models.Server) {
+	sort.Slice(servers, func(i, j int) bool {
+		a, b := servers[i], servers[j]
+		return a.Address < b.Address || (a.Address == b.Address && a.Port < b.Port)
+	})
+}
@@ -0,0 +1,64 @@
+package analytics
+
+import (
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// UptimeReport describes a server's availability over one window.
+type UptimeReport struct {
+	From    time.Time
+	To      time.Time
+	Online  time.Duration
+	Offline time.Duration
+	Unknown time.Duration // The scraper was not running, so the state is unknown
+	// Availability is Online over the time the state is known, in percent. It
+	// is nil when the scraper never ran during the window.
+	Availability *float64
+	Outages      []models.Interval
+	// MeanTimeBetweenOutages is the online time per outage, nil without outages.
+	MeanTimeBetweenOutages *time.Duration
+	LongestOutage          time.Duration
+}
+
+// Uptime computes availability over [from, to] from a server's sightings and
+// the scraper's coverage. Open sightings count as online until to. Time
+// outside the coverage is unknown rather than offline, whatever the sightings
+// say, and so never counts towards an outage.
+func Uptime(sightings []models.ServerSighting, coverage []models.Interval, from, to time.Time) UptimeReport {
+	online := make([]models.Interval, 0, len(sightings))
+	for _, s := range sightings {
+		end := to
+		if s.DisconnectedAt != nil {
+			end = *s.DisconnectedAt
+		}
+		online = append(online, models.Interval{Start: s.SeenAt, End: end})
+	}
+
+	known := clip(coverage, from, to)
+	online = intersect(online, known)
+	outages := subtract(known, online)
+
+	report := UptimeReport{
+		From:    from,
+		To:      to,
+		Online:  total(online),
+		Offline: total(outages),
+		Outages: outages,
+	}
+	report.Unknown = to.Sub(from) - report.Online - report.Offline
+
+	if known := report.Online + report.Offline; known > 0 {
+		availability := 100 * float64(report.Online) / float64(known)
+		report.Availability = &availability
+	}
+	if len(outages) > 0 {
+		mtbo := report.Online / time.Duration(len(outages))
+		report.MeanTimeBetweenOutages = &mtbo
+	}
+	for _, outage := range outages {
+		report.LongestOutage = max(report.LongestOutage, outage.End.Sub(outage.Start))
+	}
+
+	return report
+}
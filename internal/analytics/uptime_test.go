@@ -0,0 +1,60 @@
+package analytics
+
+import (
+	"teamacedia/minestalker/internal/models"
+	"testing"
+	"time"
+)
+
+func TestUptime(t *testing.T) {
+	sighting := func(from float64, to *float64) models.ServerSighting {
+		s := models.ServerSighting{SeenAt: at(from)}
+		if to != nil {
+			end := at(*to)
+			s.DisconnectedAt = &end
+		}
+		return s
+	}
+	closedAt := func(h float64) *float64 { return &h }
+	percent := func(p float64) *float64 { return &p }
+
+	cases := []struct {
+		name         string
+		sightings    []models.ServerSighting
+		coverage     []models.Interval
+		online       time.Duration
+		offline      time.Duration
+		unknown      time.Duration
+		availability *float64
+		outages      []models.Interval
+	}{
+		{"online throughout", []models.ServerSighting{sighting(0, nil)}, []models.Interval{span(0, 10)},
+			hours(10), 0, 0, percent(100), nil},
+		{"one outage", []models.ServerSighting{sighting(0, closedAt(4)), sighting(6, nil)}, []models.Interval{span(0, 10)},
+			hours(8), hours(2), 0, percent(80), []models.Interval{span(4, 6)}},
+		{"scraper down during the gap", []models.ServerSighting{sighting(0, closedAt(4)), sighting(6, nil)}, []models.Interval{span(0, 4), span(6, 10)},
+			hours(8), 0, hours(2), percent(100), nil},
+		{"sightings outside coverage are unknown", []models.ServerSighting{sighting(0, nil)}, []models.Interval{span(5, 10)},
+			hours(5), 0, hours(5), percent(100), nil},
+		{"never scraped", []models.ServerSighting{sighting(0, nil)}, nil,
+			0, 0, hours(10), nil, nil},
+		{"never seen", nil, []models.Interval{span(0, 10)},
+			0, hours(10), 0, percent(0), []models.Interval{span(0, 10)}},
+		{"clipped to the window", []models.ServerSighting{sighting(-5, closedAt(2))}, []models.Interval{span(-10, 20)},
+			hours(2), hours(8), 0, percent(20), []models.Interval{span(2, 10)}},
+	}
+
+	for _, c := range cases {
+		got := Uptime(c.sightings, c.coverage, at(0), at(10))
+		if got.Online != c.online || got.Offline != c.offline || got.Unknown != c.unknown {
+			t.Errorf("%s: online %v, offline %v, unknown %v, want %v, %v, %v",
+				c.name, got.Online, got.Offline, got.Unknown, c.online, c.offline, c.unknown)
+		}
+		if (got.Availability == nil) != (c.availability == nil) || got.Availability != nil && *got.Availability != *c.availability {
+			t.Errorf("%s: availability %v, want %v", c.name, got.Availability, c.availability)
+		}
+		if !sameIntervals(got.Outages, c.outages) {
+			t.Errorf("%s: outages %v, want %v", c.name, got.Outages, c.outages)
+		}
+	}
+}
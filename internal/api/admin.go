@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"teamacedia/minestalker/internal/db"
+	"teamacedia/minestalker/internal/models"
+)
+
+// ListOptOutsHandler lists players who opted out of tracking
+// GET /api/v1/admin/optout
+func ListOptOutsHandler(w http.ResponseWriter, r *http.Request) {
+	optOuts, err := db.GetOptOuts()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving opt-out list: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, newOptOutResponses(optOuts))
+}
+
+// optOutRequest is the body of AddOptOutHandler.
+type optOutRequest struct {
+	PlayerName string `json:"player_name"`
+	Reason     string `json:"reason,omitempty"`
+	AddedBy    string `json:"added_by,omitempty"`
+}
+
+// erasureRequest is the body of ErasePlayerHandler.
+type erasureRequest struct {
+	PlayerName  string `json:"player_name"`
+	RequestedBy string `json:"requested_by,omitempty"`
+}
+
+// AddOptOutHandler opts a player out of tracking
+// POST /api/v1/admin/optout with {"player_name": ..., "reason": ..., "added_by": ...}
+func AddOptOutHandler(w http.ResponseWriter, r *http.Request) {
+	var req optOutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid JSON body")
+		return
+	}
+	if req.PlayerName == "" {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Missing player_name")
+		return
+	}
+	optOut := models.OptOut{PlayerName: req.PlayerName, Reason: req.Reason, AddedBy: req.AddedBy}
+	if err := db.AddOptOut(optOut); err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error adding opt-out: "+err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveOptOutHandler resumes tracking a player
+// DELETE /api/v1/admin/optout/{name}
+func RemoveOptOutHandler(w http.ResponseWriter, r *http.Request) {
+	playerName := r.PathValue("name")
+	if !db.IsOptedOut(playerName) {
+		writeError(w, http.StatusNotFound, codeNotFound, "Player is not opted out")
+		return
+	}
+	if err := db.RemoveOptOut(playerName); err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error removing opt-out: "+err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListErasuresHandler serves the erasure audit log
+// GET /api/v1/admin/erasures
+func ListErasuresHandler(w http.ResponseWriter, r *http.Request) {
+	erasures, err := db.GetErasures()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving erasures: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, newErasureResponses(erasures))
+}
+
+// ErasePlayerHandler erases everything stored about a player
+// POST /api/v1/admin/erasures with {"player_name": ..., "requested_by": ...}
+func ErasePlayerHandler(w http.ResponseWriter, r *http.Request) {
+	var req erasureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid JSON body")
+		return
+	}
+	if req.PlayerName == "" {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Missing player_name")
+		return
+	}
+	erasure, err := db.ErasePlayer(req.PlayerName, req.RequestedBy)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error erasing player: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, newErasureResponse(erasure))
+}
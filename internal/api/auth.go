@@ -0,0 +1,161 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"teamacedia/minestalker/internal/db"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// auth authenticates requests by API key, applies rate limits and checks
+// scopes. Requests without a key get the anonymous scopes and share a rate
+// limit per IP address, keyed requests are limited per key and the admin
+// token is not limited at all.
+type auth struct {
+	adminToken      string
+	anonymousScopes []string
+	anonymousRate   int
+	keyRate         int
+	trustedProxies  []netip.Prefix
+	limiter         *rateLimiter
+}
+
+func newAuth(cfg *models.Config) *auth {
+	return &auth{
+		adminToken:      cfg.AdminToken,
+		anonymousScopes: cfg.AnonymousScopes,
+		anonymousRate:   cfg.AnonymousRateLimit,
+		keyRate:         cfg.APIKeyRateLimit,
+		trustedProxies:  cfg.TrustedProxies,
+		limiter:         newRateLimiter(),
+	}
+}
+
+// require wraps a handler so it only runs for requests granted scope.
+func (a *auth) require(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := apiKeyFromRequest(r)
+
+		var scopes []string
+		var bucket string
+		var limit int
+		switch {
+		case token == "":
+			scopes, limit = a.anonymousScopes, a.anonymousRate
+			bucket = "ip:" + a.clientIP(r)
+		case a.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.adminToken)) == 1:
+			scopes = []string{db.ScopeAdmin}
+		default:
+			key, err := db.LookupAPIKey(token)
+			if errors.Is(err, db.ErrNotFound) {
+				writeError(w, http.StatusUnauthorized, codeUnauthorized, "Invalid or revoked API key")
+				return
+			} else if err != nil {
+				writeError(w, http.StatusInternalServerError, codeInternal, "Error checking API key: "+err.Error())
+				return
+			}
+			scopes, limit = key.Scopes, a.keyRate
+			if key.RateLimit > 0 {
+				limit = key.RateLimit
+			}
+			bucket = "key:" + strconv.Itoa(key.ID)
+		}
+
+		if limit > 0 {
+			rl := a.limiter.take(bucket, limit, time.Now())
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(rl.limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(rl.remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(rl.reset)))
+			if !rl.allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(rl.retry)))
+				writeError(w, http.StatusTooManyRequests, codeRateLimited,
+					fmt.Sprintf("Rate limit of %d requests per minute exceeded", rl.limit))
+				return
+			}
+		}
+
+		if !db.HasScope(scopes, scope) {
+			if token == "" {
+				writeError(w, http.StatusUnauthorized, codeUnauthorized, "This endpoint requires an API key with the "+scope+" scope")
+			} else {
+				writeError(w, http.StatusForbidden, codeForbidden, "API key lacks the "+scope+" scope")
+			}
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// apiKeyFromRequest reads the key from "Authorization: Bearer <key>", the
+// X-API-Key header or, for browser WebSockets and EventSource which cannot
+// set headers, the api_key query parameter.
+func apiKeyFromRequest(r *http.Request) string {
+	if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return key
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("api_key")
+}
+
+// clientIP returns the address a request came from. Requests from a trusted
+// proxy are taken to come from the last address in X-Forwarded-For that is
+// not a trusted proxy itself, or else from X-Real-IP.
+func (a *auth) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !a.trusted(host) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	client := ""
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break // Anything before a malformed entry cannot be believed
+		}
+		client = addr.Unmap().String()
+		if !a.trusted(client) {
+			return client
+		}
+	}
+	if client != "" {
+		return client // The furthest hop that can be believed
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap().String()
+	}
+	return host
+}
+
+// trusted reports whether addr belongs to a trusted proxy.
+func (a *auth) trusted(addr string) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap().WithZone("")
+	for _, prefix := range a.trustedProxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
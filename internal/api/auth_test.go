@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	a := &auth{trustedProxies: []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	}}
+
+	cases := []struct {
+		name      string
+		remote    string
+		forwarded []string // X-Forwarded-For headers
+		realIP    string
+		want      string
+	}{
+		{"direct", "203.0.113.5:4000", nil, "", "203.0.113.5"},
+		{"headers of untrusted clients ignored", "203.0.113.5:4000", []string{"198.51.100.7"}, "198.51.100.8", "203.0.113.5"},
+		{"through a proxy", "10.0.0.1:4000", []string{"198.51.100.7"}, "", "198.51.100.7"},
+		{"spoofed entries before the proxy ignored", "10.0.0.1:4000", []string{"192.0.2.1, 198.51.100.7"}, "", "198.51.100.7"},
+		{"through two proxies", "10.0.0.1:4000", []string{"198.51.100.7, 10.0.0.2"}, "", "198.51.100.7"},
+		{"repeated headers", "10.0.0.1:4000", []string{"198.51.100.7", "10.0.0.2"}, "", "198.51.100.7"},
+		{"only proxies", "10.0.0.1:4000", []string{"10.0.0.3, 10.0.0.2"}, "", "10.0.0.3"},
+		{"malformed entry", "10.0.0.1:4000", []string{"unknown, 10.0.0.2"}, "", "10.0.0.2"},
+		{"X-Real-IP", "10.0.0.1:4000", nil, "198.51.100.7", "198.51.100.7"},
+		{"X-Forwarded-For before X-Real-IP", "10.0.0.1:4000", []string{"198.51.100.7"}, "198.51.100.8", "198.51.100.7"},
+		{"proxy without headers", "10.0.0.1:4000", nil, "", "10.0.0.1"},
+		{"IPv6 proxy", "[fd00::1]:4000", []string{"2001:db8::7"}, "", "2001:db8::7"},
+		{"IPv4-mapped proxy", "[::ffff:10.0.0.1]:4000", []string{"198.51.100.7"}, "", "198.51.100.7"},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = c.remote
+		for _, f := range c.forwarded {
+			r.Header.Add("X-Forwarded-For", f)
+		}
+		if c.realIP != "" {
+			r.Header.Set("X-Real-IP", c.realIP)
+		}
+		if got := a.clientIP(r); got != c.want {
+			t.Errorf("%s: clientIP = %s, want %s", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// maxCacheEntries bounds a cache keyed on query parameters; when full it is
+// simply emptied, the entries are cheap to recompute.
+const maxCacheEntries = 64
+
+// ttlCache keeps computed responses for a fixed time so expensive endpoints
+// are computed at most once per ttl and key, however often they are polled.
+type ttlCache[T any] struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]cacheEntry[T]
+}
+
+type cacheEntry[T any] struct {
+	value   T
+	expires time.Time
+}
+
+func newTTLCache[T any](ttl time.Duration) *ttlCache[T] {
+	return &ttlCache[T]{ttl: ttl, entries: map[string]cacheEntry[T]{}}
+}
+
+// get returns the cached value for key, calling compute when it is missing or
+// expired. Errors are not cached.
+func (c *ttlCache[T]) get(key string, compute func() (T, error)) (T, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	value, err := compute()
+	if err != nil {
+		return value, err
+	}
+
+	c.mu.Lock()
+	if len(c.entries) >= maxCacheEntries {
+		clear(c.entries)
+	}
+	c.entries[key] = cacheEntry[T]{value: value, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return value, nil
+}
@@ -0,0 +1,839 @@
+package api
+
+import (
+	"math"
+	"teamacedia/minestalker/internal/analytics"
+	"teamacedia/minestalker/internal/events"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// The types below are the wire format of the v1 API. They are kept apart from
+// the models so storage can change without changing what clients see: every
+// field is snake_case and every timestamp is RFC 3339 in UTC.
+
+// formatTime formats t as RFC 3339 with a fixed millisecond fraction, matching
+// the precision timestamps are stored with. Events use the same layout.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(events.TimeLayout)
+}
+
+// formatOptionalTime formats t, or returns nil so the field encodes as null.
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := formatTime(*t)
+	return &s
+}
+
+// PlayerHistoryResponse is returned by GET /api/v1/player/{name}.
+type PlayerHistoryResponse struct {
+	Player    string                   `json:"player"`
+	Sightings []PlayerSightingResponse `json:"sightings"`
+}
+
+// PlayerSightingResponse is one session of a player on a server.
+type PlayerSightingResponse struct {
+	ServerAddress  string  `json:"server_address"`
+	ServerPort     int     `json:"server_port"`
+	ServerName     string  `json:"server_name"`
+	Game           string  `json:"game"`
+	ConnectedAt    string  `json:"connected_at"`
+	DisconnectedAt *string `json:"disconnected_at"` // null while still connected
+}
+
+// PlayerSightingRow is a row of the CSV and NDJSON exports of a player's
+// history.
+type PlayerSightingRow struct {
+	Player string `json:"player"`
+	PlayerSightingResponse
+}
+
+// PlayerStatsResponse is returned by GET /api/v1/player/{name}/stats. Days
+// and hours are in the requested time zone.
+type PlayerStatsResponse struct {
+	Player               string                   `json:"player"`
+	Timezone             string                   `json:"timezone"`
+	From                 *string                  `json:"from"` // null when covering all history
+	To                   *string                  `json:"to"`
+	TotalPlaytimeSeconds int64                    `json:"total_playtime_seconds"`
+	Sessions             int                      `json:"sessions"`
+	ActiveDays           int                      `json:"active_days"`
+	SessionsPerDay       float64                  `json:"sessions_per_day"`
+	AvgSessionSeconds    int64                    `json:"avg_session_seconds"`
+	FirstSeen            *string                  `json:"first_seen"`
+	LastSeen             *string                  `json:"last_seen"`
+	Servers              []ServerPlaytimeResponse `json:"servers"` // Most played first
+	FavouriteServers     []ServerPlaytimeResponse `json:"favourite_servers"`
+	// Heatmap holds seconds played per weekday, Sunday first, and hour of day.
+	Heatmap [][]int64 `json:"heatmap"`
+}
+
+// ServerPlaytimeResponse is the time a player spent on one server.
+type ServerPlaytimeResponse struct {
+	Address         string `json:"address"`
+	Port            int    `json:"port"`
+	Name            string `json:"name"`
+	Game            string `json:"game"`
+	PlaytimeSeconds int64  `json:"playtime_seconds"`
+	Sessions        int    `json:"sessions"`
+	LastSeen        string `json:"last_seen"`
+}
+
+// CompanionsResponse is returned by GET /api/v1/player/{name}/companions.
+type CompanionsResponse struct {
+	Player     string              `json:"player"`
+	Companions []CompanionResponse `json:"companions"` // Longest overlap first
+	Page       int                 `json:"page"`
+	PerPage    int                 `json:"per_page"`
+	Total      int                 `json:"total"`
+}
+
+// CompanionResponse is a player seen on the same server at the same time.
+type CompanionResponse struct {
+	Player         string `json:"player"`
+	OverlapSeconds int64  `json:"overlap_seconds"`
+	Sessions       int    `json:"sessions"`
+	LastTogether   string `json:"last_together"`
+}
+
+// ServerHistoryResponse is returned by GET /api/v1/server/{ip}/{port}.
+type ServerHistoryResponse struct {
+	Address   string                   `json:"address"`
+	Port      int                      `json:"port"`
+	Snapshots []ServerSnapshotResponse `json:"snapshots"`
+}
+
+// RosterResponse is returned by GET /api/v1/server/{ip}/{port}/roster. Either
+// at or from and to are set, depending on the query.
+type RosterResponse struct {
+	Address string                `json:"address"`
+	Port    int                   `json:"port"`
+	At      *string               `json:"at"`
+	From    *string               `json:"from"`
+	To      *string               `json:"to"`
+	Players []RosterEntryResponse `json:"players"` // Earliest join first
+}
+
+// RosterEntryResponse is one session of a player on the server. A player who
+// rejoined during a window is listed once per session.
+type RosterEntryResponse struct {
+	Player   string  `json:"player"`
+	JoinedAt string  `json:"joined_at"`
+	LeftAt   *string `json:"left_at"` // null while still online
+}
+
+// ServerSnapshotResponse is the state of one server in one snapshot.
+type ServerSnapshotResponse struct {
+	Time    string   `json:"time"`
+	Name    string   `json:"name"`
+	Game    string   `json:"game"`
+	Clients int      `json:"clients"`
+	Players []string `json:"players"`
+}
+
+// SnapshotResponse is returned by GET /api/v1/snapshot.
+type SnapshotResponse struct {
+	Time    string           `json:"time"`
+	Servers []ServerResponse `json:"servers"`
+}
+
+// SnapshotListResponse is returned by GET /api/v1/snapshots.
+type SnapshotListResponse struct {
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Snapshots []string `json:"snapshots"` // Times, oldest first
+}
+
+// SnapshotDiffResponse is returned by GET /api/v1/snapshot/diff. from and to
+// are the times of the snapshots actually compared.
+type SnapshotDiffResponse struct {
+	From        string                 `json:"from"`
+	To          string                 `json:"to"`
+	Appeared    []ServerResponse       `json:"appeared"`
+	Disappeared []ServerResponse       `json:"disappeared"`
+	Changed     []ServerChangeResponse `json:"changed"`
+}
+
+// ServerChangeResponse is the players who joined and left a server listed in
+// both snapshots of a diff.
+type ServerChangeResponse struct {
+	Address string   `json:"address"`
+	Port    int      `json:"port"`
+	Name    string   `json:"name"`
+	Joined  []string `json:"joined"`
+	Left    []string `json:"left"`
+}
+
+// ServerResponse is a server as listed in a snapshot.
+type ServerResponse struct {
+	Address string   `json:"address"`
+	Port    int      `json:"port"`
+	Name    string   `json:"name"`
+	Game    string   `json:"game"`
+	Clients int      `json:"clients"`
+	Players []string `json:"players"`
+}
+
+// SnapshotServerRow is a row of the CSV and NDJSON exports of snapshots and
+// server histories, the columns match the snapshot CSV import.
+type SnapshotServerRow struct {
+	Time string `json:"time"`
+	ServerResponse
+}
+
+// ServerListResponse is returned by GET /api/v1/servers.
+type ServerListResponse struct {
+	Servers []ServerStatusResponse `json:"servers"`
+	Page    int                    `json:"page"`
+	PerPage int                    `json:"per_page"`
+	Total   int                    `json:"total"` // Matching servers across all pages
+}
+
+// ServerStatusResponse is a known server with its current state.
+type ServerStatusResponse struct {
+	Address       string  `json:"address"`
+	Port          int     `json:"port"`
+	Name          string  `json:"name"`
+	Game          string  `json:"game"`
+	Online        bool    `json:"online"`
+	Players       int     `json:"players"`
+	OnlineSince   *string `json:"online_since"` // null while offline
+	UptimeSeconds int64   `json:"uptime_seconds"`
+	FirstSeen     string  `json:"first_seen"`
+	LastSeen      string  `json:"last_seen"`
+}
+
+// ServerUptimeResponse is returned by GET /api/v1/server/{ip}/{port}/uptime.
+type ServerUptimeResponse struct {
+	Address string                 `json:"address"`
+	Port    int                    `json:"port"`
+	Windows []UptimeWindowResponse `json:"windows"`
+	Outages []OutageResponse       `json:"outages"` // Over the longest window
+}
+
+// UptimeWindowResponse is the availability of a server over one window. Time
+// the scraper was not running is unknown and left out of the percentage.
+type UptimeWindowResponse struct {
+	Window                        string   `json:"window"`
+	From                          string   `json:"from"`
+	To                            string   `json:"to"`
+	AvailabilityPercent           *float64 `json:"availability_percent"` // null when nothing is known
+	OnlineSeconds                 int64    `json:"online_seconds"`
+	OfflineSeconds                int64    `json:"offline_seconds"`
+	UnknownSeconds                int64    `json:"unknown_seconds"`
+	OutageCount                   int      `json:"outage_count"`
+	MeanTimeBetweenOutagesSeconds *int64   `json:"mean_time_between_outages_seconds"` // null without outages
+	LongestOutageSeconds          int64    `json:"longest_outage_seconds"`
+}
+
+// OutageResponse is a span of time a server was known to be offline.
+type OutageResponse struct {
+	Start           string `json:"start"`
+	End             string `json:"end"`
+	DurationSeconds int64  `json:"duration_seconds"`
+}
+
+// ServerPopulationResponse is returned by GET /api/v1/server/{ip}/{port}/population.
+type ServerPopulationResponse struct {
+	Address           string                     `json:"address"`
+	Port              int                        `json:"port"`
+	From              string                     `json:"from"`
+	To                string                     `json:"to"`
+	ResolutionSeconds int64                      `json:"resolution_seconds"`
+	Buckets           []PopulationBucketResponse `json:"buckets"`
+}
+
+// PopulationBucketResponse summarises the player count over one bucket. The
+// counts come from snapshots and are null for buckets without any.
+type PopulationBucketResponse struct {
+	Start         string   `json:"start"`
+	End           string   `json:"end"`
+	Samples       int      `json:"samples"`
+	MinPlayers    *int     `json:"min_players"`
+	AvgPlayers    *float64 `json:"avg_players"`
+	MaxPlayers    *int     `json:"max_players"`
+	UniquePlayers int      `json:"unique_players"`
+}
+
+// GlobalStatsResponse is returned by GET /api/v1/stats/global.
+type GlobalStatsResponse struct {
+	GeneratedAt           string                 `json:"generated_at"`
+	ServersOnline         int                    `json:"servers_online"`
+	PlayersOnline         int                    `json:"players_online"`
+	UniquePlayersToday    int                    `json:"unique_players_today"`     // Since midnight UTC
+	UniquePlayersThisWeek int                    `json:"unique_players_this_week"` // Since Monday midnight UTC
+	TopServers            []ServerStatusResponse `json:"top_servers"`              // Most players first
+	TopGames              []GameTotalResponse    `json:"top_games"`                // Most players first
+	WindowSeconds         int64                  `json:"window_seconds"`
+	NewServers            int                    `json:"new_servers"` // First seen within the window
+	NewPlayers            int                    `json:"new_players"`
+}
+
+// GameTotalResponse is the number of online servers and players of a game.
+type GameTotalResponse struct {
+	Game    string `json:"game"`
+	Servers int    `json:"servers"`
+	Players int    `json:"players"`
+}
+
+// LeaderboardsResponse is returned by GET /api/v1/leaderboards, keyed by board.
+type LeaderboardsResponse struct {
+	Window string                                `json:"window"`
+	Since  *string                               `json:"since"` // null for all time
+	Boards map[string][]LeaderboardEntryResponse `json:"boards"`
+}
+
+// LeaderboardEntryResponse is one ranked player or server. Value is seconds
+// for playtime and uptime_streak and a count for the other boards.
+type LeaderboardEntryResponse struct {
+	Rank    int    `json:"rank"`
+	Player  string `json:"player,omitempty"`
+	Address string `json:"address,omitempty"`
+	Port    int    `json:"port,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Value   int64  `json:"value"`
+}
+
+// GameListResponse is returned by GET /api/v1/games, most servers first.
+type GameListResponse struct {
+	Games []GameResponse `json:"games"`
+}
+
+// GameResponse is a game with its current and all-time numbers, returned by
+// GET /api/v1/games/{id} and listed by GET /api/v1/games.
+type GameResponse struct {
+	Game          string                 `json:"game"`
+	ServersOnline int                    `json:"servers_online"`
+	PlayersOnline int                    `json:"players_online"`
+	ServersTotal  int                    `json:"servers_total"` // Ever seen running the game
+	PlayersTotal  int                    `json:"players_total"` // Ever seen playing it
+	TopServers    []ServerStatusResponse `json:"top_servers"`   // Most players first
+	Trend         []GameDayResponse      `json:"trend"`         // Oldest day first
+}
+
+// GameDayResponse is the average number of servers and players of a game
+// across the snapshots of one UTC day.
+type GameDayResponse struct {
+	Day        string  `json:"day"` // YYYY-MM-DD
+	AvgServers float64 `json:"avg_servers"`
+	AvgPlayers float64 `json:"avg_players"`
+}
+
+// PlayerStateResponse is where a player is online right now, sent to
+// WebSocket clients when they subscribe to the player.
+type PlayerStateResponse struct {
+	Player        string `json:"player"`
+	Online        bool   `json:"online"`
+	ServerAddress string `json:"server_address,omitempty"`
+	ServerPort    int    `json:"server_port,omitempty"`
+	ServerName    string `json:"server_name,omitempty"`
+	Since         string `json:"since,omitempty"`
+}
+
+// OptOutResponse is an entry of the opt-out list.
+type OptOutResponse struct {
+	PlayerName string `json:"player_name"`
+	Reason     string `json:"reason"`
+	AddedBy    string `json:"added_by"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// ErasureResponse is an entry of the erasure audit log.
+type ErasureResponse struct {
+	ID                      int    `json:"id"`
+	PlayerName              string `json:"player_name"`
+	RequestedBy             string `json:"requested_by"`
+	ErasedAt                string `json:"erased_at"`
+	SightingsDeleted        int64  `json:"sightings_deleted"`
+	SnapshotMentionsRemoved int64  `json:"snapshot_mentions_removed"`
+	AlertsDeleted           int64  `json:"alerts_deleted"`
+}
+
+// WebhookResponse is a webhook subscription. The secret is only included
+// when the subscription is created.
+type WebhookResponse struct {
+	ID                  int      `json:"id"`
+	URL                 string   `json:"url"`
+	Secret              string   `json:"secret,omitempty"`
+	Players             []string `json:"players"`
+	Servers             []string `json:"servers"`
+	Types               []string `json:"types"`
+	Enabled             bool     `json:"enabled"`
+	ConsecutiveFailures int      `json:"consecutive_failures"`
+	DisabledReason      string   `json:"disabled_reason,omitempty"` // Set when disabled automatically
+	CreatedAt           string   `json:"created_at"`
+}
+
+// WebhookDeliveriesResponse is a page of the delivery log of a webhook.
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+	Page       int                       `json:"page"`
+	PerPage    int                       `json:"per_page"`
+	Total      int                       `json:"total"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID          int    `json:"id"`
+	EventID     uint64 `json:"event_id"`
+	EventType   string `json:"event_type"`
+	Attempt     int    `json:"attempt"`
+	Success     bool   `json:"success"`
+	StatusCode  int    `json:"status_code,omitempty"` // Absent when no response was received
+	Error       string `json:"error,omitempty"`
+	DurationMs  int64  `json:"duration_ms"`
+	DeliveredAt string `json:"delivered_at"`
+}
+
+// GraphQLResponse is the result of a GraphQL query. Data has the shape of
+// the query and is absent when the query could not be run at all.
+type GraphQLResponse struct {
+	Data   any            `json:"data,omitempty"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
+
+type GraphQLError struct {
+	Message   string            `json:"message"`
+	Locations []GraphQLLocation `json:"locations,omitempty"`
+	Path      []any             `json:"path,omitempty"`
+}
+
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func newPlayerHistoryResponse(player string, history []models.PlayerSighting) PlayerHistoryResponse {
+	resp := PlayerHistoryResponse{
+		Player:    player,
+		Sightings: make([]PlayerSightingResponse, 0, len(history)),
+	}
+	for _, sighting := range history {
+		resp.Sightings = append(resp.Sightings, newPlayerSightingResponse(sighting))
+	}
+	return resp
+}
+
+func newPlayerSightingResponse(sighting models.PlayerSighting) PlayerSightingResponse {
+	return PlayerSightingResponse{
+		ServerAddress:  sighting.Address,
+		ServerPort:     sighting.Port,
+		ServerName:     sighting.ServerName,
+		Game:           sighting.Game,
+		ConnectedAt:    formatTime(sighting.ConnectedAt),
+		DisconnectedAt: formatOptionalTime(sighting.DisconnectedAt),
+	}
+}
+
+func newPlayerStatsResponse(player string, loc *time.Location, from, to time.Time, stats analytics.PlayerStats) PlayerStatsResponse {
+	resp := PlayerStatsResponse{
+		Player:               player,
+		Timezone:             loc.String(),
+		TotalPlaytimeSeconds: seconds(stats.TotalPlaytime),
+		Sessions:             stats.Sessions,
+		ActiveDays:           stats.ActiveDays,
+		SessionsPerDay:       stats.SessionsPerDay,
+		AvgSessionSeconds:    seconds(stats.AvgSession),
+		FirstSeen:            formatOptionalTime(stats.FirstSeen),
+		LastSeen:             formatOptionalTime(stats.LastSeen),
+		Servers:              newServerPlaytimeResponses(stats.Servers),
+		FavouriteServers:     newServerPlaytimeResponses(stats.Favourites),
+		Heatmap:              make([][]int64, len(stats.Heatmap)),
+	}
+	if !from.IsZero() {
+		resp.From, resp.To = formatOptionalTime(&from), formatOptionalTime(&to)
+	}
+	for day, hours := range stats.Heatmap {
+		resp.Heatmap[day] = make([]int64, len(hours))
+		for hour, d := range hours {
+			resp.Heatmap[day][hour] = seconds(d)
+		}
+	}
+	return resp
+}
+
+func newServerPlaytimeResponses(servers []analytics.ServerPlaytime) []ServerPlaytimeResponse {
+	resp := make([]ServerPlaytimeResponse, 0, len(servers))
+	for _, server := range servers {
+		resp = append(resp, ServerPlaytimeResponse{
+			Address:         server.Address,
+			Port:            server.Port,
+			Name:            server.Name,
+			Game:            server.Game,
+			PlaytimeSeconds: seconds(server.Playtime),
+			Sessions:        server.Sessions,
+			LastSeen:        formatTime(server.LastSeen),
+		})
+	}
+	return resp
+}
+
+func newCompanionsResponse(player string, companions []models.Companion, page, perPage, total int) CompanionsResponse {
+	resp := CompanionsResponse{
+		Player:     player,
+		Companions: make([]CompanionResponse, 0, len(companions)),
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+	}
+	for _, companion := range companions {
+		resp.Companions = append(resp.Companions, CompanionResponse{
+			Player:         companion.Player,
+			OverlapSeconds: seconds(companion.Overlap),
+			Sessions:       companion.Sessions,
+			LastTogether:   formatTime(companion.LastTogether),
+		})
+	}
+	return resp
+}
+
+func newRosterResponse(address string, port int, sightings []models.PlayerSighting) RosterResponse {
+	resp := RosterResponse{
+		Address: address,
+		Port:    port,
+		Players: make([]RosterEntryResponse, 0, len(sightings)),
+	}
+	for _, sighting := range sightings {
+		resp.Players = append(resp.Players, RosterEntryResponse{
+			Player:   sighting.Player,
+			JoinedAt: formatTime(sighting.ConnectedAt),
+			LeftAt:   formatOptionalTime(sighting.DisconnectedAt),
+		})
+	}
+	return resp
+}
+
+func newServerHistoryResponse(address string, port int, snapshots []models.Snapshot) ServerHistoryResponse {
+	resp := ServerHistoryResponse{
+		Address:   address,
+		Port:      port,
+		Snapshots: make([]ServerSnapshotResponse, 0, len(snapshots)),
+	}
+	for _, snapshot := range snapshots {
+		for _, server := range snapshot.Servers {
+			resp.Snapshots = append(resp.Snapshots, ServerSnapshotResponse{
+				Time:    formatTime(snapshot.Time),
+				Name:    server.Name,
+				Game:    server.Game,
+				Clients: server.Clients,
+				Players: nonNil(server.PlayerList),
+			})
+		}
+	}
+	return resp
+}
+
+func newSnapshotResponse(snapshot models.Snapshot) SnapshotResponse {
+	resp := SnapshotResponse{
+		Time:    formatTime(snapshot.Time),
+		Servers: make([]ServerResponse, 0, len(snapshot.Servers)),
+	}
+	for _, server := range snapshot.Servers {
+		resp.Servers = append(resp.Servers, newServerResponse(server))
+	}
+	return resp
+}
+
+func newSnapshotListResponse(from, to time.Time, times []time.Time) SnapshotListResponse {
+	resp := SnapshotListResponse{
+		From:      formatTime(from),
+		To:        formatTime(to),
+		Snapshots: make([]string, 0, len(times)),
+	}
+	for _, t := range times {
+		resp.Snapshots = append(resp.Snapshots, formatTime(t))
+	}
+	return resp
+}
+
+func newSnapshotDiffResponse(from, to time.Time, diff analytics.SnapshotDiff) SnapshotDiffResponse {
+	resp := SnapshotDiffResponse{
+		From:        formatTime(from),
+		To:          formatTime(to),
+		Appeared:    make([]ServerResponse, 0, len(diff.Appeared)),
+		Disappeared: make([]ServerResponse, 0, len(diff.Disappeared)),
+		Changed:     make([]ServerChangeResponse, 0, len(diff.Changed)),
+	}
+	for _, server := range diff.Appeared {
+		resp.Appeared = append(resp.Appeared, newServerResponse(server))
+	}
+	for _, server := range diff.Disappeared {
+		resp.Disappeared = append(resp.Disappeared, newServerResponse(server))
+	}
+	for _, change := range diff.Changed {
+		resp.Changed = append(resp.Changed, ServerChangeResponse{
+			Address: change.Address,
+			Port:    change.Port,
+			Name:    change.Name,
+			Joined:  nonNil(change.Joined),
+			Left:    nonNil(change.Left),
+		})
+	}
+	return resp
+}
+
+func newServerResponse(server models.Server) ServerResponse {
+	return ServerResponse{
+		Address: server.Address,
+		Port:    server.Port,
+		Name:    server.Name,
+		Game:    server.Game,
+		Clients: server.Clients,
+		Players: nonNil(server.PlayerList),
+	}
+}
+
+func newServerListResponse(servers []models.ServerStatus, page, perPage, total int) ServerListResponse {
+	return ServerListResponse{
+		Servers: newServerStatusResponses(servers),
+		Page:    page,
+		PerPage: perPage,
+		Total:   total,
+	}
+}
+
+func newServerStatusResponses(servers []models.ServerStatus) []ServerStatusResponse {
+	resp := make([]ServerStatusResponse, 0, len(servers))
+	for _, server := range servers {
+		var uptime int64
+		if server.OnlineSince != nil {
+			uptime = seconds(server.LastSeen.Sub(*server.OnlineSince))
+		}
+		resp = append(resp, ServerStatusResponse{
+			Address:       server.Address,
+			Port:          server.Port,
+			Name:          server.Name,
+			Game:          server.Game,
+			Online:        server.Online,
+			Players:       server.Players,
+			OnlineSince:   formatOptionalTime(server.OnlineSince),
+			UptimeSeconds: uptime,
+			FirstSeen:     formatTime(server.FirstSeen),
+			LastSeen:      formatTime(server.LastSeen),
+		})
+	}
+	return resp
+}
+
+func newGlobalStatsResponse(now time.Time, window time.Duration, counts models.GlobalCounts, topServers []models.ServerStatus, games []analytics.GameTotal) GlobalStatsResponse {
+	return GlobalStatsResponse{
+		GeneratedAt:           formatTime(now),
+		ServersOnline:         counts.ServersOnline,
+		PlayersOnline:         counts.PlayersOnline,
+		UniquePlayersToday:    counts.UniquePlayersToday,
+		UniquePlayersThisWeek: counts.UniquePlayersThisWeek,
+		TopServers:            newServerStatusResponses(topServers),
+		TopGames:              newGameTotalResponses(games),
+		WindowSeconds:         seconds(window),
+		NewServers:            counts.NewServers,
+		NewPlayers:            counts.NewPlayers,
+	}
+}
+
+func newGameTotalResponses(games []analytics.GameTotal) []GameTotalResponse {
+	resp := make([]GameTotalResponse, 0, len(games))
+	for _, game := range games {
+		resp = append(resp, GameTotalResponse{Game: game.Game, Servers: game.Servers, Players: game.Players})
+	}
+	return resp
+}
+
+func newUptimeWindowResponse(window string, report analytics.UptimeReport) UptimeWindowResponse {
+	resp := UptimeWindowResponse{
+		Window:               window,
+		From:                 formatTime(report.From),
+		To:                   formatTime(report.To),
+		AvailabilityPercent:  report.Availability,
+		OnlineSeconds:        seconds(report.Online),
+		OfflineSeconds:       seconds(report.Offline),
+		UnknownSeconds:       seconds(report.Unknown),
+		OutageCount:          len(report.Outages),
+		LongestOutageSeconds: seconds(report.LongestOutage),
+	}
+	if report.MeanTimeBetweenOutages != nil {
+		mtbo := seconds(*report.MeanTimeBetweenOutages)
+		resp.MeanTimeBetweenOutagesSeconds = &mtbo
+	}
+	return resp
+}
+
+func newOutageResponses(outages []models.Interval) []OutageResponse {
+	resp := make([]OutageResponse, 0, len(outages))
+	for _, outage := range outages {
+		resp = append(resp, OutageResponse{
+			Start:           formatTime(outage.Start),
+			End:             formatTime(outage.End),
+			DurationSeconds: seconds(outage.End.Sub(outage.Start)),
+		})
+	}
+	return resp
+}
+
+func newServerPopulationResponse(address string, port int, from, to time.Time, resolution time.Duration, buckets []analytics.PopulationBucket) ServerPopulationResponse {
+	resp := ServerPopulationResponse{
+		Address:           address,
+		Port:              port,
+		From:              formatTime(from),
+		To:                formatTime(to),
+		ResolutionSeconds: seconds(resolution),
+		Buckets:           make([]PopulationBucketResponse, 0, len(buckets)),
+	}
+	for _, bucket := range buckets {
+		b := PopulationBucketResponse{
+			Start:         formatTime(bucket.Start),
+			End:           formatTime(bucket.End),
+			Samples:       bucket.Samples,
+			UniquePlayers: bucket.UniquePlayers,
+		}
+		if bucket.Samples > 0 {
+			b.MinPlayers, b.AvgPlayers, b.MaxPlayers = &bucket.Min, &bucket.Avg, &bucket.Max
+		}
+		resp.Buckets = append(resp.Buckets, b)
+	}
+	return resp
+}
+
+func newLeaderboardEntryResponses(entries []models.LeaderboardEntry) []LeaderboardEntryResponse {
+	resp := make([]LeaderboardEntryResponse, 0, len(entries))
+	for i, entry := range entries {
+		resp = append(resp, LeaderboardEntryResponse{
+			Rank:    i + 1,
+			Player:  entry.Player,
+			Address: entry.Address,
+			Port:    entry.Port,
+			Name:    entry.Name,
+			Value:   int64(math.Round(entry.Value)),
+		})
+	}
+	return resp
+}
+
+func newGameResponse(counts models.GameCounts, online analytics.GameTotal, top []models.ServerStatus, trend []models.GameDay) GameResponse {
+	resp := GameResponse{
+		Game:          counts.Game,
+		ServersOnline: online.Servers,
+		PlayersOnline: online.Players,
+		ServersTotal:  counts.ServersTotal,
+		PlayersTotal:  counts.PlayersTotal,
+		TopServers:    newServerStatusResponses(top),
+		Trend:         make([]GameDayResponse, 0, len(trend)),
+	}
+	for _, day := range trend {
+		resp.Trend = append(resp.Trend, GameDayResponse{
+			Day:        day.Day.Format(time.DateOnly),
+			AvgServers: day.Servers,
+			AvgPlayers: day.Players,
+		})
+	}
+	return resp
+}
+
+// newPlayerStateResponse reports the player online on the server of their
+// newest open sighting, if any.
+func newPlayerStateResponse(player string, sightings []models.PlayerSighting) PlayerStateResponse {
+	resp := PlayerStateResponse{Player: player}
+	for _, s := range sightings {
+		if s.DisconnectedAt != nil {
+			continue
+		}
+		resp.Online = true
+		resp.ServerAddress = s.Address
+		resp.ServerPort = s.Port
+		resp.ServerName = s.ServerName
+		resp.Since = formatTime(s.ConnectedAt)
+		break
+	}
+	return resp
+}
+
+func newOptOutResponses(optOuts []models.OptOut) []OptOutResponse {
+	resp := make([]OptOutResponse, 0, len(optOuts))
+	for _, optOut := range optOuts {
+		resp = append(resp, OptOutResponse{
+			PlayerName: optOut.PlayerName,
+			Reason:     optOut.Reason,
+			AddedBy:    optOut.AddedBy,
+			CreatedAt:  formatTime(optOut.CreatedAt),
+		})
+	}
+	return resp
+}
+
+func newErasureResponse(erasure models.PlayerErasure) ErasureResponse {
+	return ErasureResponse{
+		ID:                      erasure.ID,
+		PlayerName:              erasure.PlayerName,
+		RequestedBy:             erasure.RequestedBy,
+		ErasedAt:                formatTime(erasure.ErasedAt),
+		SightingsDeleted:        erasure.SightingsDeleted,
+		SnapshotMentionsRemoved: erasure.SnapshotMentionsRemoved,
+		AlertsDeleted:           erasure.AlertsDeleted,
+	}
+}
+
+func newErasureResponses(erasures []models.PlayerErasure) []ErasureResponse {
+	resp := make([]ErasureResponse, 0, len(erasures))
+	for _, erasure := range erasures {
+		resp = append(resp, newErasureResponse(erasure))
+	}
+	return resp
+}
+
+// seconds truncates a duration to whole seconds.
+func seconds(d time.Duration) int64 {
+	return int64(d / time.Second)
+}
+
+// nonNil keeps empty lists encoding as [] rather than null.
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+func newWebhookResponse(sub models.WebhookSubscription) WebhookResponse {
+	return WebhookResponse{
+		ID:                  sub.ID,
+		URL:                 sub.URL,
+		Players:             nonNil(sub.Players),
+		Servers:             nonNil(sub.Servers),
+		Types:               nonNil(sub.Types),
+		Enabled:             sub.Enabled,
+		ConsecutiveFailures: sub.ConsecutiveFailures,
+		DisabledReason:      sub.DisabledReason,
+		CreatedAt:           formatTime(sub.CreatedAt),
+	}
+}
+
+func newWebhookResponses(subs []models.WebhookSubscription) []WebhookResponse {
+	resp := make([]WebhookResponse, 0, len(subs))
+	for _, sub := range subs {
+		resp = append(resp, newWebhookResponse(sub))
+	}
+	return resp
+}
+
+func newWebhookDeliveriesResponse(deliveries []models.WebhookDelivery, page, perPage, total int) WebhookDeliveriesResponse {
+	resp := WebhookDeliveriesResponse{
+		Deliveries: make([]WebhookDeliveryResponse, 0, len(deliveries)),
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+	}
+	for _, d := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, WebhookDeliveryResponse{
+			ID:          d.ID,
+			EventID:     d.EventID,
+			EventType:   d.EventType,
+			Attempt:     d.Attempt,
+			Success:     d.Succeeded(),
+			StatusCode:  d.StatusCode,
+			Error:       d.Error,
+			DurationMs:  d.Duration.Milliseconds(),
+			DeliveredAt: formatTime(d.DeliveredAt),
+		})
+	}
+	return resp
+}
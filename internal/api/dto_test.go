@@ -0,0 +1,300 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"teamacedia/minestalker/internal/analytics"
+	"teamacedia/minestalker/internal/events"
+	"teamacedia/minestalker/internal/models"
+	"testing"
+	"time"
+)
+
+// The golden files in testdata/wire pin the JSON clients receive. A change to
+// one of them is a change of the v1 wire format; regenerate them with
+//
+//	go test ./internal/api -run TestWireFormat -update
+//
+// only when that is intended.
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/wire")
+
+var (
+	// A time off UTC and below millisecond precision, which must be sent as
+	// UTC truncated to milliseconds, and a whole second which keeps its .000
+	connected    = time.Date(2025, 1, 2, 11, 4, 5, 123456789, time.FixedZone("CET", 3600))
+	disconnected = time.Date(2025, 1, 2, 12, 30, 0, 0, time.UTC)
+	later        = disconnected.Add(36 * time.Hour)
+)
+
+var wireCases = []struct {
+	name string
+	resp any
+}{
+	{"player_history", newPlayerHistoryResponse("alice", []models.PlayerSighting{
+		{Address: "example.org", Port: 30000, ServerName: "Example", Game: "minetest", Player: "alice", ConnectedAt: connected, DisconnectedAt: &disconnected},
+		{Address: "example.org", Port: 30001, ServerName: "Creative", Game: "mineclonia", Player: "alice", ConnectedAt: later},
+	})},
+	{"player_history_empty", newPlayerHistoryResponse("nobody", nil)},
+	{"player_sighting_row", PlayerSightingRow{Player: "alice", PlayerSightingResponse: newPlayerSightingResponse(models.PlayerSighting{
+		Address: "example.org", Port: 30000, ServerName: "Example", Game: "minetest", Player: "alice", ConnectedAt: connected,
+	})}},
+	{"player_stats", newPlayerStatsResponse("alice", time.UTC, connected, later, analytics.PlayerStats{
+		TotalPlaytime:  90*time.Minute + 999*time.Millisecond,
+		Sessions:       2,
+		ActiveDays:     1,
+		SessionsPerDay: 2,
+		AvgSession:     45 * time.Minute,
+		FirstSeen:      &connected,
+		LastSeen:       &later,
+		Servers: []analytics.ServerPlaytime{
+			{Address: "example.org", Port: 30000, Name: "Example", Game: "minetest", Playtime: 90 * time.Minute, Sessions: 2, LastSeen: disconnected},
+		},
+		Heatmap: [7][24]time.Duration{4: {10: 30 * time.Minute, 11: time.Hour}},
+	})},
+	{"player_stats_all_time", newPlayerStatsResponse("nobody", time.UTC, time.Time{}, time.Time{}, analytics.PlayerStats{})},
+	{"companions", newCompanionsResponse("alice", []models.Companion{
+		{Player: "bob", Overlap: 75 * time.Minute, Sessions: 3, LastTogether: disconnected},
+	}, 1, 50, 1)},
+	{"roster", func() RosterResponse {
+		resp := newRosterResponse("example.org", 30000, []models.PlayerSighting{
+			{Player: "alice", ConnectedAt: connected, DisconnectedAt: &disconnected},
+			{Player: "bob", ConnectedAt: later},
+		})
+		resp.At = formatOptionalTime(&later)
+		return resp
+	}()},
+	{"server_history", newServerHistoryResponse("example.org", 30000, []models.Snapshot{
+		{Time: connected, Servers: []models.Server{{Address: "example.org", Port: 30000, Name: "Example", Game: "minetest", Clients: 1, PlayerList: []string{"alice"}}}},
+		{Time: disconnected, Servers: []models.Server{{Address: "example.org", Port: 30000, Name: "Example", Game: "minetest"}}},
+	})},
+	{"snapshot", newSnapshotResponse(models.Snapshot{Time: connected, Servers: []models.Server{
+		{Address: "example.org", Port: 30000, Name: "Example", Game: "minetest", Clients: 2, PlayerList: []string{"alice", "bob"}},
+	}})},
+	{"snapshot_server_row", SnapshotServerRow{Time: formatTime(connected), ServerResponse: newServerResponse(models.Server{
+		Address: "example.org", Port: 30000, Name: "Example", Game: "minetest",
+	})}},
+	{"snapshot_list", newSnapshotListResponse(connected, later, []time.Time{connected, disconnected})},
+	{"snapshot_diff", newSnapshotDiffResponse(connected, disconnected, analytics.SnapshotDiff{
+		Appeared: []models.Server{{Address: "new.example.org", Port: 30000, Name: "New", Game: "minetest"}},
+		Changed:  []analytics.ServerChange{{Address: "example.org", Port: 30000, Name: "Example", Joined: []string{"bob"}}},
+	})},
+	{"server_list", newServerListResponse([]models.ServerStatus{
+		{Address: "example.org", Port: 30000, Name: "Example", Game: "minetest", Online: true, Players: 2, OnlineSince: &connected, FirstSeen: connected, LastSeen: later},
+		{Address: "old.example.org", Port: 30000, Name: "Old", Game: "minetest", FirstSeen: connected, LastSeen: disconnected},
+	}, 2, 2, 3)},
+	{"server_uptime", ServerUptimeResponse{
+		Address: "example.org",
+		Port:    30000,
+		Windows: []UptimeWindowResponse{
+			newUptimeWindowResponse("24h", analytics.UptimeReport{
+				From: connected, To: later, Online: 30 * time.Hour, Offline: 2 * time.Hour, Unknown: time.Hour,
+				Availability: ptr(93.75), Outages: []models.Interval{{Start: connected, End: disconnected}},
+				MeanTimeBetweenOutages: ptr(30 * time.Hour), LongestOutage: 2 * time.Hour,
+			}),
+			newUptimeWindowResponse("7d", analytics.UptimeReport{From: connected, To: later, Unknown: 7 * 24 * time.Hour}),
+		},
+		Outages: newOutageResponses([]models.Interval{{Start: connected, End: disconnected}}),
+	}},
+	{"server_population", newServerPopulationResponse("example.org", 30000, connected, later, time.Hour, []analytics.PopulationBucket{
+		{Start: disconnected, End: disconnected.Add(time.Hour), Samples: 12, Min: 1, Max: 4, Avg: 2.5, UniquePlayers: 5},
+		{Start: disconnected.Add(time.Hour), End: disconnected.Add(2 * time.Hour)},
+	})},
+	{"global_stats", newGlobalStatsResponse(later, 24*time.Hour, models.GlobalCounts{
+		ServersOnline: 1, PlayersOnline: 2, UniquePlayersToday: 3, UniquePlayersThisWeek: 4, NewServers: 5, NewPlayers: 6,
+	}, []models.ServerStatus{
+		{Address: "example.org", Port: 30000, Name: "Example", Game: "minetest", Online: true, Players: 2, OnlineSince: &connected, FirstSeen: connected, LastSeen: later},
+	}, []analytics.GameTotal{{Game: "minetest", Servers: 1, Players: 2}})},
+	{"leaderboards", LeaderboardsResponse{
+		Window: "week",
+		Since:  formatOptionalTime(&connected),
+		Boards: map[string][]LeaderboardEntryResponse{
+			"playtime":        newLeaderboardEntryResponses([]models.LeaderboardEntry{{Player: "alice", Value: 5400.4}}),
+			"uptime_streak":   newLeaderboardEntryResponses([]models.LeaderboardEntry{{Address: "example.org", Port: 30000, Name: "Example", Value: 86400}}),
+			"servers_visited": newLeaderboardEntryResponses(nil),
+		},
+	}},
+	{"leaderboards_all_time", LeaderboardsResponse{Window: "all", Boards: map[string][]LeaderboardEntryResponse{}}},
+	{"game_list", GameListResponse{Games: []GameResponse{
+		newGameResponse(models.GameCounts{Game: "minetest", ServersTotal: 3, PlayersTotal: 10}, analytics.GameTotal{Game: "minetest", Servers: 1, Players: 2}, nil, []models.GameDay{
+			{Day: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), Servers: 1.5, Players: 2.25},
+		}),
+	}}},
+	{"event_player", events.Event{ID: 1735812245123000, TrackingEvent: models.TrackingEvent{
+		Type: "playerJoin", Server: "example.org", Port: 30000, Timestamp: connected, Player: "alice", Name: "Example",
+	}}.Payload()},
+	{"event_server", events.Event{ID: 1735812245123001, TrackingEvent: models.TrackingEvent{
+		Type: "serverOnline", Server: "example.org", Port: 30000, Timestamp: connected, Game: "minetest", Name: "Example",
+	}}.Payload()},
+	{"player_state_online", newPlayerStateResponse("alice", []models.PlayerSighting{
+		{Address: "example.org", Port: 30001, ServerName: "Creative", ConnectedAt: later},
+	})},
+	{"player_state_offline", newPlayerStateResponse("alice", []models.PlayerSighting{
+		{Address: "example.org", Port: 30000, ServerName: "Example", ConnectedAt: connected, DisconnectedAt: &disconnected},
+	})},
+	{"opt_outs", newOptOutResponses([]models.OptOut{{PlayerName: "alice", Reason: "asked", AddedBy: "admin", CreatedAt: connected}})},
+	{"erasures", newErasureResponses([]models.PlayerErasure{
+		{ID: 1, PlayerName: "alice", RequestedBy: "admin", ErasedAt: connected, SightingsDeleted: 2, SnapshotMentionsRemoved: 3, AlertsDeleted: 1},
+	})},
+	{"webhook_created", func() WebhookResponse {
+		resp := newWebhookResponse(models.WebhookSubscription{ID: 1, URL: "https://example.org/hook", Players: []string{"alice"}, Enabled: true, CreatedAt: connected})
+		resp.Secret = "0123456789abcdef"
+		return resp
+	}()},
+	{"webhooks", newWebhookResponses([]models.WebhookSubscription{
+		{ID: 1, URL: "https://example.org/hook", Secret: "never sent", Servers: []string{"example.org:30000"}, Types: []string{"playerJoin"}, Enabled: true, CreatedAt: connected},
+		{ID: 2, URL: "https://example.org/gone", ConsecutiveFailures: 5, DisabledReason: "5 consecutive failed deliveries", CreatedAt: connected},
+	})},
+	{"webhook_deliveries", newWebhookDeliveriesResponse([]models.WebhookDelivery{
+		{ID: 2, EventID: 1735812245123000, EventType: "playerJoin", Attempt: 1, StatusCode: 204, Duration: 120 * time.Millisecond, DeliveredAt: connected},
+		{ID: 1, EventID: 1735812245123000, EventType: "playerJoin", Attempt: 2, Error: "connection refused", Duration: 5 * time.Millisecond, DeliveredAt: disconnected},
+	}, 1, 50, 2)},
+	{"graphql", GraphQLResponse{
+		Data:   map[string]any{"player": nil},
+		Errors: []GraphQLError{{Message: "unknown field", Locations: []GraphQLLocation{{Line: 1, Column: 3}}, Path: []any{"player", 0}}},
+	}},
+	{"error", errorResponse{Error: errorBody{Code: codeNotFound, Message: "Player not found"}}},
+}
+
+// legacyCases are the payloads of the deprecated unversioned routes, which
+// predate the conventions of v1 and are pinned as they were.
+var legacyCases = []struct {
+	name string
+	resp any
+}{
+	{"legacy_player_history", []legacyPlayerSighting{
+		{Address: "example.org", Port: 30000, Player: "alice", ConnectedAt: disconnected, DisconnectedAt: &later},
+		{Address: "example.org", Port: 30001, Player: "alice", ConnectedAt: later},
+	}},
+	{"legacy_player_history_unknown", []legacyPlayerSighting(nil)},
+	{"legacy_snapshot", models.Snapshot{Time: disconnected, Servers: []models.Server{
+		{Address: "example.org", Port: 30000, Name: "Example", Game: "minetest", Clients: 1, PlayerList: []string{"alice"}},
+	}}},
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestWireFormat(t *testing.T) {
+	for _, c := range append(wireCases, legacyCases...) {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := json.MarshalIndent(c.resp, "", "  ")
+			if err != nil {
+				t.Fatalf("encoding: %v", err)
+			}
+			got = append(got, '\n')
+
+			path := filepath.Join("testdata", "wire", c.name+".json")
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading golden file, run with -update to create it: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s changed:\n got: %s\nwant: %s", path, got, want)
+			}
+		})
+	}
+}
+
+var (
+	snakeCase      = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+	looksLikeTime  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T`)
+	wireTimeFormat = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}Z$`)
+)
+
+// TestWireConventions checks the rules every v1 type follows independently of
+// the golden files, so a newly added field cannot break them unnoticed.
+func TestWireConventions(t *testing.T) {
+	for _, c := range wireCases {
+		encoded, err := json.Marshal(c.resp)
+		if err != nil {
+			t.Fatalf("%s: encoding: %v", c.name, err)
+		}
+		var decoded any
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatalf("%s: decoding: %v", c.name, err)
+		}
+		checkWireValue(t, c.name, decoded)
+	}
+}
+
+func checkWireValue(t *testing.T, path string, v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if !snakeCase.MatchString(key) {
+				t.Errorf("%s: key %q is not snake_case", path, key)
+			}
+			checkWireValue(t, path+"."+key, value)
+		}
+	case []any:
+		for _, value := range v {
+			checkWireValue(t, path+"[]", value)
+		}
+	case string:
+		if looksLikeTime.MatchString(v) && !wireTimeFormat.MatchString(v) {
+			t.Errorf("%s: time %q is not RFC 3339 UTC with milliseconds", path, v)
+		}
+	}
+}
+
+// TestNullAndOmittedFields pins which fields are sent as null and which are
+// left out, the difference clients check with "in" or hasOwnProperty.
+func TestNullAndOmittedFields(t *testing.T) {
+	cases := []struct {
+		name    string
+		null    []string
+		omitted []string
+	}{
+		{"player_history_empty", nil, nil},
+		{"player_stats_all_time", []string{"from", "to", "first_seen", "last_seen"}, nil},
+		{"roster", []string{"from", "to"}, nil},
+		{"event_player", nil, []string{"game"}},
+		{"event_server", nil, []string{"player"}},
+		{"player_state_offline", nil, []string{"server_address", "server_port", "server_name", "since"}},
+		{"webhook_created", nil, []string{"disabled_reason"}},
+		{"leaderboards_all_time", []string{"since"}, nil},
+	}
+	byName := map[string]any{}
+	for _, c := range wireCases {
+		byName[c.name] = c.resp
+	}
+
+	for _, c := range cases {
+		encoded, err := json.Marshal(byName[c.name])
+		if err != nil {
+			t.Fatalf("%s: encoding: %v", c.name, err)
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(encoded, &fields); err != nil {
+			t.Fatalf("%s: decoding: %v", c.name, err)
+		}
+		for _, key := range c.null {
+			if raw, ok := fields[key]; !ok || string(raw) != "null" {
+				t.Errorf("%s: %s = %s, want null", c.name, key, raw)
+			}
+		}
+		for _, key := range c.omitted {
+			if raw, ok := fields[key]; ok {
+				t.Errorf("%s: %s = %s, want it left out", c.name, key, raw)
+			}
+		}
+		// Lists are never null, an empty one is []
+		for key, raw := range fields {
+			if string(raw) == "null" && !slices.Contains(c.null, key) {
+				t.Errorf("%s: %s is null", c.name, key)
+			}
+		}
+	}
+}
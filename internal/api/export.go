@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Formats of the endpoints that export rows, picked with ?format= or the
+// Accept header. JSON is the default.
+const (
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+)
+
+var formatContentTypes = map[string]string{
+	formatCSV:    "text/csv; charset=utf-8",
+	formatNDJSON: "application/x-ndjson",
+}
+
+// negotiateFormat reads the format parameter, falling back to the first CSV
+// or NDJSON media type in the Accept header and then to JSON.
+func negotiateFormat(r *http.Request) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "":
+	case formatJSON, formatCSV, formatNDJSON:
+		return format, nil
+	default:
+		return "", paramError{"format", "expected json, csv or ndjson"}
+	}
+
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv":
+			return formatCSV, nil
+		case "application/x-ndjson", "application/ndjson", "application/jsonl":
+			return formatNDJSON, nil
+		case "application/json":
+			return formatJSON, nil
+		}
+	}
+	return formatJSON, nil
+}
+
+// rowWriter streams rows as CSV or NDJSON. CSV columns are the JSON field
+// names of the row type, lists are joined with ";" and null is empty. The
+// headers go out with the first row, so errors before it can still be
+// answered with a JSON error.
+type rowWriter struct {
+	w       http.ResponseWriter
+	format  string
+	columns []string
+	csv     *csv.Writer
+	started bool
+	flush   bool // After every row, for live streams
+}
+
+func newRowWriter(w http.ResponseWriter, format string, row any) *rowWriter {
+	return &rowWriter{w: w, format: format, columns: csvColumns(reflect.TypeOf(row))}
+}
+
+func (rw *rowWriter) start() error {
+	if rw.started {
+		return nil
+	}
+	rw.started = true
+	rw.w.Header().Set("Content-Type", formatContentTypes[rw.format])
+	rw.w.WriteHeader(http.StatusOK)
+	if rw.format == formatCSV {
+		rw.csv = csv.NewWriter(rw.w)
+		return rw.csv.Write(rw.columns)
+	}
+	return nil
+}
+
+func (rw *rowWriter) write(row any) error {
+	if err := rw.start(); err != nil {
+		return err
+	}
+
+	if rw.format == formatCSV {
+		if err := rw.csv.Write(csvValues(reflect.ValueOf(row))); err != nil {
+			return err
+		}
+	} else {
+		data, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		if _, err := rw.w.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+
+	if rw.flush {
+		return rw.close()
+	}
+	return nil
+}
+
+// close flushes buffered rows, sending the headers for an empty export.
+func (rw *rowWriter) close() error {
+	if err := rw.start(); err != nil {
+		return err
+	}
+	if rw.csv != nil {
+		rw.csv.Flush()
+		if err := rw.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(rw.w).Flush()
+}
+
+func csvColumns(t reflect.Type) []string {
+	var columns []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			columns = append(columns, csvColumns(field.Type)...)
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		columns = append(columns, name)
+	}
+	return columns
+}
+
+func csvValues(v reflect.Value) []string {
+	var values []string
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if v.Type().Field(i).Anonymous {
+			values = append(values, csvValues(field)...)
+			continue
+		}
+		values = append(values, csvValue(field))
+	}
+	return values
+}
+
+func csvValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return ""
+		}
+		return csvValue(v.Elem())
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = csvValue(v.Index(i))
+		}
+		return strings.Join(items, ";")
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"teamacedia/minestalker/internal/events"
+	"testing"
+)
+
+func TestCSVFlattening(t *testing.T) {
+	disconnected := "2025-01-02T12:30:00.000Z"
+	cases := []struct {
+		name    string
+		row     any
+		columns []string
+		values  []string
+	}{
+		{"embedded struct", PlayerSightingRow{Player: "alice", PlayerSightingResponse: PlayerSightingResponse{
+			ServerAddress: "example.org", ServerPort: 30000, ServerName: "Example, the server", Game: "minetest",
+			ConnectedAt: "2025-01-02T10:04:05.123Z", DisconnectedAt: &disconnected,
+		}},
+			[]string{"player", "server_address", "server_port", "server_name", "game", "connected_at", "disconnected_at"},
+			[]string{"alice", "example.org", "30000", "Example, the server", "minetest", "2025-01-02T10:04:05.123Z", disconnected}},
+		{"null is empty", PlayerSightingRow{Player: "alice", PlayerSightingResponse: PlayerSightingResponse{ConnectedAt: "2025-01-02T10:04:05.123Z"}},
+			[]string{"player", "server_address", "server_port", "server_name", "game", "connected_at", "disconnected_at"},
+			[]string{"alice", "", "0", "", "", "2025-01-02T10:04:05.123Z", ""}},
+		{"lists joined", SnapshotServerRow{Time: "2025-01-02T10:04:05.123Z", ServerResponse: ServerResponse{
+			Address: "example.org", Port: 30000, Name: "Example", Game: "minetest", Clients: 2, Players: []string{"alice", "bob"},
+		}},
+			[]string{"time", "address", "port", "name", "game", "clients", "players"},
+			[]string{"2025-01-02T10:04:05.123Z", "example.org", "30000", "Example", "minetest", "2", "alice;bob"}},
+		{"empty list", SnapshotServerRow{ServerResponse: ServerResponse{Players: []string{}}},
+			[]string{"time", "address", "port", "name", "game", "clients", "players"},
+			[]string{"", "", "0", "", "", "0", ""}},
+		{"omitempty ignored", events.Payload{ID: 1735812245123000, Type: "serverOnline", ServerPort: 30000},
+			[]string{"id", "type", "time", "server_address", "server_port", "server_name", "game", "player"},
+			[]string{"1735812245123000", "serverOnline", "", "", "30000", "", "", ""}},
+		{"floats and bools", struct {
+			Avg    float64  `json:"avg"`
+			Whole  float64  `json:"whole"`
+			Online bool     `json:"online"`
+			Peak   *float64 `json:"peak"`
+		}{Avg: 2.25, Whole: 3, Online: true},
+			[]string{"avg", "whole", "online", "peak"},
+			[]string{"2.25", "3", "true", ""}},
+	}
+
+	for _, c := range cases {
+		if got := csvColumns(reflect.TypeOf(c.row)); !slices.Equal(got, c.columns) {
+			t.Errorf("%s: columns %q, want %q", c.name, got, c.columns)
+		}
+		if got := csvValues(reflect.ValueOf(c.row)); !slices.Equal(got, c.values) {
+			t.Errorf("%s: values %q, want %q", c.name, got, c.values)
+		}
+	}
+}
+
+func TestRowWriter(t *testing.T) {
+	rows := []SnapshotServerRow{
+		{Time: "2025-01-02T10:04:05.123Z", ServerResponse: ServerResponse{Address: "example.org", Port: 30000, Name: `Say "hi", all`, Players: []string{"alice", "bob"}}},
+		{Time: "2025-01-02T10:04:05.123Z", ServerResponse: ServerResponse{Address: "example.net", Port: 30001, Name: "Other"}},
+	}
+	cases := []struct {
+		format      string
+		rows        []SnapshotServerRow
+		contentType string
+		body        string
+	}{
+		{formatCSV, rows, "text/csv; charset=utf-8", "time,address,port,name,game,clients,players\n" +
+			"2025-01-02T10:04:05.123Z,example.org,30000,\"Say \"\"hi\"\", all\",,0,alice;bob\n" +
+			"2025-01-02T10:04:05.123Z,example.net,30001,Other,,0,\n"},
+		{formatCSV, nil, "text/csv; charset=utf-8", "time,address,port,name,game,clients,players\n"},
+		{formatNDJSON, rows, "application/x-ndjson",
+			`{"time":"2025-01-02T10:04:05.123Z","address":"example.org","port":30000,"name":"Say \"hi\", all","game":"","clients":0,"players":["alice","bob"]}` + "\n" +
+				`{"time":"2025-01-02T10:04:05.123Z","address":"example.net","port":30001,"name":"Other","game":"","clients":0,"players":null}` + "\n"},
+		{formatNDJSON, nil, "application/x-ndjson", ""},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		rw := newRowWriter(w, c.format, SnapshotServerRow{})
+		for _, row := range c.rows {
+			if err := rw.write(row); err != nil {
+				t.Fatalf("%s: write: %v", c.format, err)
+			}
+		}
+		if err := rw.close(); err != nil {
+			t.Fatalf("%s: close: %v", c.format, err)
+		}
+
+		if got := w.Header().Get("Content-Type"); got != c.contentType {
+			t.Errorf("%s with %d rows: Content-Type %q, want %q", c.format, len(c.rows), got, c.contentType)
+		}
+		if got := w.Body.String(); got != c.body {
+			t.Errorf("%s with %d rows: body\n%s\nwant\n%s", c.format, len(c.rows), got, c.body)
+		}
+	}
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	cases := []struct {
+		query, accept string
+		want          string
+		ok            bool
+	}{
+		{"", "", formatJSON, true},
+		{"format=csv", "", formatCSV, true},
+		{"format=ndjson", "text/csv", formatNDJSON, true},
+		{"format=json", "text/csv", formatJSON, true},
+		{"format=xml", "", "", false},
+		{"", "text/csv", formatCSV, true},
+		{"", "text/csv; charset=utf-8", formatCSV, true},
+		{"", "application/x-ndjson", formatNDJSON, true},
+		{"", "application/jsonl", formatNDJSON, true},
+		{"", "text/html, application/json;q=0.9, text/csv", formatJSON, true},
+		{"", "text/html, */*", formatJSON, true},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/player/alice/history?"+c.query, nil)
+		if c.accept != "" {
+			r.Header.Set("Accept", c.accept)
+		}
+		got, err := negotiateFormat(r)
+		if (err == nil) != c.ok || got != c.want {
+			t.Errorf("?%s with Accept %q: %q, %v, want %q", c.query, c.accept, got, err, c.want)
+		}
+	}
+}
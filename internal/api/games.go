@@ -0,0 +1,149 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"teamacedia/minestalker/internal/analytics"
+	"teamacedia/minestalker/internal/db"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// The game list shows a week of trend and three top servers per game, a
+// single game 30 days (up to a year) and ten. Both are cached for a minute.
+const (
+	gameListTrendDays    = 7
+	gameListTopServers   = 3
+	defaultGameDays      = 30
+	maxGameDays          = 365
+	gameDetailTopServers = 10
+	gamesTTL             = time.Minute
+)
+
+var (
+	gameListCache   = newTTLCache[GameListResponse](gamesTTL)
+	gameDetailCache = newTTLCache[GameResponse](gamesTTL)
+)
+
+// GameListHandler lists every game reported by a server
+// GET /api/v1/games
+func GameListHandler(w http.ResponseWriter, r *http.Request) {
+	resp, err := gameListCache.get("", computeGameList)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error computing games: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GameHandler serves the details of one game
+// GET /api/v1/games/{id}?days=
+func GameHandler(w http.ResponseWriter, r *http.Request) {
+	game := strings.ToLower(r.PathValue("id"))
+
+	days := defaultGameDays
+	if d, err := queryInt(r, "days"); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	} else if d != nil {
+		if *d < 1 || *d > maxGameDays {
+			writeError(w, http.StatusBadRequest, codeBadRequest, paramError{"days", "must be between 1 and " + strconv.Itoa(maxGameDays)}.Error())
+			return
+		}
+		days = *d
+	}
+
+	resp, err := gameDetailCache.get(game+"/"+strconv.Itoa(days), func() (GameResponse, error) {
+		return computeGame(game, days)
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error computing game: "+err.Error())
+		return
+	}
+	if resp.ServersTotal == 0 {
+		writeError(w, http.StatusNotFound, codeNotFound, "No server has reported game "+game)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func computeGameList() (GameListResponse, error) {
+	counts, err := db.GetGameCounts()
+	if err != nil {
+		return GameListResponse{}, err
+	}
+	trends, err := db.GetGameTrends(trendStart(gameListTrendDays), "")
+	if err != nil {
+		return GameListResponse{}, err
+	}
+	online, err := onlineServers("")
+	if err != nil {
+		return GameListResponse{}, err
+	}
+
+	totals := map[string]analytics.GameTotal{}
+	for _, total := range analytics.GameTotals(online) {
+		totals[total.Game] = total
+	}
+	byGame := map[string][]models.ServerStatus{}
+	for _, server := range online {
+		game := strings.ToLower(server.Game)
+		if len(byGame[game]) < gameListTopServers {
+			byGame[game] = append(byGame[game], server)
+		}
+	}
+
+	resp := GameListResponse{Games: make([]GameResponse, 0, len(counts))}
+	for _, c := range counts {
+		resp.Games = append(resp.Games, newGameResponse(c, totals[c.Game], byGame[c.Game], trends[c.Game]))
+	}
+	return resp, nil
+}
+
+func computeGame(game string, days int) (GameResponse, error) {
+	counts, err := db.GetGameCounts()
+	if err != nil {
+		return GameResponse{}, err
+	}
+	var count models.GameCounts
+	for _, c := range counts {
+		if c.Game == game {
+			count = c
+		}
+	}
+	if count.ServersTotal == 0 {
+		return GameResponse{Game: game}, nil
+	}
+
+	trends, err := db.GetGameTrends(trendStart(days), game)
+	if err != nil {
+		return GameResponse{}, err
+	}
+	online, err := onlineServers(game)
+	if err != nil {
+		return GameResponse{}, err
+	}
+
+	var total analytics.GameTotal
+	if totals := analytics.GameTotals(online); len(totals) > 0 {
+		total = totals[0]
+	}
+	top := online[:min(gameDetailTopServers, len(online))]
+	return newGameResponse(count, total, top, trends[game]), nil
+}
+
+// onlineServers lists the online servers of a game, or of every game, most
+// players first.
+func onlineServers(game string) ([]models.ServerStatus, error) {
+	online := true
+	servers, _, err := db.ListServers(db.ServerFilter{Online: &online, Game: game, Sort: "players", Descending: true})
+	return servers, err
+}
+
+// trendStart is midnight UTC days-1 days ago, so a trend covers days whole days
+// including today.
+func trendStart(days int) time.Time {
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day()-(days-1), 0, 0, 0, 0, time.UTC)
+}
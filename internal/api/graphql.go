@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"teamacedia/minestalker/internal/graph"
+)
+
+// graphQLRequest is the body of GraphQLHandler.
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// GraphQLHandler runs a GraphQL query, see internal/graph for the schema.
+// Query errors are reported in the errors of a 200 response as GraphQL
+// clients expect
+// POST /api/v1/graphql with {"query": ..., "operationName": ..., "variables": {...}}
+func GraphQLHandler(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid JSON body")
+		return
+	}
+	if req.Query == "" {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Missing query")
+		return
+	}
+
+	result := graph.Exec(r.Context(), req.Query, req.OperationName, req.Variables)
+
+	resp := GraphQLResponse{}
+	if result.Data != nil {
+		resp.Data = result.Data
+	}
+	for _, err := range result.Errors {
+		e := GraphQLError{Message: err.Message, Path: err.Path}
+		for _, loc := range err.Locations {
+			e.Locations = append(e.Locations, GraphQLLocation{Line: loc.Line, Column: loc.Column})
+		}
+		resp.Errors = append(resp.Errors, e)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
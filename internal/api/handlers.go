@@ -3,83 +3,152 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
-	"strings"
 	"teamacedia/minestalker/internal/db"
+	"teamacedia/minestalker/internal/models"
 )
 
 // PlayerHistoryHandler serves player history by name
+// GET /api/v1/player/{name}?format=
 func PlayerHistoryHandler(w http.ResponseWriter, r *http.Request) {
-	// Extract player name from the URL path
-	// Expecting: /api/player/<name>
-	parts := strings.Split(r.URL.Path, "/")
-	if len(parts) < 4 || parts[3] == "" {
-		http.Error(w, "Missing player name in URL", http.StatusBadRequest)
+	playerName := r.PathValue("name")
+	format, err := negotiateFormat(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
 		return
 	}
-	playerName := parts[3]
 
-	// Query DB for player history
-	history, err := db.GetPlayerHistory(playerName)
-	if err != nil {
-		http.Error(w, "Error retrieving player history: "+err.Error(), http.StatusInternalServerError)
+	if format != formatJSON {
+		rw := newRowWriter(w, format, PlayerSightingRow{})
+		err := db.EachPlayerSighting(playerName, func(sighting models.PlayerSighting) error {
+			return rw.write(PlayerSightingRow{Player: playerName, PlayerSightingResponse: newPlayerSightingResponse(sighting)})
+		})
+		finishRows(w, r, rw, err, "Error retrieving player history")
 		return
 	}
 
-	// Serialize and write JSON response
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(history)
+	// Query DB for player history
+	history, err := db.GetPlayerHistory(playerName)
 	if err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving player history: "+err.Error())
 		return
 	}
+
+	writeJSON(w, http.StatusOK, newPlayerHistoryResponse(playerName, history))
 }
 
 // ServerHistoryHandler serves server connection history by server address and port
+// GET /api/v1/server/{ip}/{port}?format=
 func ServerHistoryHandler(w http.ResponseWriter, r *http.Request) {
-	parts := strings.Split(r.URL.Path, "/")
-	if len(parts) < 5 || parts[3] == "" || parts[4] == "" {
-		http.Error(w, "Missing server address or port in URL", http.StatusBadRequest)
+	serverAddress, serverPort, ok := serverFromPath(w, r)
+	if !ok {
 		return
 	}
-	serverAddress := parts[3]
-	portStr := parts[4]
-
-	serverPort, err := strconv.Atoi(portStr)
+	format, err := negotiateFormat(r)
 	if err != nil {
-		http.Error(w, "Invalid server port", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	if format != formatJSON {
+		rw := newRowWriter(w, format, SnapshotServerRow{})
+		err := db.EachServerSnapshot(serverAddress, serverPort, func(snapshot models.Snapshot) error {
+			return writeSnapshotRows(rw, snapshot)
+		})
+		finishRows(w, r, rw, err, "Error retrieving snapshot history")
 		return
 	}
 
 	// Query DB for snapshot history of the server
 	snapshotHistory, err := db.GetSnapshotHistoryForServer(serverAddress, serverPort)
 	if err != nil {
-		http.Error(w, "Error retrieving snapshot history: "+err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving snapshot history: "+err.Error())
 		return
 	}
 
-	// Serialize and write JSON response
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(snapshotHistory); err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
-	}
+	writeJSON(w, http.StatusOK, newServerHistoryResponse(serverAddress, serverPort, snapshotHistory))
 }
 
+// SnapshotHandler serves the latest snapshot of the server list, or the latest
+// one taken at or before the given time
+// GET /api/v1/snapshot?at=&format=
 func SnapshotHandler(w http.ResponseWriter, r *http.Request) {
-	// Query DB for latest server snapshot
-	snapshot, err := db.GetLatestSnapshot()
+	at, err := queryTime(r, "at")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	format, err := negotiateFormat(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	// Query DB for the requested server snapshot
+	var snapshot models.Snapshot
+	if at != nil {
+		snapshot, err = db.GetSnapshotByTime(*at)
+	} else {
+		snapshot, err = db.GetLatestSnapshot()
+	}
+	if errors.Is(err, db.ErrNotFound) && at != nil {
+		writeError(w, http.StatusNotFound, codeNotFound, "No snapshot had been taken by "+formatTime(*at))
+		return
+	}
+	if errors.Is(err, db.ErrNotFound) {
+		writeError(w, http.StatusNotFound, codeNotFound, "No snapshot has been taken yet")
+		return
+	}
 	if err != nil {
-		http.Error(w, "Error retrieving latest snapshot: "+err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving snapshot: "+err.Error())
+		return
+	}
+
+	if format != formatJSON {
+		rw := newRowWriter(w, format, SnapshotServerRow{})
+		finishRows(w, r, rw, writeSnapshotRows(rw, snapshot), "Error writing snapshot")
 		return
 	}
 
-	// Serialize and write JSON response
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	writeJSON(w, http.StatusOK, newSnapshotResponse(snapshot))
+}
+
+func writeSnapshotRows(rw *rowWriter, snapshot models.Snapshot) error {
+	for _, server := range snapshot.Servers {
+		err := rw.write(SnapshotServerRow{Time: formatTime(snapshot.Time), ServerResponse: newServerResponse(server)})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finishRows completes a CSV or NDJSON export. An error before the first row
+// is answered as usual, after it the response can only be cut short.
+func finishRows(w http.ResponseWriter, r *http.Request, rw *rowWriter, err error, message string) {
+	switch {
+	case err != nil && !rw.started:
+		writeError(w, http.StatusInternalServerError, codeInternal, message+": "+err.Error())
+	case err != nil:
+		log.Printf("Export of %s aborted: %v", r.URL.Path, err)
+	default:
+		rw.close()
+	}
+}
+
+// serverFromPath reads the {ip} and {port} path parameters, answering a 400
+// itself when the port is not a number.
+func serverFromPath(w http.ResponseWriter, r *http.Request) (string, int, bool) {
+	port, err := strconv.Atoi(r.PathValue("port"))
+	if err != nil || port < 1 || port > 65535 {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid server port")
+		return "", 0, false
 	}
+	return r.PathValue("ip"), port, true
 }
 
 func SendWebhook(webhookURL, message, username string) error {
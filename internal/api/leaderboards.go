@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"teamacedia/minestalker/internal/db"
+	"time"
+)
+
+// Leaderboards list the top ten entries unless asked for more.
+const (
+	defaultLeaderboardLimit = 10
+	maxLeaderboardLimit     = 100
+)
+
+// leaderboards are the boards returned when none is picked, in the order of
+// the README.
+var leaderboards = []string{db.BoardPlaytime, db.BoardServersVisited, db.BoardPeakPlayers, db.BoardUptimeStreak}
+
+// LeaderboardsHandler serves ranked players and servers over a window
+// GET /api/v1/leaderboards?window=&board=&game=&server=&limit=
+func LeaderboardsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := db.LeaderboardFilter{Game: query.Get("game"), Limit: defaultLeaderboardLimit}
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	window := query.Get("window")
+	switch window {
+	case "day":
+		filter.Since = today
+	case "week":
+		filter.Since = today.AddDate(0, 0, -6)
+	case "month":
+		filter.Since = today.AddDate(0, 0, -29)
+	case "", "all":
+		window = "all"
+	default:
+		writeError(w, http.StatusBadRequest, codeBadRequest, paramError{"window", "expected day, week, month or all"}.Error())
+		return
+	}
+
+	boards := leaderboards
+	if board := query.Get("board"); board != "" {
+		known := false
+		for _, b := range leaderboards {
+			known = known || b == board
+		}
+		if !known {
+			writeError(w, http.StatusBadRequest, codeBadRequest, paramError{"board", "expected playtime, servers_visited, peak_players or uptime_streak"}.Error())
+			return
+		}
+		boards = []string{board}
+	}
+
+	if server := query.Get("server"); server != "" {
+		host, port, err := net.SplitHostPort(server)
+		if err == nil {
+			filter.Port, err = strconv.Atoi(port)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, paramError{"server", "expected address:port"}.Error())
+			return
+		}
+		filter.Address = host
+	}
+
+	if limit, err := queryInt(r, "limit"); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	} else if limit != nil {
+		if *limit < 1 || *limit > maxLeaderboardLimit {
+			writeError(w, http.StatusBadRequest, codeBadRequest, paramError{"limit", "must be between 1 and " + strconv.Itoa(maxLeaderboardLimit)}.Error())
+			return
+		}
+		filter.Limit = *limit
+	}
+
+	resp := LeaderboardsResponse{
+		Window: window,
+		Boards: make(map[string][]LeaderboardEntryResponse, len(boards)),
+	}
+	if !filter.Since.IsZero() {
+		resp.Since = formatOptionalTime(&filter.Since)
+	}
+	for _, board := range boards {
+		entries, err := db.GetLeaderboard(board, filter)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving leaderboard: "+err.Error())
+			return
+		}
+		resp.Boards[board] = newLeaderboardEntryResponses(entries)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"teamacedia/minestalker/internal/db"
+	"time"
+)
+
+// The handlers below serve the deprecated unversioned routes with the payloads
+// of before the v1 response types existed, so existing clients keep receiving
+// what they parse until they migrate. Snapshots are still the models encoded
+// directly; sightings have since gained fields and are frozen below.
+
+// legacyPlayerSighting is models.PlayerSighting as it was encoded before v1.
+type legacyPlayerSighting struct {
+	Address        string
+	Port           int
+	Player         string
+	ConnectedAt    time.Time
+	DisconnectedAt *time.Time
+}
+
+// legacyPlayerHistoryHandler serves GET /api/player/{name}
+func legacyPlayerHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	history, err := db.GetPlayerHistory(r.PathValue("name"))
+	if err != nil {
+		http.Error(w, "Error retrieving player history: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var legacy []legacyPlayerSighting // Unknown players still encode as null
+	for _, sighting := range history {
+		legacy = append(legacy, legacyPlayerSighting{
+			Address:        sighting.Address,
+			Port:           sighting.Port,
+			Player:         sighting.Player,
+			ConnectedAt:    sighting.ConnectedAt,
+			DisconnectedAt: sighting.DisconnectedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(legacy); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// legacyServerHistoryHandler serves GET /api/server/{ip}/{port}
+func legacyServerHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	serverPort, err := strconv.Atoi(r.PathValue("port"))
+	if err != nil {
+		http.Error(w, "Invalid server port", http.StatusBadRequest)
+		return
+	}
+
+	snapshotHistory, err := db.GetSnapshotHistoryForServer(r.PathValue("ip"), serverPort)
+	if err != nil {
+		http.Error(w, "Error retrieving snapshot history: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(snapshotHistory); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// legacySnapshotHandler serves GET /api/snapshot
+func legacySnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := db.GetLatestSnapshot()
+	if err != nil {
+		http.Error(w, "Error retrieving latest snapshot: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"bufio"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"teamacedia/minestalker/internal/metrics"
+	"time"
+)
+
+// unmatchedRoute labels requests no route matched, keeping arbitrary paths
+// out of the metric labels.
+const unmatchedRoute = "unmatched"
+
+// MetricsHandler serves the service metrics in the Prometheus text format
+// GET /metrics
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := metrics.WriteText(w); err != nil {
+		log.Printf("Error writing metrics: %v", err)
+	}
+}
+
+// instrument counts the requests served by handler and times them.
+func instrument(pattern string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		handler(rec, r)
+		observeRequest(r.Method, pattern, rec.status, start)
+	}
+}
+
+// otherMethod labels requests with a method outside the standard ones,
+// keeping arbitrary methods out of the metric labels.
+const otherMethod = "other"
+
+func observeRequest(method, route string, status int, start time.Time) {
+	if status == 0 {
+		status = http.StatusOK // Nothing written, net/http answers 200
+	}
+	method = methodLabel(method)
+	metrics.HTTPRequests.Inc(method, route, strconv.Itoa(status))
+	metrics.HTTPRequestDuration.Since(start, method, route)
+}
+
+// methodLabel returns method if it is a standard HTTP method and otherMethod
+// otherwise.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return otherMethod
+}
+
+// statusRecorder remembers the status written through it. It passes flushes
+// and hijacks on to the underlying writer, event streams and WebSockets need
+// them, and unwraps for http.ResponseController.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusRecorder) FlushError() error {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *statusRecorder) Flush() {
+	w.FlushError()
+}
+
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
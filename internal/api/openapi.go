@@ -0,0 +1,303 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"teamacedia/minestalker/internal/db"
+	"time"
+)
+
+// operation documents one route in the OpenAPI document. Request and
+// response schemas are derived from the Go types by reflection, so they
+// cannot drift from what the handlers encode.
+type operation struct {
+	method      string
+	path        string
+	summary     string
+	description string
+	scope       string // Required API key scope, empty for none
+	params      []parameter
+	body        any    // Zero value of the request body type, nil without body
+	response    any    // Zero value of the response type, nil without body
+	status      int    // Success status, 200 when zero
+	contentType string // Of the response, application/json when empty
+	row         any    // Zero value of the row type of CSV and NDJSON exports, nil without
+	deprecated  bool
+}
+
+type parameter struct {
+	name        string
+	in          string // "path", "query" or "header"
+	schema      map[string]any
+	description string
+}
+
+func pathParam(name, description string) parameter {
+	return parameter{name: name, in: "path", schema: map[string]any{"type": "string"}, description: description}
+}
+
+func queryParam(name, typ, description string) parameter {
+	return parameter{name: name, in: "query", schema: map[string]any{"type": typ}, description: description}
+}
+
+func enumParam(name, description string, values ...string) parameter {
+	return parameter{name: name, in: "query", schema: map[string]any{"type": "string", "enum": values}, description: description}
+}
+
+// specDocument builds the document from the operations once.
+var specDocument = sync.OnceValue(func() []byte {
+	doc, err := json.MarshalIndent(buildSpec(operations), "", "  ")
+	if err != nil {
+		log.Panicf("Failed to encode OpenAPI document: %v", err)
+	}
+	return doc
+})
+
+// OpenAPIHandler serves the OpenAPI 3 document describing the API
+// GET /api/openapi.json
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(specDocument())
+}
+
+// checkSpec lists every route registered on the router that the OpenAPI
+// document does not describe and every documented route that does not exist.
+// NewHandler logs them and openapi_test.go fails on them.
+func checkSpec(rt *Router) []string {
+	documented := map[[2]string]bool{}
+	for _, op := range operations {
+		documented[[2]string{op.method, op.path}] = true
+	}
+
+	var problems []string
+	for _, route := range rt.Routes() {
+		if !documented[route] {
+			problems = append(problems, "route "+route[0]+" "+route[1]+" is missing from the OpenAPI document")
+		}
+		delete(documented, route)
+	}
+	for route := range documented {
+		problems = append(problems, "OpenAPI document describes "+route[0]+" "+route[1]+", which is not routed")
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+func buildSpec(ops []operation) map[string]any {
+	schemas := schemaSet{schemas: map[string]any{}, names: map[reflect.Type]string{}}
+	errorSchema := schemas.of(reflect.TypeOf(errorResponse{}))
+
+	paths := map[string]map[string]any{}
+	for _, op := range ops {
+		item := map[string]any{
+			"summary":     op.summary,
+			"operationId": operationID(op),
+			"tags":        []string{tag(op.path)},
+		}
+		if op.description != "" {
+			item["description"] = op.description
+		}
+		if op.deprecated {
+			item["deprecated"] = true
+		}
+
+		opParams := op.params
+		if op.row != nil {
+			opParams = append(opParams, enumParam("format", "Overrides the Accept header, default json", formatJSON, formatCSV, formatNDJSON))
+		}
+		params := []map[string]any{}
+		for _, p := range opParams {
+			param := map[string]any{"name": p.name, "in": p.in, "schema": p.schema}
+			if p.in == "path" {
+				param["required"] = true
+			}
+			if p.description != "" {
+				param["description"] = p.description
+			}
+			params = append(params, param)
+		}
+		if len(params) > 0 {
+			item["parameters"] = params
+		}
+
+		if op.body != nil {
+			item["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": schemas.of(reflect.TypeOf(op.body))},
+				},
+			}
+		}
+
+		status, contentType := op.status, op.contentType
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		success := map[string]any{"description": http.StatusText(status)}
+		if op.response != nil {
+			content := map[string]any{
+				contentType: map[string]any{"schema": schemas.of(reflect.TypeOf(op.response))},
+			}
+			if op.row != nil {
+				content["text/csv"] = map[string]any{"schema": map[string]any{"type": "string", "description": "One row per " + reflect.TypeOf(op.row).Name()}}
+				content["application/x-ndjson"] = map[string]any{"schema": schemas.of(reflect.TypeOf(op.row))}
+			}
+			success["content"] = content
+		}
+		item["responses"] = map[string]any{
+			strconv.Itoa(status): success,
+			"default": map[string]any{
+				"description": "Error",
+				"content":     map[string]any{"application/json": map[string]any{"schema": errorSchema}},
+			},
+		}
+
+		if op.scope != "" {
+			item["x-required-scope"] = op.scope
+			item["security"] = []map[string][]string{{"bearer": {}}, {"apiKeyHeader": {}}, {"apiKeyQuery": {}}, {}}
+		}
+
+		if paths[op.path] == nil {
+			paths[op.path] = map[string]any{}
+		}
+		paths[op.path][strings.ToLower(op.method)] = item
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "MineStalker API",
+			"version":     "v1",
+			"description": "Player and server tracking for the public Luanti server list. Every operation names the API key scope it requires in x-required-scope; " + db.ScopeReadHistory + " includes " + db.ScopeReadPublic + " and " + db.ScopeAdmin + " includes both. Rate limited responses carry RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas.schemas,
+			"securitySchemes": map[string]any{
+				"bearer":       map[string]any{"type": "http", "scheme": "bearer"},
+				"apiKeyHeader": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"apiKeyQuery":  map[string]any{"type": "apiKey", "in": "query", "name": "api_key"},
+			},
+		},
+	}
+}
+
+// operationID is derived from method and path, e.g. "get_api_v1_player_name_stats".
+func operationID(op operation) string {
+	return strings.ToLower(op.method) + strings.NewReplacer("/", "_", "{", "", "}", "", ".", "_").Replace(op.path)
+}
+
+// tag groups operations by the first path segment after the version.
+func tag(path string) string {
+	segments := splitPath(strings.TrimPrefix(strings.TrimPrefix(path, "/api"), "/v1"))
+	if len(segments) == 0 {
+		return "api"
+	}
+	return segments[0]
+}
+
+// schemaSet turns Go types into JSON schemas the way encoding/json encodes
+// them. Named structs become components referenced by name.
+type schemaSet struct {
+	schemas map[string]any
+	names   map[reflect.Type]string
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (s schemaSet) of(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == reflect.TypeOf(time.Duration(0)):
+		return map[string]any{"type": "integer", "format": "int64", "description": "Nanoseconds"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := map[string]any{}
+		for k, v := range s.of(t.Elem()) {
+			schema[k] = v
+		}
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]any{"allOf": []any{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		name, ok := s.names[t]
+		if !ok {
+			name = strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+			if pkg := t.PkgPath(); !strings.HasSuffix(pkg, "/api") {
+				name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+			}
+			s.names[t] = name
+			s.schemas[name] = s.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+// object describes a struct. Fields without omitempty are always present and
+// therefore required; embedded structs are flattened like encoding/json does.
+func (s schemaSet) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	s.fields(t, properties, &required)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (s schemaSet) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tagValue := field.Tag.Get("json")
+		if tagValue == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tagValue, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			s.fields(field.Type, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.of(field.Type)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
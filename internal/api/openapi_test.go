@@ -0,0 +1,24 @@
+package api
+
+import (
+	"encoding/json"
+	"teamacedia/minestalker/internal/models"
+	"testing"
+)
+
+func TestSpecCoversRoutes(t *testing.T) {
+	rt := NewHandler(&models.Config{}).(*Router)
+	for _, problem := range checkSpec(rt) {
+		t.Errorf("%s", problem)
+	}
+}
+
+func TestSpecDocumentEncodes(t *testing.T) {
+	var doc map[string]any
+	if err := json.Unmarshal(specDocument(), &doc); err != nil {
+		t.Fatalf("OpenAPI document is not JSON: %v", err)
+	}
+	if doc["openapi"] != "3.0.3" {
+		t.Errorf("openapi = %v, want 3.0.3", doc["openapi"])
+	}
+}
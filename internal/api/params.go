@@ -0,0 +1,151 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embedded so time zone parameters work on hosts without a zoneinfo database
+	_ "time/tzdata"
+)
+
+// Pagination defaults for list endpoints.
+const (
+	defaultPerPage = 50
+	maxPerPage     = 500
+)
+
+// paramError is a query parameter that failed to parse. Handlers answer it
+// with a 400 carrying its message.
+type paramError struct {
+	param   string
+	message string
+}
+
+func (e paramError) Error() string {
+	return fmt.Sprintf("Invalid %s: %s", e.param, e.message)
+}
+
+// queryInt reads an optional integer parameter, nil when absent.
+func queryInt(r *http.Request, param string) (*int, error) {
+	raw := r.URL.Query().Get(param)
+	if raw == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, paramError{param, "expected an integer"}
+	}
+	return &n, nil
+}
+
+// queryBool reads an optional boolean parameter, nil when absent.
+func queryBool(r *http.Request, param string) (*bool, error) {
+	raw := r.URL.Query().Get(param)
+	if raw == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, paramError{param, "expected true or false"}
+	}
+	return &b, nil
+}
+
+// queryTime reads an optional time parameter given as RFC 3339 or as a plain
+// date, which means midnight UTC. It is nil when absent.
+func queryTime(r *http.Request, param string) (*time.Time, error) {
+	raw := r.URL.Query().Get(param)
+	if raw == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+		return &t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, raw); err == nil {
+		return &t, nil
+	}
+	return nil, paramError{param, "expected an RFC 3339 time or a YYYY-MM-DD date"}
+}
+
+// queryRange reads the from and to parameters of an endpoint working on a
+// span of time. to defaults to now and from to length before to.
+func queryRange(r *http.Request, length time.Duration) (from, to time.Time, err error) {
+	to = time.Now().UTC()
+	if t, err := queryTime(r, "to"); err != nil {
+		return from, to, err
+	} else if t != nil {
+		to = t.UTC()
+	}
+	from = to.Add(-length)
+	if t, err := queryTime(r, "from"); err != nil {
+		return from, to, err
+	} else if t != nil {
+		from = t.UTC()
+	}
+	if !from.Before(to) {
+		return from, to, paramError{"from", "must be before to"}
+	}
+	return from, to, nil
+}
+
+// queryDuration reads an optional duration parameter such as "15m", "1h" or
+// "7d", nil when absent. Days are always 24 hours.
+func queryDuration(r *http.Request, param string) (*time.Duration, error) {
+	raw := r.URL.Query().Get(param)
+	if raw == "" {
+		return nil, nil
+	}
+
+	var d time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(raw)
+	}
+	if err != nil || d <= 0 {
+		return nil, paramError{param, "expected a positive duration such as 15m, 1h or 7d"}
+	}
+	return &d, nil
+}
+
+// queryLocation reads an optional IANA time zone parameter such as
+// "Europe/Berlin", UTC when absent.
+func queryLocation(r *http.Request, param string) (*time.Location, error) {
+	raw := r.URL.Query().Get(param)
+	if raw == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(raw)
+	if err != nil {
+		return nil, paramError{param, "unknown time zone " + strconv.Quote(raw)}
+	}
+	return loc, nil
+}
+
+// queryPage reads the page and per_page parameters. Pages start at 1.
+func queryPage(r *http.Request) (page, perPage int, err error) {
+	page, perPage = 1, defaultPerPage
+	if p, err := queryInt(r, "page"); err != nil {
+		return 0, 0, err
+	} else if p != nil {
+		if *p < 1 {
+			return 0, 0, paramError{"page", "must be at least 1"}
+		}
+		page = *p
+	}
+	if p, err := queryInt(r, "per_page"); err != nil {
+		return 0, 0, err
+	} else if p != nil {
+		if *p < 1 || *p > maxPerPage {
+			return 0, 0, paramError{"per_page", fmt.Sprintf("must be between 1 and %d", maxPerPage)}
+		}
+		perPage = *p
+	}
+	return page, perPage, nil
+}
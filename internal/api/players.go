@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"teamacedia/minestalker/internal/analytics"
+	"teamacedia/minestalker/internal/db"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// defaultStatsRange is the window player stats and companions cover when only
+// one of from and to is given. Without either they cover all recorded history.
+const defaultStatsRange = 30 * 24 * time.Hour
+
+// PlayerStatsHandler serves playtime and activity statistics of a player
+// GET /api/v1/player/{name}/stats?tz=&from=&to=
+func PlayerStatsHandler(w http.ResponseWriter, r *http.Request) {
+	playerName := r.PathValue("name")
+
+	loc, err := queryLocation(r, "tz")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	var from, to time.Time
+	var history []models.PlayerSighting
+	if r.URL.Query().Has("from") || r.URL.Query().Has("to") {
+		from, to, err = queryRange(r, defaultStatsRange)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+		history, err = db.GetPlayerHistoryBetween(playerName, from, to)
+	} else {
+		history, err = db.GetPlayerHistory(playerName)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving player history: "+err.Error())
+		return
+	}
+
+	stats := analytics.PlayerActivity(history, time.Now().UTC(), loc, from, to)
+	writeJSON(w, http.StatusOK, newPlayerStatsResponse(playerName, loc, from, to, stats))
+}
+
+// PlayerCompanionsHandler ranks the players who played alongside a player
+// GET /api/v1/player/{name}/companions?from=&to=&page=&per_page=
+func PlayerCompanionsHandler(w http.ResponseWriter, r *http.Request) {
+	playerName := r.PathValue("name")
+
+	var from time.Time
+	to := time.Now().UTC()
+	var err error
+	if r.URL.Query().Has("from") || r.URL.Query().Has("to") {
+		from, to, err = queryRange(r, defaultStatsRange)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+	}
+	page, perPage, err := queryPage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	companions, total, err := db.GetCompanions(playerName, from, to, perPage, (page-1)*perPage)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving companions: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newCompanionsResponse(playerName, companions, page, perPage, total))
+}
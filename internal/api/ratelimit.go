@@ -0,0 +1,78 @@
+package api
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// rateWindow is the period rate limits are expressed in: a limit of n allows
+// bursts of n requests and refills n requests per window.
+const rateWindow = time.Minute
+
+// rateLimiter keeps a token bucket per client key, e.g. "key:3" or "ip:1.2.3.4".
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimit is the outcome of taking a token, as reported in the headers.
+type rateLimit struct {
+	allowed   bool
+	limit     int
+	remaining int
+	reset     time.Duration // Until the bucket is full again
+	retry     time.Duration // Until the next request is allowed, when it is not
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: map[string]*bucket{}}
+}
+
+// take spends a token of the bucket of key, which holds limit tokens.
+func (l *rateLimiter) take(key string, limit int, now time.Time) rateLimit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	perToken := rateWindow / time.Duration(limit)
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit), b.tokens+float64(now.Sub(b.last))/float64(perToken))
+	b.last = now
+
+	res := rateLimit{limit: limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.allowed = true
+	} else {
+		res.retry = time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	res.remaining = int(b.tokens)
+	res.reset = time.Duration((float64(limit) - b.tokens) * float64(perToken))
+	return res
+}
+
+// sweep drops the buckets that have refilled completely once per window, they
+// behave exactly like new ones.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateWindow {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= rateWindow {
+			delete(l.buckets, key)
+		}
+	}
+}
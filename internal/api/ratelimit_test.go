@@ -0,0 +1,58 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	start := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	// A limit of 3 per minute refills a token every 20 seconds
+	steps := []struct {
+		at        time.Duration
+		key       string
+		allowed   bool
+		remaining int
+		reset     time.Duration
+		retry     time.Duration
+	}{
+		{0, "a", true, 2, 20 * time.Second, 0},
+		{0, "a", true, 1, 40 * time.Second, 0},
+		{0, "a", true, 0, time.Minute, 0},
+		{0, "a", false, 0, time.Minute, 20 * time.Second},
+		{0, "b", true, 2, 20 * time.Second, 0}, // Buckets are per key
+		{10 * time.Second, "a", false, 0, 50 * time.Second, 10 * time.Second},
+		{20 * time.Second, "a", true, 0, time.Minute, 0},
+		{50 * time.Second, "a", true, 0, 50 * time.Second, 0},
+		// Idle for longer than the window, the bucket is full again
+		{5 * time.Minute, "a", true, 2, 20 * time.Second, 0},
+	}
+
+	l := newRateLimiter()
+	for i, s := range steps {
+		got := l.take(s.key, 3, start.Add(s.at))
+		if got.allowed != s.allowed || got.remaining != s.remaining || got.limit != 3 {
+			t.Errorf("step %d: allowed %v with %d of %d left, want %v with %d of 3",
+				i, got.allowed, got.remaining, got.limit, s.allowed, s.remaining)
+		}
+		if got.reset.Round(time.Millisecond) != s.reset || got.retry.Round(time.Millisecond) != s.retry {
+			t.Errorf("step %d: reset in %v and retry in %v, want %v and %v", i, got.reset, got.retry, s.reset, s.retry)
+		}
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	start := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	l := newRateLimiter()
+	l.take("a", 60, start)
+	l.take("b", 60, start.Add(45*time.Second))
+
+	l.take("c", 60, start.Add(90*time.Second))
+	if _, ok := l.buckets["a"]; ok {
+		t.Error("bucket idle for a whole window kept")
+	}
+	if _, ok := l.buckets["b"]; !ok {
+		t.Error("bucket used within the window dropped")
+	}
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Router matches requests on method and path. Patterns are slash separated
+// and a "{name}" segment matches any single non-empty segment, readable in the
+// handler through r.PathValue("name"). Unknown paths get a 404 and known paths
+// requested with the wrong method a 405, both as JSON errors. Every request
+// is counted and timed by route, see metrics.go.
+type Router struct {
+	routes []route
+}
+
+type route struct {
+	method   string
+	pattern  string
+	segments []string
+	handler  http.HandlerFunc
+}
+
+// NewRouter returns an empty Router.
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Handle registers handler for method and pattern, e.g. "GET" and
+// "/api/v1/player/{name}". GET routes also answer HEAD requests.
+func (rt *Router) Handle(method, pattern string, handler http.HandlerFunc) {
+	rt.routes = append(rt.routes, route{
+		method:   method,
+		pattern:  pattern,
+		segments: splitPath(pattern),
+		handler:  instrument(pattern, handler),
+	})
+}
+
+// Routes returns the method and pattern of every registered route.
+func (rt *Router) Routes() [][2]string {
+	routes := make([][2]string, len(rt.routes))
+	for i, r := range rt.routes {
+		routes[i] = [2]string{r.method, r.pattern}
+	}
+	return routes
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	segments := splitPath(r.URL.Path)
+
+	var allowed []string
+	for _, route := range rt.routes {
+		params, ok := route.match(segments)
+		if !ok {
+			continue
+		}
+		if route.method != r.Method && !(route.method == http.MethodGet && r.Method == http.MethodHead) {
+			allowed = append(allowed, route.method)
+			if route.method == http.MethodGet {
+				allowed = append(allowed, http.MethodHead)
+			}
+			continue
+		}
+
+		for name, value := range params {
+			r.SetPathValue(name, value)
+		}
+		route.handler(w, r)
+		return
+	}
+
+	if len(allowed) > 0 {
+		sort.Strings(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method "+r.Method+" is not allowed on this route")
+		observeRequest(r.Method, unmatchedRoute, http.StatusMethodNotAllowed, start)
+		return
+	}
+	writeError(w, http.StatusNotFound, codeNotFound, "No route for "+r.URL.Path)
+	observeRequest(r.Method, unmatchedRoute, http.StatusNotFound, start)
+}
+
+func (route route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(route.segments) {
+		return nil, false
+	}
+
+	var params map[string]string
+	for i, seg := range route.segments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = map[string]string{}
+			}
+			params[seg[1:len(seg)-1]] = segments[i]
+			continue
+		}
+		if seg != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// splitPath splits a URL path into segments, ignoring a trailing slash.
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// deprecated marks a handler as a deprecated alias of its /api/v1 successor.
+func deprecated(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		successor := "/api/v1/" + strings.TrimPrefix(r.URL.Path, "/api/")
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+">; rel=\"successor-version\"")
+		handler(w, r)
+	}
+}
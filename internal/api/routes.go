@@ -0,0 +1,73 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"teamacedia/minestalker/internal/db"
+	"teamacedia/minestalker/internal/models"
+)
+
+// NewHandler builds the HTTP API. Every endpoint lives under /api/v1, the
+// unversioned paths served before versioning remain as deprecated aliases.
+// Each route requires a scope, see auth.go.
+func NewHandler(cfg *models.Config) http.Handler {
+	rt := NewRouter()
+	auth := newAuth(cfg)
+	public := func(h http.HandlerFunc) http.HandlerFunc {
+		return auth.require(db.ScopeReadPublic, h)
+	}
+	history := func(h http.HandlerFunc) http.HandlerFunc {
+		return auth.require(db.ScopeReadHistory, h)
+	}
+	admin := func(h http.HandlerFunc) http.HandlerFunc {
+		return auth.require(db.ScopeAdmin, h)
+	}
+
+	rt.Handle(http.MethodGet, "/api/openapi.json", OpenAPIHandler)
+	rt.Handle(http.MethodGet, "/metrics", public(MetricsHandler))
+	rt.Handle(http.MethodGet, "/api/v1/player/{name}", history(PlayerHistoryHandler))
+	rt.Handle(http.MethodGet, "/api/v1/player/{name}/stats", history(PlayerStatsHandler))
+	rt.Handle(http.MethodGet, "/api/v1/player/{name}/companions", history(PlayerCompanionsHandler))
+	rt.Handle(http.MethodGet, "/api/v1/server/{ip}/{port}", history(ServerHistoryHandler))
+	rt.Handle(http.MethodGet, "/api/v1/server/{ip}/{port}/uptime", public(ServerUptimeHandler))
+	rt.Handle(http.MethodGet, "/api/v1/server/{ip}/{port}/population", public(ServerPopulationHandler))
+	rt.Handle(http.MethodGet, "/api/v1/server/{ip}/{port}/roster", history(ServerRosterHandler))
+	rt.Handle(http.MethodGet, "/api/v1/snapshot", public(SnapshotHandler))
+	rt.Handle(http.MethodGet, "/api/v1/snapshot/diff", history(SnapshotDiffHandler))
+	rt.Handle(http.MethodGet, "/api/v1/snapshots", public(SnapshotListHandler))
+	rt.Handle(http.MethodGet, "/api/v1/stats/global", public(GlobalStatsHandler))
+	rt.Handle(http.MethodGet, "/api/v1/leaderboards", public(LeaderboardsHandler))
+	rt.Handle(http.MethodGet, "/api/v1/games", public(GameListHandler))
+	rt.Handle(http.MethodGet, "/api/v1/games/{id}", public(GameHandler))
+	rt.Handle(http.MethodGet, "/api/v1/events/stream", history(EventStreamHandler))
+	rt.Handle(http.MethodGet, "/api/v1/ws", history(WebSocketHandler))
+	rt.Handle(http.MethodGet, "/api/v1/servers", public(ServerListHandler))
+	rt.Handle(http.MethodPost, "/api/v1/graphql", history(GraphQLHandler))
+
+	rt.Handle(http.MethodGet, "/api/v1/admin/optout", admin(ListOptOutsHandler))
+	rt.Handle(http.MethodPost, "/api/v1/admin/optout", admin(AddOptOutHandler))
+	rt.Handle(http.MethodDelete, "/api/v1/admin/optout/{name}", admin(RemoveOptOutHandler))
+	rt.Handle(http.MethodGet, "/api/v1/admin/erasures", admin(ListErasuresHandler))
+	rt.Handle(http.MethodPost, "/api/v1/admin/erasures", admin(ErasePlayerHandler))
+	rt.Handle(http.MethodGet, "/api/v1/admin/webhooks", admin(ListWebhooksHandler))
+	rt.Handle(http.MethodPost, "/api/v1/admin/webhooks", admin(CreateWebhookHandler))
+	rt.Handle(http.MethodGet, "/api/v1/admin/webhooks/{id}", admin(WebhookHandler))
+	rt.Handle(http.MethodPatch, "/api/v1/admin/webhooks/{id}", admin(UpdateWebhookHandler))
+	rt.Handle(http.MethodDelete, "/api/v1/admin/webhooks/{id}", admin(DeleteWebhookHandler))
+	rt.Handle(http.MethodGet, "/api/v1/admin/webhooks/{id}/deliveries", admin(WebhookDeliveriesHandler))
+
+	// Deprecated aliases, the public ones keep their pre-v1 payloads
+	rt.Handle(http.MethodGet, "/api/player/{name}", deprecated(history(legacyPlayerHistoryHandler)))
+	rt.Handle(http.MethodGet, "/api/server/{ip}/{port}", deprecated(history(legacyServerHistoryHandler)))
+	rt.Handle(http.MethodGet, "/api/snapshot", deprecated(public(legacySnapshotHandler)))
+	rt.Handle(http.MethodGet, "/api/admin/optout", deprecated(admin(ListOptOutsHandler)))
+	rt.Handle(http.MethodPost, "/api/admin/optout", deprecated(admin(AddOptOutHandler)))
+	rt.Handle(http.MethodDelete, "/api/admin/optout/{name}", deprecated(admin(RemoveOptOutHandler)))
+	rt.Handle(http.MethodGet, "/api/admin/erasures", deprecated(admin(ListErasuresHandler)))
+	rt.Handle(http.MethodPost, "/api/admin/erasures", deprecated(admin(ErasePlayerHandler)))
+
+	for _, problem := range checkSpec(rt) {
+		log.Printf("OpenAPI: %s", problem)
+	}
+	return rt
+}
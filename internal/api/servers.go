@@ -0,0 +1,248 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"teamacedia/minestalker/internal/analytics"
+	"teamacedia/minestalker/internal/db"
+	"time"
+)
+
+// Population buckets default to hourly over the last day. The bucket count is
+// capped to keep responses chart sized.
+const (
+	defaultPopulationRange      = 24 * time.Hour
+	defaultPopulationResolution = time.Hour
+	maxPopulationBuckets        = 2000
+)
+
+// defaultRosterRange is the window of a roster given only one of from and to.
+const defaultRosterRange = time.Hour
+
+// uptimeWindows are the windows reported by the uptime endpoint, shortest
+// first. Outages are listed for the last, longest one.
+var uptimeWindows = []struct {
+	name   string
+	length time.Duration
+}{
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+}
+
+// ServerListHandler serves the directory of every server ever seen
+// GET /api/v1/servers?online=&game=&name=&min_players=&max_players=&first_seen_after=&sort=&order=&page=&per_page=
+func ServerListHandler(w http.ResponseWriter, r *http.Request) {
+	filter, page, perPage, err := serverFilterFromQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	servers, total, err := db.ListServers(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving servers: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newServerListResponse(servers, page, perPage, total))
+}
+
+func serverFilterFromQuery(r *http.Request) (db.ServerFilter, int, int, error) {
+	query := r.URL.Query()
+	filter := db.ServerFilter{
+		Game:         query.Get("game"),
+		NameContains: query.Get("name"),
+		Sort:         query.Get("sort"),
+	}
+
+	var err error
+	if filter.Online, err = queryBool(r, "online"); err != nil {
+		return filter, 0, 0, err
+	}
+	if filter.MinPlayers, err = queryInt(r, "min_players"); err != nil {
+		return filter, 0, 0, err
+	}
+	if filter.MaxPlayers, err = queryInt(r, "max_players"); err != nil {
+		return filter, 0, 0, err
+	}
+	if filter.FirstSeenAfter, err = queryTime(r, "first_seen_after"); err != nil {
+		return filter, 0, 0, err
+	}
+
+	switch filter.Sort {
+	case "":
+		filter.Sort = "players"
+	case "players", "uptime", "name", "last_seen":
+	default:
+		return filter, 0, 0, paramError{"sort", "expected players, uptime, name or last_seen"}
+	}
+
+	// Names read best A to Z, everything else biggest first
+	switch query.Get("order") {
+	case "":
+		filter.Descending = filter.Sort != "name"
+	case "asc":
+	case "desc":
+		filter.Descending = true
+	default:
+		return filter, 0, 0, paramError{"order", "expected asc or desc"}
+	}
+
+	page, perPage, err := queryPage(r)
+	if err != nil {
+		return filter, 0, 0, err
+	}
+	filter.Limit = perPage
+	filter.Offset = (page - 1) * perPage
+
+	return filter, page, perPage, nil
+}
+
+// ServerUptimeHandler serves the availability of a server over the last 24
+// hours, 7 days and 30 days
+// GET /api/v1/server/{ip}/{port}/uptime
+func ServerUptimeHandler(w http.ResponseWriter, r *http.Request) {
+	serverAddress, serverPort, ok := knownServerFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	from := now.Add(-uptimeWindows[len(uptimeWindows)-1].length)
+
+	sightings, err := db.GetServerHistoryBetween(serverAddress, serverPort, from, now)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving server history: "+err.Error())
+		return
+	}
+	coverage, err := db.GetScrapeCoverage(from, now)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving scrape coverage: "+err.Error())
+		return
+	}
+
+	resp := ServerUptimeResponse{
+		Address: serverAddress,
+		Port:    serverPort,
+		Windows: make([]UptimeWindowResponse, 0, len(uptimeWindows)),
+	}
+	for _, window := range uptimeWindows {
+		report := analytics.Uptime(sightings, coverage, now.Add(-window.length), now)
+		resp.Windows = append(resp.Windows, newUptimeWindowResponse(window.name, report))
+		resp.Outages = newOutageResponses(report.Outages)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// knownServerFromPath works like serverFromPath and also answers a 404 itself
+// when the server has never been seen.
+func knownServerFromPath(w http.ResponseWriter, r *http.Request) (string, int, bool) {
+	serverAddress, serverPort, ok := serverFromPath(w, r)
+	if !ok {
+		return "", 0, false
+	}
+
+	_, err := db.GetServerInfo(serverAddress, serverPort)
+	if errors.Is(err, db.ErrNotFound) {
+		writeError(w, http.StatusNotFound, codeNotFound, "Server has never been seen")
+		return "", 0, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving server: "+err.Error())
+		return "", 0, false
+	}
+	return serverAddress, serverPort, true
+}
+
+// ServerPopulationHandler serves the player count of a server bucketed over time
+// GET /api/v1/server/{ip}/{port}/population?from=&to=&resolution=
+func ServerPopulationHandler(w http.ResponseWriter, r *http.Request) {
+	serverAddress, serverPort, ok := knownServerFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	from, to, err := queryRange(r, defaultPopulationRange)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	resolution := defaultPopulationResolution
+	if d, err := queryDuration(r, "resolution"); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	} else if d != nil {
+		resolution = *d
+	}
+	if to.Sub(from)/resolution >= maxPopulationBuckets {
+		writeError(w, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("Range holds more than %d buckets, use a coarser resolution", maxPopulationBuckets))
+		return
+	}
+
+	counts, err := db.GetServerPlayerCounts(serverAddress, serverPort, from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving player counts: "+err.Error())
+		return
+	}
+	sightings, err := db.GetServerPlayerSightingsBetween(serverAddress, serverPort, from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving player sightings: "+err.Error())
+		return
+	}
+
+	buckets := analytics.Population(counts, sightings, from, to, resolution)
+	writeJSON(w, http.StatusOK, newServerPopulationResponse(serverAddress, serverPort, from, to, resolution, buckets))
+}
+
+// ServerRosterHandler serves who was online on a server, either at one instant
+// (default now) or at any point of a window
+// GET /api/v1/server/{ip}/{port}/roster?at=
+// GET /api/v1/server/{ip}/{port}/roster?from=&to=
+func ServerRosterHandler(w http.ResponseWriter, r *http.Request) {
+	serverAddress, serverPort, ok := knownServerFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Has("at") && (query.Has("from") || query.Has("to")) {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Use either at or from and to, not both")
+		return
+	}
+
+	if query.Has("from") || query.Has("to") {
+		from, to, err := queryRange(r, defaultRosterRange)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+		sightings, err := db.GetServerPlayerSightingsBetween(serverAddress, serverPort, from, to)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving roster: "+err.Error())
+			return
+		}
+		resp := newRosterResponse(serverAddress, serverPort, sightings)
+		resp.From, resp.To = formatOptionalTime(&from), formatOptionalTime(&to)
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	at := time.Now().UTC()
+	if t, err := queryTime(r, "at"); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	} else if t != nil {
+		at = t.UTC()
+	}
+	sightings, err := db.GetServerRosterAt(serverAddress, serverPort, at)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving roster: "+err.Error())
+		return
+	}
+	resp := newRosterResponse(serverAddress, serverPort, sightings)
+	resp.At = formatOptionalTime(&at)
+	writeJSON(w, http.StatusOK, resp)
+}
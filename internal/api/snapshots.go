@@ -0,0 +1,71 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"teamacedia/minestalker/internal/analytics"
+	"teamacedia/minestalker/internal/db"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// defaultSnapshotListRange is the window of the snapshot list without from and to.
+const defaultSnapshotListRange = 24 * time.Hour
+
+// SnapshotListHandler lists the times snapshots were taken, oldest first
+// GET /api/v1/snapshots?from=&to=
+func SnapshotListHandler(w http.ResponseWriter, r *http.Request) {
+	from, to, err := queryRange(r, defaultSnapshotListRange)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	times, err := db.GetSnapshotTimes(from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving snapshot times: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newSnapshotListResponse(from, to, times))
+}
+
+// SnapshotDiffHandler compares the snapshots in effect at two times, the
+// latest taken at or before each
+// GET /api/v1/snapshot/diff?from=&to=
+func SnapshotDiffHandler(w http.ResponseWriter, r *http.Request) {
+	if !r.URL.Query().Has("from") {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Missing from")
+		return
+	}
+	from, to, err := queryRange(r, 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	before, ok := snapshotAt(w, from)
+	if !ok {
+		return
+	}
+	after, ok := snapshotAt(w, to)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newSnapshotDiffResponse(before.Time, after.Time, analytics.DiffSnapshots(before, after)))
+}
+
+// snapshotAt loads the snapshot in effect at t, answering errors itself.
+func snapshotAt(w http.ResponseWriter, t time.Time) (models.Snapshot, bool) {
+	snapshot, err := db.GetSnapshotByTime(t)
+	if errors.Is(err, db.ErrNotFound) {
+		writeError(w, http.StatusNotFound, codeNotFound, "No snapshot had been taken by "+formatTime(t))
+		return snapshot, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving snapshot: "+err.Error())
+		return snapshot, false
+	}
+	return snapshot, true
+}
@@ -0,0 +1,313 @@
+package api
+
+import (
+	"net/http"
+	"teamacedia/minestalker/internal/db"
+	"teamacedia/minestalker/internal/events"
+	"teamacedia/minestalker/internal/models"
+)
+
+// Parameters shared by several operations
+var (
+	nameParam  = pathParam("name", "Player name, matched case-insensitively")
+	ipParam    = pathParam("ip", "Server address")
+	portParam  = parameter{name: "port", in: "path", schema: map[string]any{"type": "integer", "minimum": 1, "maximum": 65535}}
+	idParam    = parameter{name: "id", in: "path", schema: map[string]any{"type": "integer"}}
+	fromParam  = queryParam("from", "string", "Start, RFC 3339 time or YYYY-MM-DD date")
+	toParam    = queryParam("to", "string", "End, RFC 3339 time or YYYY-MM-DD date; defaults to now")
+	atParam    = queryParam("at", "string", "RFC 3339 time or YYYY-MM-DD date")
+	pageParams = []parameter{
+		queryParam("page", "integer", "Page number, starting at 1"),
+		queryParam("per_page", "integer", "Results per page, default 50, at most 500"),
+	}
+	eventFilterParams = []parameter{
+		queryParam("players", "string", "Comma separated player names"),
+		queryParam("servers", "string", "Comma separated servers, address or address:port"),
+		queryParam("types", "string", "Comma separated event types: serverOnline, serverOffline, playerJoin, playerLeave"),
+	}
+)
+
+func params(groups ...[]parameter) []parameter {
+	var all []parameter
+	for _, group := range groups {
+		all = append(all, group...)
+	}
+	return all
+}
+
+// operations documents every route of NewHandler. checkSpec reports routes
+// added there without an entry here.
+var operations = []operation{
+	{
+		method: http.MethodGet, path: "/api/openapi.json",
+		summary:  "This OpenAPI document",
+		response: map[string]any{},
+	},
+	{
+		method: http.MethodGet, path: "/metrics", scope: db.ScopeReadPublic,
+		summary:     "Service metrics in the Prometheus text exposition format",
+		response:    "",
+		contentType: "text/plain; version=0.0.4",
+	},
+
+	// Players
+	{
+		method: http.MethodGet, path: "/api/v1/player/{name}", scope: db.ScopeReadHistory,
+		summary:  "History of a player across servers, newest session first",
+		params:   []parameter{nameParam},
+		response: PlayerHistoryResponse{},
+		row:      PlayerSightingRow{},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/player/{name}/stats", scope: db.ScopeReadHistory,
+		summary:     "Playtime and activity statistics of a player",
+		description: "Covers all history, or from/to when given; one alone means a 30 day window.",
+		params:      []parameter{nameParam, fromParam, toParam, queryParam("tz", "string", "IANA time zone of days and hours, default UTC")},
+		response:    PlayerStatsResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/player/{name}/companions", scope: db.ScopeReadHistory,
+		summary:  "Players who played alongside a player, by overlapping time",
+		params:   params([]parameter{nameParam, fromParam, toParam}, pageParams),
+		response: CompanionsResponse{},
+	},
+
+	// Servers
+	{
+		method: http.MethodGet, path: "/api/v1/servers", scope: db.ScopeReadPublic,
+		summary: "Every known server with its status",
+		params: params([]parameter{
+			queryParam("online", "boolean", ""),
+			queryParam("game", "string", "Exact game id"),
+			queryParam("name", "string", "Case-insensitive substring of the server name"),
+			queryParam("min_players", "integer", ""),
+			queryParam("max_players", "integer", ""),
+			queryParam("first_seen_after", "string", "RFC 3339 time or YYYY-MM-DD date"),
+			enumParam("sort", "Default players", "players", "uptime", "name", "last_seen"),
+			enumParam("order", "Default asc for name, else desc", "asc", "desc"),
+		}, pageParams),
+		response: ServerListResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/server/{ip}/{port}", scope: db.ScopeReadHistory,
+		summary:  "Snapshot history of a server including its players",
+		params:   []parameter{ipParam, portParam},
+		response: ServerHistoryResponse{},
+		row:      SnapshotServerRow{},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/server/{ip}/{port}/uptime", scope: db.ScopeReadPublic,
+		summary:  "Availability of a server over the last 24 hours, 7 days and 30 days",
+		params:   []parameter{ipParam, portParam},
+		response: ServerUptimeResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/server/{ip}/{port}/population", scope: db.ScopeReadPublic,
+		summary: "Player counts of a server bucketed over time",
+		params: []parameter{ipParam, portParam, fromParam, toParam,
+			queryParam("resolution", "string", "Bucket length such as 15m, 1h or 1d, default 1h")},
+		response: ServerPopulationResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/server/{ip}/{port}/roster", scope: db.ScopeReadHistory,
+		summary:     "Who was online on a server at a time or during a window",
+		description: "With from/to every session overlapping the window, otherwise the players online at `at` (default now).",
+		params:      []parameter{ipParam, portParam, atParam, fromParam, toParam},
+		response:    RosterResponse{},
+	},
+
+	// Snapshots
+	{
+		method: http.MethodGet, path: "/api/v1/snapshot", scope: db.ScopeReadPublic,
+		summary:  "The latest snapshot of the server list, or the one in effect at a time",
+		params:   []parameter{atParam},
+		response: SnapshotResponse{},
+		row:      SnapshotServerRow{},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/snapshot/diff", scope: db.ScopeReadHistory,
+		summary:  "Servers and players that appeared or disappeared between two snapshots",
+		params:   []parameter{fromParam, toParam},
+		response: SnapshotDiffResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/snapshots", scope: db.ScopeReadPublic,
+		summary:  "Times snapshots were taken, by default in the last 24 hours",
+		params:   []parameter{fromParam, toParam},
+		response: SnapshotListResponse{},
+	},
+
+	// Aggregates
+	{
+		method: http.MethodGet, path: "/api/v1/stats/global", scope: db.ScopeReadPublic,
+		summary:  "Network wide totals",
+		params:   []parameter{enumParam("window", "How far back servers and players count as new, default 24h", "1h", "24h", "7d", "30d")},
+		response: GlobalStatsResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/leaderboards", scope: db.ScopeReadPublic,
+		summary: "Ranked players and servers over a window",
+		params: []parameter{
+			enumParam("window", "Default all", "day", "week", "month", "all"),
+			enumParam("board", "Default every board", db.BoardPlaytime, db.BoardServersVisited, db.BoardPeakPlayers, db.BoardUptimeStreak),
+			queryParam("game", "string", ""),
+			queryParam("server", "string", "address:port"),
+			queryParam("limit", "integer", "Entries per board, default 10, at most 100"),
+		},
+		response: LeaderboardsResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/games", scope: db.ScopeReadPublic,
+		summary:  "Every game reported by a server with its numbers",
+		response: GameListResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/games/{id}", scope: db.ScopeReadPublic,
+		summary:  "Details of one game",
+		params:   []parameter{pathParam("id", "Game id, e.g. mineclonia"), queryParam("days", "integer", "Days of trend, default 30, at most 365")},
+		response: GameResponse{},
+	},
+
+	// Live events
+	{
+		method: http.MethodGet, path: "/api/v1/events/stream", scope: db.ScopeReadHistory,
+		summary:     "Live tracking events as server-sent events",
+		description: "Each event is named after its type and carries the event as JSON data; a heartbeat event is sent every 15 seconds.",
+		params: params(eventFilterParams, []parameter{
+			{name: "Last-Event-ID", in: "header", schema: map[string]any{"type": "string"}, description: "Resume after this event"},
+			queryParam("last_event_id", "string", "Same as Last-Event-ID"),
+		}),
+		response:    events.Payload{},
+		contentType: "text/event-stream",
+		row:         events.Payload{},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/ws", scope: db.ScopeReadHistory,
+		summary:     "WebSocket with subscriptions to players, servers and event types",
+		description: `Send {"action": "subscribe" or "unsubscribe", "players": [...], "servers": [...], "types": [...]}; receive subscribed, state, event and error messages.`,
+		status:      http.StatusSwitchingProtocols,
+	},
+
+	// GraphQL
+	{
+		method: http.MethodPost, path: "/api/v1/graphql", scope: db.ScopeReadHistory,
+		summary:     "GraphQL queries over players, servers, sightings, snapshots and games",
+		description: "The schema can be introspected. Errors are reported in the errors of a 200 response.",
+		body:        graphQLRequest{},
+		response:    GraphQLResponse{},
+	},
+
+	// Admin
+	{
+		method: http.MethodGet, path: "/api/v1/admin/optout", scope: db.ScopeAdmin,
+		summary:  "Players who opted out of tracking",
+		response: []OptOutResponse{},
+	},
+	{
+		method: http.MethodPost, path: "/api/v1/admin/optout", scope: db.ScopeAdmin,
+		summary: "Opt a player out of tracking",
+		body:    optOutRequest{},
+		status:  http.StatusNoContent,
+	},
+	{
+		method: http.MethodDelete, path: "/api/v1/admin/optout/{name}", scope: db.ScopeAdmin,
+		summary: "Resume tracking a player",
+		params:  []parameter{nameParam},
+		status:  http.StatusNoContent,
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/admin/erasures", scope: db.ScopeAdmin,
+		summary:  "The erasure audit log",
+		response: []ErasureResponse{},
+	},
+	{
+		method: http.MethodPost, path: "/api/v1/admin/erasures", scope: db.ScopeAdmin,
+		summary:  "Erase everything stored about a player",
+		body:     erasureRequest{},
+		response: ErasureResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/admin/webhooks", scope: db.ScopeAdmin,
+		summary:  "Webhook subscriptions",
+		response: []WebhookResponse{},
+	},
+	{
+		method: http.MethodPost, path: "/api/v1/admin/webhooks", scope: db.ScopeAdmin,
+		summary:  "Subscribe a URL to events; the response holds the signing secret",
+		body:     webhookRequest{},
+		response: WebhookResponse{},
+		status:   http.StatusCreated,
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/admin/webhooks/{id}", scope: db.ScopeAdmin,
+		summary:  "One webhook subscription",
+		params:   []parameter{idParam},
+		response: WebhookResponse{},
+	},
+	{
+		method: http.MethodPatch, path: "/api/v1/admin/webhooks/{id}", scope: db.ScopeAdmin,
+		summary:  "Change a webhook subscription or enable or disable it",
+		params:   []parameter{idParam},
+		body:     webhookRequest{},
+		response: WebhookResponse{},
+	},
+	{
+		method: http.MethodDelete, path: "/api/v1/admin/webhooks/{id}", scope: db.ScopeAdmin,
+		summary: "Remove a webhook subscription and its delivery log",
+		params:  []parameter{idParam},
+		status:  http.StatusNoContent,
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/admin/webhooks/{id}/deliveries", scope: db.ScopeAdmin,
+		summary:  "Delivery attempts of a webhook, newest first",
+		params:   params([]parameter{idParam}, pageParams),
+		response: WebhookDeliveriesResponse{},
+	},
+
+	// Deprecated aliases
+	{
+		method: http.MethodGet, path: "/api/player/{name}", scope: db.ScopeReadHistory, deprecated: true,
+		summary:  "Use /api/v1/player/{name}",
+		params:   []parameter{nameParam},
+		response: []models.PlayerSighting{},
+	},
+	{
+		method: http.MethodGet, path: "/api/server/{ip}/{port}", scope: db.ScopeReadHistory, deprecated: true,
+		summary:  "Use /api/v1/server/{ip}/{port}",
+		params:   []parameter{ipParam, portParam},
+		response: []models.Snapshot{},
+	},
+	{
+		method: http.MethodGet, path: "/api/snapshot", scope: db.ScopeReadPublic, deprecated: true,
+		summary:  "Use /api/v1/snapshot",
+		response: models.Snapshot{},
+	},
+	{
+		method: http.MethodGet, path: "/api/admin/optout", scope: db.ScopeAdmin, deprecated: true,
+		summary:  "Use /api/v1/admin/optout",
+		response: []OptOutResponse{},
+	},
+	{
+		method: http.MethodPost, path: "/api/admin/optout", scope: db.ScopeAdmin, deprecated: true,
+		summary: "Use /api/v1/admin/optout",
+		body:    optOutRequest{},
+		status:  http.StatusNoContent,
+	},
+	{
+		method: http.MethodDelete, path: "/api/admin/optout/{name}", scope: db.ScopeAdmin, deprecated: true,
+		summary: "Use /api/v1/admin/optout/{name}",
+		params:  []parameter{nameParam},
+		status:  http.StatusNoContent,
+	},
+	{
+		method: http.MethodGet, path: "/api/admin/erasures", scope: db.ScopeAdmin, deprecated: true,
+		summary:  "Use /api/v1/admin/erasures",
+		response: []ErasureResponse{},
+	},
+	{
+		method: http.MethodPost, path: "/api/admin/erasures", scope: db.ScopeAdmin, deprecated: true,
+		summary:  "Use /api/v1/admin/erasures",
+		body:     erasureRequest{},
+		response: ErasureResponse{},
+	},
+}
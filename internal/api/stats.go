@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"teamacedia/minestalker/internal/analytics"
+	"teamacedia/minestalker/internal/db"
+	"time"
+)
+
+// Global stats count new servers and players over the last day by default,
+// list the ten busiest servers and are recomputed at most once a minute.
+const (
+	defaultGlobalStatsWindow = 24 * time.Hour
+	globalStatsTopServers    = 10
+	globalStatsTTL           = time.Minute
+)
+
+// globalStatsWindows are the windows global stats can be asked for. Each is
+// cached on its own, so the set stays small.
+var globalStatsWindows = []time.Duration{time.Hour, 24 * time.Hour, 7 * 24 * time.Hour, 30 * 24 * time.Hour}
+
+var globalStatsCache = newTTLCache[GlobalStatsResponse](globalStatsTTL)
+
+// GlobalStatsHandler serves network wide statistics
+// GET /api/v1/stats/global?window=
+func GlobalStatsHandler(w http.ResponseWriter, r *http.Request) {
+	window := defaultGlobalStatsWindow
+	if d, err := queryDuration(r, "window"); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	} else if d != nil {
+		window = *d
+	}
+	if !slices.Contains(globalStatsWindows, window) {
+		writeError(w, http.StatusBadRequest, codeBadRequest, paramError{"window", "expected 1h, 24h, 7d or 30d"}.Error())
+		return
+	}
+
+	resp, err := globalStatsCache.get(window.String(), func() (GlobalStatsResponse, error) {
+		return computeGlobalStats(window)
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error computing global stats: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func computeGlobalStats(window time.Duration) (GlobalStatsResponse, error) {
+	now := time.Now().UTC()
+	counts, err := db.GetGlobalCounts(now, now.Add(-window))
+	if err != nil {
+		return GlobalStatsResponse{}, err
+	}
+
+	online := true
+	servers, _, err := db.ListServers(db.ServerFilter{Online: &online, Sort: "players", Descending: true})
+	if err != nil {
+		return GlobalStatsResponse{}, err
+	}
+
+	return newGlobalStatsResponse(now, window, counts, servers[:min(globalStatsTopServers, len(servers))], analytics.GameTotals(servers)), nil
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"teamacedia/minestalker/internal/events"
+	"time"
+)
+
+// heartbeatInterval keeps idle streams alive through proxies that close quiet
+// connections.
+const heartbeatInterval = 15 * time.Second
+
+// EventStreamHandler pushes tracking events as server-sent events, or as
+// CSV or NDJSON rows when asked for
+// GET /api/v1/events/stream?players=&servers=&types=&format=
+func EventStreamHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := eventFilterFromQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	format, err := negotiateFormat(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	// EventSource resends the last ID it saw as a header when reconnecting
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		if lastID, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid Last-Event-ID")
+			return
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	// Rows have no way to carry heartbeats, so only event streams get them
+	write := func(event events.Event) error { return writeServerSentEvent(w, event) }
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	if format == formatJSON {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+	} else {
+		rw := newRowWriter(w, format, events.Payload{})
+		rw.flush = true
+		write = func(event events.Event) error { return rw.write(event.Payload()) }
+		heartbeat.Stop()
+		if err := rw.start(); err != nil {
+			return
+		}
+	}
+
+	sub, missed := events.Subscribe(lastID)
+	defer sub.Close()
+
+	for _, event := range missed {
+		if filter.Match(event.TrackingEvent) {
+			if err := write(event); err != nil {
+				return
+			}
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if !filter.Match(event.TrackingEvent) {
+				continue
+			}
+			if err := write(event); err != nil {
+				return
+			}
+
+		case t := <-heartbeat.C:
+			_, err := fmt.Fprintf(w, "event: heartbeat\ndata: {\"time\":%q}\n\n", formatTime(t))
+			if err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeServerSentEvent(w http.ResponseWriter, event events.Event) error {
+	data, err := json.Marshal(event.Payload())
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// eventFilterFromQuery reads the comma separated players, servers and types
+// parameters shared by the event endpoints.
+func eventFilterFromQuery(r *http.Request) (events.Filter, error) {
+	query := r.URL.Query()
+	filter, err := events.ParseFilter(query.Get("players"), query.Get("servers"), query.Get("types"))
+	if err != nil {
+		return filter, paramError{"types", err.Error()}
+	}
+	return filter, nil
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"teamacedia/minestalker/internal/events"
+	"teamacedia/minestalker/internal/models"
+	"testing"
+)
+
+func TestEventStreamLastEventID(t *testing.T) {
+	events.Publish([]models.TrackingEvent{
+		{Type: "playerJoin", Server: "example.org", Port: 30000, Player: "alice"},
+		{Type: "playerJoin", Server: "example.org", Port: 30000, Player: "bob"},
+		{Type: "playerLeave", Server: "example.org", Port: 30000, Player: "alice"},
+	})
+	sub, published := events.Subscribe(1)
+	sub.Close()
+	if len(published) < 3 {
+		t.Fatalf("%d events in the replay buffer, want at least 3", len(published))
+	}
+	published = published[len(published)-3:]
+	id := func(i int) string { return strconv.FormatUint(published[i].ID, 10) }
+
+	cases := []struct {
+		name   string
+		header string
+		query  string
+		status int
+		ids    []string
+	}{
+		{"no last ID", "", "", http.StatusOK, nil},
+		{"header", id(0), "", http.StatusOK, []string{id(1), id(2)}},
+		{"query parameter", "", "last_event_id=" + id(1), http.StatusOK, []string{id(2)}},
+		{"header wins", id(1), "last_event_id=" + id(0), http.StatusOK, []string{id(2)}},
+		{"filtered", id(0), "players=bob", http.StatusOK, []string{id(1)}},
+		{"invalid", "abc", "", http.StatusBadRequest, nil},
+		{"negative", "-1", "", http.StatusBadRequest, nil},
+	}
+
+	for _, c := range cases {
+		// Cancelled up front, the handler writes the missed events and returns
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		r := httptest.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/events/stream?"+c.query, nil)
+		if c.header != "" {
+			r.Header.Set("Last-Event-ID", c.header)
+		}
+		w := httptest.NewRecorder()
+		EventStreamHandler(w, r)
+
+		if w.Code != c.status {
+			t.Errorf("%s: status %d, want %d", c.name, w.Code, c.status)
+			continue
+		}
+		var ids []string
+		for _, m := range regexp.MustCompile(`(?m)^id: (\d+)$`).FindAllStringSubmatch(w.Body.String(), -1) {
+			ids = append(ids, m[1])
+		}
+		if !slices.Equal(ids, c.ids) {
+			t.Errorf("%s: sent IDs %v, want %v", c.name, ids, c.ids)
+		}
+	}
+}
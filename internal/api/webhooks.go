@@ -0,0 +1,233 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"teamacedia/minestalker/internal/db"
+	"teamacedia/minestalker/internal/events"
+	"teamacedia/minestalker/internal/models"
+	"teamacedia/minestalker/internal/webhooks"
+)
+
+// webhookRequest is the body of creating or updating a webhook. Absent fields
+// are left unchanged by updates, omitempty marks them optional in the OpenAPI
+// document.
+type webhookRequest struct {
+	URL     *string   `json:"url,omitempty"`
+	Secret  *string   `json:"secret,omitempty"` // Generated when not given on creation
+	Players *[]string `json:"players,omitempty"`
+	Servers *[]string `json:"servers,omitempty"`
+	Types   *[]string `json:"types,omitempty"`
+	Enabled *bool     `json:"enabled,omitempty"` // Enabling resets the failure count
+}
+
+// apply validates the request and copies its fields onto sub.
+func (req webhookRequest) apply(sub *models.WebhookSubscription) error {
+	if req.URL != nil {
+		u, err := url.Parse(*req.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return paramError{"url", "must be an absolute http or https URL"}
+		}
+		sub.URL = *req.URL
+	}
+	if req.Secret != nil {
+		if *req.Secret == "" {
+			return paramError{"secret", "must not be empty"}
+		}
+		sub.Secret = *req.Secret
+	}
+
+	for _, list := range []*[]string{req.Players, req.Servers, req.Types} {
+		for _, entry := range deref(list) {
+			if strings.TrimSpace(entry) == "" || strings.Contains(entry, ",") {
+				return paramError{"filter", "entries must be non-empty and must not contain commas"}
+			}
+		}
+	}
+	if _, err := events.ParseFilter("", "", strings.Join(deref(req.Types), ",")); err != nil {
+		return paramError{"types", err.Error()}
+	}
+	if req.Players != nil {
+		sub.Players = *req.Players
+	}
+	if req.Servers != nil {
+		sub.Servers = *req.Servers
+	}
+	if req.Types != nil {
+		sub.Types = *req.Types
+	}
+
+	if req.Enabled != nil {
+		sub.Enabled = *req.Enabled
+		sub.DisabledReason = ""
+		if sub.Enabled {
+			sub.ConsecutiveFailures = 0
+		}
+	}
+	return nil
+}
+
+func deref(list *[]string) []string {
+	if list == nil {
+		return nil
+	}
+	return *list
+}
+
+// webhookFromPath looks up the {id} path parameter, answering a 400 or 404
+// itself when there is no such webhook.
+func webhookFromPath(w http.ResponseWriter, r *http.Request) (models.WebhookSubscription, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid webhook ID")
+		return models.WebhookSubscription{}, false
+	}
+	sub, err := db.GetWebhook(id)
+	if errors.Is(err, db.ErrNotFound) {
+		writeError(w, http.StatusNotFound, codeNotFound, "Webhook not found")
+		return sub, false
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving webhook: "+err.Error())
+		return sub, false
+	}
+	return sub, true
+}
+
+// reloadWebhooks applies changed subscriptions to the running deliveries. The
+// change itself is saved already, so a failure is only logged.
+func reloadWebhooks() {
+	if err := webhooks.Reload(); err != nil {
+		log.Printf("Error reloading webhooks: %v", err)
+	}
+}
+
+// ListWebhooksHandler lists webhook subscriptions
+// GET /api/v1/admin/webhooks
+func ListWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	subs, err := db.GetWebhooks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving webhooks: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, newWebhookResponses(subs))
+}
+
+// CreateWebhookHandler subscribes a URL to events. The response carries the
+// secret payloads are signed with, it is not shown again.
+// POST /api/v1/admin/webhooks with {"url": ..., "players": [...], "servers": [...], "types": [...]}
+func CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid JSON body")
+		return
+	}
+	if req.URL == nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Missing url")
+		return
+	}
+
+	sub := models.WebhookSubscription{Enabled: true}
+	if err := req.apply(&sub); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	if sub.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, "Error generating secret: "+err.Error())
+			return
+		}
+		sub.Secret = hex.EncodeToString(secret)
+	}
+
+	sub, err := db.CreateWebhook(sub)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error creating webhook: "+err.Error())
+		return
+	}
+	reloadWebhooks()
+
+	resp := newWebhookResponse(sub)
+	resp.Secret = sub.Secret
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// WebhookHandler serves one webhook subscription
+// GET /api/v1/admin/webhooks/{id}
+func WebhookHandler(w http.ResponseWriter, r *http.Request) {
+	sub, ok := webhookFromPath(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, newWebhookResponse(sub))
+}
+
+// UpdateWebhookHandler changes the URL, filters or secret of a webhook, or
+// enables or disables it
+// PATCH /api/v1/admin/webhooks/{id} with any of the fields of the creation body and "enabled"
+func UpdateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	sub, ok := webhookFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid JSON body")
+		return
+	}
+	if err := req.apply(&sub); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	if err := db.UpdateWebhook(sub); err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error updating webhook: "+err.Error())
+		return
+	}
+	reloadWebhooks()
+	writeJSON(w, http.StatusOK, newWebhookResponse(sub))
+}
+
+// DeleteWebhookHandler removes a webhook subscription and its delivery log
+// DELETE /api/v1/admin/webhooks/{id}
+func DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	sub, ok := webhookFromPath(w, r)
+	if !ok {
+		return
+	}
+	if err := db.DeleteWebhook(sub.ID); err != nil && !errors.Is(err, db.ErrNotFound) {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error deleting webhook: "+err.Error())
+		return
+	}
+	reloadWebhooks()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// WebhookDeliveriesHandler serves the delivery log of a webhook, newest first
+// GET /api/v1/admin/webhooks/{id}/deliveries?page=&per_page=
+func WebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	sub, ok := webhookFromPath(w, r)
+	if !ok {
+		return
+	}
+	page, perPage, err := queryPage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	deliveries, total, err := db.GetWebhookDeliveries(sub.ID, perPage, (page-1)*perPage)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error retrieving deliveries: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, newWebhookDeliveriesResponse(deliveries, page, perPage, total))
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"teamacedia/minestalker/internal/db"
+	"teamacedia/minestalker/internal/events"
+	"teamacedia/minestalker/internal/models"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket keepalive: the server pings every wsPingInterval and drops
+// clients that have not answered within wsPongTimeout.
+const (
+	wsPingInterval = 30 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsWriteTimeout = 10 * time.Second
+	wsMaxMessage   = 64 * 1024
+)
+
+var upgrader = websocket.Upgrader{
+	// Everything on the socket is public data and nothing is authenticated by
+	// cookie, so pages on any origin may connect.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// wsRequest is a message from the client:
+// {"action": "subscribe", "players": [...], "servers": ["address:port", ...], "types": [...]}
+type wsRequest struct {
+	Action  string   `json:"action"` // "subscribe" or "unsubscribe"
+	Players []string `json:"players"`
+	Servers []string `json:"servers"`
+	Types   []string `json:"types"`
+}
+
+// wsMessage is a message to the client. Type is "subscribed" after every
+// change of the subscription, "state" with the current state of newly
+// subscribed players and servers, "event" for live events and "error".
+type wsMessage struct {
+	Type         string                 `json:"type"`
+	Subscription *wsSubscriptionMessage `json:"subscription,omitempty"`
+	Players      []PlayerStateResponse  `json:"players,omitempty"`
+	Servers      []ServerStatusResponse `json:"servers,omitempty"`
+	Event        *events.Payload        `json:"event,omitempty"`
+	Message      string                 `json:"message,omitempty"`
+}
+
+type wsSubscriptionMessage struct {
+	Players []string `json:"players"`
+	Servers []string `json:"servers"`
+	Types   []string `json:"types"`
+}
+
+// wsSubscription is what one connection subscribed to. Players and servers
+// are keyed lowercased; a server is "address" or "address:port".
+type wsSubscription struct {
+	players map[string]string // lowercased -> as given
+	servers map[string]string
+	types   map[string]bool
+}
+
+// match delivers an event when something is subscribed, its type is
+// subscribed (or no type is), and its player or server is subscribed (or no
+// player or server is).
+func (s *wsSubscription) match(event models.TrackingEvent) bool {
+	if len(s.players)+len(s.servers)+len(s.types) == 0 {
+		return false
+	}
+	if len(s.types) > 0 && !s.types[event.Type] {
+		return false
+	}
+	if len(s.players)+len(s.servers) == 0 {
+		return true
+	}
+	if _, ok := s.players[strings.ToLower(event.Player)]; ok && event.Player != "" {
+		return true
+	}
+	if _, ok := s.servers[strings.ToLower(event.Server)]; ok {
+		return true
+	}
+	_, ok := s.servers[strings.ToLower(fmt.Sprintf("%s:%d", event.Server, event.Port))]
+	return ok
+}
+
+func (s *wsSubscription) message() *wsSubscriptionMessage {
+	msg := &wsSubscriptionMessage{Players: []string{}, Servers: []string{}, Types: []string{}}
+	for _, p := range s.players {
+		msg.Players = append(msg.Players, p)
+	}
+	for _, srv := range s.servers {
+		msg.Servers = append(msg.Servers, srv)
+	}
+	for t := range s.types {
+		msg.Types = append(msg.Types, t)
+	}
+	return msg
+}
+
+// WebSocketHandler serves live events over a WebSocket with subscriptions
+// managed by the client
+// GET /api/v1/ws
+func WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade has answered the request
+	}
+	defer conn.Close()
+
+	sub, _ := events.Subscribe(0)
+	defer sub.Close()
+
+	// The reader goroutine only parses requests, the loop below owns the
+	// subscription and is the only writer, as gorilla/websocket requires.
+	requests := make(chan wsRequest)
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		conn.SetReadLimit(wsMaxMessage)
+		conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		})
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var req wsRequest
+			if err := json.Unmarshal(data, &req); err != nil {
+				req = wsRequest{Action: "invalid"}
+			}
+			select {
+			case requests <- req:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}()
+
+	subscription := &wsSubscription{players: map[string]string{}, servers: map[string]string{}, types: map[string]bool{}}
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	send := func(msg wsMessage) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return conn.WriteJSON(msg)
+	}
+
+	for {
+		var err error
+		select {
+		case <-readDone:
+			return
+
+		case req := <-requests:
+			for _, msg := range handleWSRequest(subscription, req) {
+				if err = send(msg); err != nil {
+					return
+				}
+			}
+
+		case event, ok := <-sub.C:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+					time.Now().Add(wsWriteTimeout))
+				return
+			}
+			if subscription.match(event.TrackingEvent) {
+				payload := event.Payload()
+				err = send(wsMessage{Type: "event", Event: &payload})
+			}
+
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handleWSRequest applies a client request to the subscription and returns
+// the messages to answer it with.
+func handleWSRequest(s *wsSubscription, req wsRequest) []wsMessage {
+	for _, t := range req.Types {
+		if _, err := events.ParseFilter("", "", t); err != nil {
+			return []wsMessage{{Type: "error", Message: err.Error()}}
+		}
+	}
+
+	switch req.Action {
+	case "subscribe":
+		var newPlayers, newServers []string
+		for _, p := range req.Players {
+			if key := strings.ToLower(strings.TrimSpace(p)); key != "" && s.players[key] == "" {
+				s.players[key] = strings.TrimSpace(p)
+				newPlayers = append(newPlayers, s.players[key])
+			}
+		}
+		for _, srv := range req.Servers {
+			if key := strings.ToLower(strings.TrimSpace(srv)); key != "" && s.servers[key] == "" {
+				s.servers[key] = strings.TrimSpace(srv)
+				newServers = append(newServers, s.servers[key])
+			}
+		}
+		for _, t := range req.Types {
+			s.types[t] = true
+		}
+
+		msgs := []wsMessage{{Type: "subscribed", Subscription: s.message()}}
+		state, err := currentState(newPlayers, newServers)
+		if err != nil {
+			log.Printf("Error loading WebSocket state: %v", err)
+			return append(msgs, wsMessage{Type: "error", Message: "Error loading current state"})
+		}
+		if len(state.Players)+len(state.Servers) > 0 {
+			msgs = append(msgs, state)
+		}
+		return msgs
+
+	case "unsubscribe":
+		for _, p := range req.Players {
+			delete(s.players, strings.ToLower(strings.TrimSpace(p)))
+		}
+		for _, srv := range req.Servers {
+			delete(s.servers, strings.ToLower(strings.TrimSpace(srv)))
+		}
+		for _, t := range req.Types {
+			delete(s.types, t)
+		}
+		return []wsMessage{{Type: "subscribed", Subscription: s.message()}}
+
+	case "invalid":
+		return []wsMessage{{Type: "error", Message: "Messages must be JSON objects"}}
+	}
+
+	return []wsMessage{{Type: "error", Message: fmt.Sprintf("Unknown action %q, expected subscribe or unsubscribe", req.Action)}}
+}
+
+// currentState loads where the given players are online and the status of
+// the given servers.
+func currentState(players, servers []string) (wsMessage, error) {
+	msg := wsMessage{Type: "state"}
+	now := time.Now().UTC()
+
+	for _, player := range players {
+		sightings, err := db.GetPlayerHistoryBetween(player, now, now)
+		if err != nil {
+			return msg, err
+		}
+		msg.Players = append(msg.Players, newPlayerStateResponse(player, sightings))
+	}
+
+	for _, server := range servers {
+		filter := db.ServerFilter{Address: server}
+		if host, port, err := net.SplitHostPort(server); err == nil {
+			if n, err := strconv.Atoi(port); err == nil {
+				filter.Address, filter.Port = host, n
+			}
+		}
+		statuses, _, err := db.ListServers(filter)
+		if err != nil {
+			return msg, err
+		}
+		msg.Servers = append(msg.Servers, newServerStatusResponses(statuses)...)
+	}
+
+	return msg, nil
+}
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"fmt"
+	"net/netip"
+	"strings"
 	"teamacedia/minestalker/internal/models"
 
 	"gopkg.in/ini.v1"
@@ -21,7 +24,37 @@ func LoadConfig(path string) (*models.Config, error) {
 		SnapshotInterval:      cfgFile.Section("").Key("SnapshotInterval").MustInt(300),
 		LoggerWebhookUrl:      cfgFile.Section("").Key("LoggerWebhookUrl").String(),
 		LoggerWebhookUsername: cfgFile.Section("").Key("LoggerWebhookUsername").String(),
+		BusyTimeout:           cfgFile.Section("").Key("BusyTimeout").MustInt(5000),
+		AdminToken:            cfgFile.Section("").Key("AdminToken").String(),
+		AnonymousScopes:       cfgFile.Section("").Key("AnonymousScopes").Strings(","),
+		AnonymousRateLimit:    cfgFile.Section("").Key("AnonymousRateLimit").MustInt(60),
+		APIKeyRateLimit:       cfgFile.Section("").Key("APIKeyRateLimit").MustInt(600),
+	}
+
+	if !cfgFile.Section("").HasKey("AnonymousScopes") {
+		cfg.AnonymousScopes = []string{"read:public"}
+	}
+
+	for _, proxy := range cfgFile.Section("").Key("TrustedProxies").Strings(",") {
+		prefix, err := parseProxy(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TrustedProxies entry %q: %w", proxy, err)
+		}
+		cfg.TrustedProxies = append(cfg.TrustedProxies, prefix)
 	}
 
 	return cfg, nil
 }
+
+// parseProxy reads a trusted proxy given as an IP address or a CIDR range.
+func parseProxy(proxy string) (netip.Prefix, error) {
+	if strings.Contains(proxy, "/") {
+		prefix, err := netip.ParsePrefix(proxy)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(proxy)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// Scopes of the HTTP API. Each includes the ones before it.
+const (
+	ScopeReadPublic  = "read:public"  // Current state, aggregates and live events
+	ScopeReadHistory = "read:history" // Histories of players and servers
+	ScopeAdmin       = "admin"        // Admin endpoints
+)
+
+// Scopes lists every scope, narrowest first.
+var Scopes = []string{ScopeReadPublic, ScopeReadHistory, ScopeAdmin}
+
+// apiKeyPrefix starts every key, so leaked keys are easy to recognise.
+const apiKeyPrefix = "msk_"
+
+// HasScope reports whether the granted scopes include want.
+func HasScope(granted []string, want string) bool {
+	wantRank := slices.Index(Scopes, want)
+	for _, scope := range granted {
+		if wantRank >= 0 && slices.Index(Scopes, scope) >= wantRank {
+			return true
+		}
+	}
+	return false
+}
+
+// IssueAPIKey creates a key and returns it along with the key itself, which
+// is not stored and cannot be retrieved again.
+func IssueAPIKey(name string, scopes []string, rateLimit int) (models.APIKey, string, error) {
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return models.APIKey{}, "", fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return models.APIKey{}, "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	plain := apiKeyPrefix + hex.EncodeToString(secret)
+
+	key := models.APIKey{
+		Name:      name,
+		Prefix:    plain[:len(apiKeyPrefix)+8],
+		Scopes:    scopes,
+		RateLimit: rateLimit,
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	err := write(func() error {
+		res, err := DB.Exec(`
+			INSERT INTO api_keys (name, key_hash, prefix, scopes, rate_limit, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, key.Name, hashAPIKey(plain), key.Prefix, strings.Join(key.Scopes, ","), key.RateLimit, formatTime(key.CreatedAt))
+		if err != nil {
+			return fmt.Errorf("failed to insert API key: %w", err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get API key ID: %w", err)
+		}
+		key.ID = int(id)
+		return nil
+	})
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+	return key, plain, nil
+}
+
+// RevokeAPIKey revokes a key by ID, wrapping ErrNotFound when there is no
+// such key that is still active.
+func RevokeAPIKey(id int) error {
+	return write(func() error {
+		res, err := DB.Exec(`
+			UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL
+		`, formatTime(time.Now()), id)
+		if n, err := rowsAffected(res, err); err != nil {
+			return fmt.Errorf("failed to revoke API key: %w", err)
+		} else if n == 0 {
+			return fmt.Errorf("no active API key %d: %w", id, ErrNotFound)
+		}
+		return nil
+	})
+}
+
+// LookupAPIKey finds the active key matching plain, wrapping ErrNotFound when
+// it is unknown or revoked.
+func LookupAPIKey(plain string) (models.APIKey, error) {
+	key, err := scanAPIKey(ReadDB.QueryRow(`
+		SELECT id, name, prefix, scopes, rate_limit, created_at, revoked_at
+		FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL
+	`, hashAPIKey(plain)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return key, fmt.Errorf("unknown API key: %w", ErrNotFound)
+	}
+	return key, err
+}
+
+// GetAPIKeys retrieves every key including revoked ones, oldest first.
+func GetAPIKeys() ([]models.APIKey, error) {
+	rows, err := ReadDB.Query(`
+		SELECT id, name, prefix, scopes, rate_limit, created_at, revoked_at
+		FROM api_keys ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func scanAPIKey(row interface{ Scan(...any) error }) (models.APIKey, error) {
+	var key models.APIKey
+	var scopes string
+	var revokedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &scopes, &key.RateLimit, &key.CreatedAt, &revokedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return key, err
+		}
+		return key, fmt.Errorf("row scan failed: %w", err)
+	}
+	key.Scopes = splitList(scopes)
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return key, nil
+}
+
+// Keys are long and random, so a fast unsalted hash is enough to keep them
+// unusable if the database leaks.
+func hashAPIKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"fmt"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// GetCompanions ranks the players who were online on the same server
+// sighting as the given player during [from, to] by the time they overlapped,
+// longest first, along with the total number of companions. Open sightings
+// count until now and players who opted out are never listed as companions.
+func GetCompanions(name string, from, to time.Time, limit, offset int) ([]models.Companion, int, error) {
+	now := formatTime(time.Now())
+	query := `
+	WITH overlaps AS (
+		SELECT
+			p2.name AS companion,
+			MAX(ps1.seen_at, ps2.seen_at, ?) AS overlap_start,
+			MIN(COALESCE(ps1.disconnected_at, ?), COALESCE(ps2.disconnected_at, ?), ?) AS overlap_end
+		FROM player_sightings ps1
+		JOIN players p1 ON ps1.player_id = p1.id
+		JOIN player_sightings ps2 ON ps2.server_sighting_id = ps1.server_sighting_id AND ps2.player_id != ps1.player_id
+		JOIN players p2 ON ps2.player_id = p2.id
+		WHERE LOWER(p1.name) = LOWER(?)
+			AND NOT EXISTS (SELECT 1 FROM opted_out_players o WHERE o.player_name = p2.name COLLATE NOCASE)
+	)
+	SELECT companion,
+		SUM(julianday(overlap_end) - julianday(overlap_start)) * 86400 AS overlap,
+		COUNT(*) AS sessions,
+		MAX(overlap_end) AS last_together,
+		COUNT(*) OVER () AS total
+	FROM overlaps
+	WHERE overlap_end > overlap_start
+	GROUP BY companion
+	ORDER BY overlap DESC, companion ASC
+	LIMIT ? OFFSET ?
+	`
+	if limit <= 0 {
+		limit = -1 // SQLite: no limit
+	}
+	rows, err := ReadDB.Query(query, formatTime(from), now, now, formatTime(to), name, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var companions []models.Companion
+	var total int
+	for rows.Next() {
+		var companion models.Companion
+		var overlap float64
+		var lastTogether string
+		if err := rows.Scan(&companion.Player, &overlap, &companion.Sessions, &lastTogether, &total); err != nil {
+			return nil, 0, fmt.Errorf("row scan failed: %w", err)
+		}
+		companion.Overlap = time.Duration(overlap * float64(time.Second)).Round(time.Millisecond)
+		if companion.LastTogether, err = parseTime(lastTogether); err != nil {
+			return nil, 0, err
+		}
+		companions = append(companions, companion)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// Past the last page there is no row to carry the total
+	if len(companions) == 0 && offset > 0 {
+		_, total, err = GetCompanions(name, from, to, 1, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return companions, total, nil
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// RecordScrape notes that the scraper completed a scrape at the given time.
+// The latest coverage span is extended when it ended no more than maxGap
+// earlier, otherwise the scraper was down in between and a new span starts.
+func RecordScrape(at time.Time, maxGap time.Duration) error {
+	return write(func() error {
+		return recordCoverage(DB, at, maxGap)
+	})
+}
+
+// execQueryRower is what recordCoverage needs from either DB or a transaction.
+type execQueryRower interface {
+	queryRower
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func recordCoverage(q execQueryRower, at time.Time, maxGap time.Duration) error {
+	var id int64
+	var endedAt string
+	err := q.QueryRow(`
+		SELECT id, ended_at FROM scrape_coverage ORDER BY ended_at DESC LIMIT 1
+	`).Scan(&id, &endedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read scrape coverage: %w", err)
+	}
+
+	if err == nil {
+		last, err := parseTime(endedAt)
+		if err != nil {
+			return err
+		}
+		if !at.Before(last) && at.Sub(last) <= maxGap {
+			if _, err := q.Exec(`UPDATE scrape_coverage SET ended_at = ? WHERE id = ?`, formatTime(at), id); err != nil {
+				return fmt.Errorf("failed to extend scrape coverage: %w", err)
+			}
+			return nil
+		}
+	}
+
+	_, err = q.Exec(`INSERT INTO scrape_coverage (started_at, ended_at) VALUES (?, ?)`, formatTime(at), formatTime(at))
+	if err != nil {
+		return fmt.Errorf("failed to insert scrape coverage: %w", err)
+	}
+	return nil
+}
+
+// GetScrapeCoverage returns the spans the scraper was running that overlap
+// [from, to], oldest first.
+func GetScrapeCoverage(from, to time.Time) ([]models.Interval, error) {
+	rows, err := ReadDB.Query(`
+		SELECT started_at, ended_at FROM scrape_coverage
+		WHERE started_at <= ? AND ended_at >= ?
+		ORDER BY started_at ASC
+	`, formatTime(to), formatTime(from))
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var coverage []models.Interval
+	for rows.Next() {
+		var span models.Interval
+		if err := rows.Scan(&span.Start, &span.End); err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		coverage = append(coverage, span)
+	}
+
+	return coverage, rows.Err()
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"teamacedia/minestalker/internal/models"
 	"time"
@@ -10,14 +11,32 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// DB is the single read-write connection. All mutations are funnelled through
+// the writer goroutine (see write), which is the only user of DB after InitDB.
 var DB *sql.DB
 
-func InitDB(path string) error {
+// ErrNotFound is wrapped by lookups that found nothing, check it with errors.Is.
+var ErrNotFound = errors.New("not found")
+
+// ReadDB is a read-only connection pool used by API handlers and bot commands.
+// With WAL enabled its queries never block on, or get blocked by, the writer.
+var ReadDB *sql.DB
+
+// InitDB opens the database, creates the schema and starts the writer
+// goroutine. busyTimeout is how long, in milliseconds, a connection waits on a
+// locked database before failing with "database is locked".
+func InitDB(path string, busyTimeout int) error {
 	var err error
-	DB, err = sql.Open("sqlite3", path)
+	// Foreign keys are declared in the schema but SQLite only enforces them
+	// when asked to, per connection.
+	DB, err = sql.Open("sqlite3", fmt.Sprintf(
+		"file:%s?_foreign_keys=on&_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=%d&_txlock=immediate",
+		path, busyTimeout,
+	))
 	if err != nil {
 		return err
 	}
+	DB.SetMaxOpenConns(1)
 
 	schema := `
 	CREATE TABLE IF NOT EXISTS servers (
@@ -86,12 +105,120 @@ func InitDB(path string) error {
 		discord_id TEXT NOT NULL,
 		UNIQUE(server_address, server_port, discord_id)
 	);
+
+	CREATE TABLE IF NOT EXISTS opted_out_players (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		player_name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		reason TEXT,
+		added_by TEXT,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS player_erasures (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		player_name TEXT NOT NULL,
+		requested_by TEXT,
+		erased_at DATETIME NOT NULL,
+		sightings_deleted INTEGER NOT NULL,
+		snapshot_mentions_removed INTEGER NOT NULL,
+		alerts_deleted INTEGER NOT NULL
+	);
+
+	-- Spans of time the scraper was running, extended by every successful scrape
+	CREATE TABLE IF NOT EXISTS scrape_coverage (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		started_at DATETIME NOT NULL,
+		ended_at DATETIME NOT NULL
+	);
+
+	-- Leaderboard aggregates, see leaderboards.go
+	CREATE TABLE IF NOT EXISTS player_daily_playtime (
+		day TEXT NOT NULL,
+		player_id INTEGER NOT NULL,
+		server_id INTEGER NOT NULL,
+		seconds REAL NOT NULL,
+		PRIMARY KEY(day, player_id, server_id)
+	) WITHOUT ROWID;
+
+	CREATE TABLE IF NOT EXISTS server_daily_stats (
+		day TEXT NOT NULL,
+		server_id INTEGER NOT NULL,
+		peak_players INTEGER NOT NULL DEFAULT 0,
+		longest_streak_seconds REAL NOT NULL DEFAULT 0,
+		PRIMARY KEY(day, server_id)
+	) WITHOUT ROWID;
+
+	-- Outbound webhooks, players, servers and types are comma separated filters
+	CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		players TEXT NOT NULL DEFAULT '',
+		servers TEXT NOT NULL DEFAULT '',
+		types TEXT NOT NULL DEFAULT '',
+		enabled INTEGER NOT NULL DEFAULT 1,
+		consecutive_failures INTEGER NOT NULL DEFAULT 0,
+		disabled_reason TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		subscription_id INTEGER NOT NULL,
+		event_id INTEGER NOT NULL,
+		event_type TEXT NOT NULL,
+		attempt INTEGER NOT NULL,
+		status_code INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		duration_ms INTEGER NOT NULL,
+		delivered_at DATETIME NOT NULL
+	);
+
+	-- Keys of the HTTP API, see apikeys.go
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		key_hash TEXT NOT NULL UNIQUE,
+		prefix TEXT NOT NULL,
+		scopes TEXT NOT NULL,
+		rate_limit INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL,
+		revoked_at DATETIME
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_server_sightings_server ON server_sightings(server_id, seen_at);
+	CREATE INDEX IF NOT EXISTS idx_player_sightings_server_sighting ON player_sightings(server_sighting_id);
+	CREATE INDEX IF NOT EXISTS idx_player_sightings_player ON player_sightings(player_id, seen_at);
+	CREATE INDEX IF NOT EXISTS idx_snapshots_timestamp ON snapshots(timestamp);
+	CREATE INDEX IF NOT EXISTS idx_snapshot_servers_snapshot ON snapshot_servers(snapshot_id);
+	CREATE INDEX IF NOT EXISTS idx_scrape_coverage_ended ON scrape_coverage(ended_at);
+	CREATE INDEX IF NOT EXISTS idx_server_sightings_open ON server_sightings(server_id) WHERE disconnected_at IS NULL;
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription ON webhook_deliveries(subscription_id, id);
 	`
 	_, err = DB.Exec(schema)
 	if err != nil {
 		return fmt.Errorf("failed to create schema: %w", err)
 	}
 
+	if err = runMigrations(); err != nil {
+		return err
+	}
+
+	// The read pool is opened after the schema exists, read-only connections
+	// cannot create the database file.
+	ReadDB, err = sql.Open("sqlite3", fmt.Sprintf(
+		"file:%s?mode=ro&_foreign_keys=on&_busy_timeout=%d",
+		path, busyTimeout,
+	))
+	if err != nil {
+		return err
+	}
+	if err = ReadDB.Ping(); err != nil {
+		return fmt.Errorf("failed to open read-only pool: %w", err)
+	}
+
+	go runWriter()
+
 	return nil
 }
 
@@ -104,7 +231,7 @@ func CheckIfServerTrackingAlertExists(alert models.ServerTrackingAlert) bool {
 		WHERE server_address = ? AND server_port = ? AND discord_id = ?
 	)
 	`
-	err := DB.QueryRow(query, alert.ServerAddress, alert.ServerPort, alert.DiscordID).Scan(&exists)
+	err := ReadDB.QueryRow(query, alert.ServerAddress, alert.ServerPort, alert.DiscordID).Scan(&exists)
 	if err != nil {
 		fmt.Printf("Error checking server tracking alert: %v\n", err)
 		return false
@@ -114,21 +241,25 @@ func CheckIfServerTrackingAlertExists(alert models.ServerTrackingAlert) bool {
 
 // AddServerTrackingAlert inserts a new server tracking alert.
 func AddServerTrackingAlert(alert models.ServerTrackingAlert) error {
-	_, err := DB.Exec(`
-		INSERT INTO server_tracking_alerts (server_address, server_port, discord_id)
-		VALUES (?, ?, ?)
-		ON CONFLICT(server_address, server_port, discord_id) DO NOTHING
-	`, alert.ServerAddress, alert.ServerPort, alert.DiscordID)
-	return err
+	return write(func() error {
+		_, err := DB.Exec(`
+			INSERT INTO server_tracking_alerts (server_address, server_port, discord_id)
+			VALUES (?, ?, ?)
+			ON CONFLICT(server_address, server_port, discord_id) DO NOTHING
+		`, alert.ServerAddress, alert.ServerPort, alert.DiscordID)
+		return err
+	})
 }
 
 // RemoveServerTrackingAlert removes a server tracking alert.
 func RemoveServerTrackingAlert(alert models.ServerTrackingAlert) error {
-	_, err := DB.Exec(`
-		DELETE FROM server_tracking_alerts
-		WHERE server_address = ? AND server_port = ? AND discord_id = ?
-	`, alert.ServerAddress, alert.ServerPort, alert.DiscordID)
-	return err
+	return write(func() error {
+		_, err := DB.Exec(`
+			DELETE FROM server_tracking_alerts
+			WHERE server_address = ? AND server_port = ? AND discord_id = ?
+		`, alert.ServerAddress, alert.ServerPort, alert.DiscordID)
+		return err
+	})
 }
 
 // GetServerTrackingAlerts retrieves all server tracking alerts for a specific Discord ID.
@@ -138,7 +269,7 @@ func GetServerTrackingAlerts(discordId string) ([]models.ServerTrackingAlert, er
 	FROM server_tracking_alerts
 	WHERE discord_id = ?
 	`
-	rows, err := DB.Query(query, discordId)
+	rows, err := ReadDB.Query(query, discordId)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -162,7 +293,7 @@ func GetAllServerTrackingAlerts() ([]models.ServerTrackingAlert, error) {
 	SELECT id, server_address, server_port, discord_id
 	FROM server_tracking_alerts
 	`
-	rows, err := DB.Query(query)
+	rows, err := ReadDB.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -189,7 +320,7 @@ func CheckIfTrackingAlertExists(alert models.TrackingAlert) bool {
 		WHERE player_name = ? AND discord_id = ?
 	)
 	`
-	err := DB.QueryRow(query, alert.PlayerName, alert.DiscordID).Scan(&exists)
+	err := ReadDB.QueryRow(query, alert.PlayerName, alert.DiscordID).Scan(&exists)
 	if err != nil {
 		fmt.Printf("Error checking tracking alert: %v\n", err)
 		return false
@@ -199,21 +330,25 @@ func CheckIfTrackingAlertExists(alert models.TrackingAlert) bool {
 
 // AddTrackingAlert inserts a new tracking alert.
 func AddTrackingAlert(alert models.TrackingAlert) error {
-	_, err := DB.Exec(`
-		INSERT INTO tracking_alerts (player_name, discord_id)
-		VALUES (?, ?)
-		ON CONFLICT(player_name, discord_id) DO NOTHING
-	`, alert.PlayerName, alert.DiscordID)
-	return err
+	return write(func() error {
+		_, err := DB.Exec(`
+			INSERT INTO tracking_alerts (player_name, discord_id)
+			VALUES (?, ?)
+			ON CONFLICT(player_name, discord_id) DO NOTHING
+		`, alert.PlayerName, alert.DiscordID)
+		return err
+	})
 }
 
 // RemoveTrackingAlert removes a tracking alert.
 func RemoveTrackingAlert(alert models.TrackingAlert) error {
-	_, err := DB.Exec(`
-		DELETE FROM tracking_alerts
-		WHERE player_name = ? AND discord_id = ?
-	`, alert.PlayerName, alert.DiscordID)
-	return err
+	return write(func() error {
+		_, err := DB.Exec(`
+			DELETE FROM tracking_alerts
+			WHERE player_name = ? AND discord_id = ?
+		`, alert.PlayerName, alert.DiscordID)
+		return err
+	})
 }
 
 // GetTrackingAlerts retrieves all tracking alerts.
@@ -223,7 +358,7 @@ func GetTrackingAlerts(discordId string) ([]models.TrackingAlert, error) {
 	FROM tracking_alerts
 	WHERE discord_id = ?
 	`
-	rows, err := DB.Query(query, discordId)
+	rows, err := ReadDB.Query(query, discordId)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -247,7 +382,7 @@ func GetAllTrackingAlerts() ([]models.TrackingAlert, error) {
 	SELECT id, player_name, discord_id
 	FROM tracking_alerts
 	`
-	rows, err := DB.Query(query)
+	rows, err := ReadDB.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -265,17 +400,20 @@ func GetAllTrackingAlerts() ([]models.TrackingAlert, error) {
 	return alerts, nil
 }
 
-// getOrCreateServer inserts the server if missing and returns its id.
-func getOrCreateServer(tx *sql.Tx, address string, port int, name, game string) (int64, error) {
+// getOrCreateServer inserts the server if missing and returns its id. seen
+// widens the first_seen/last_seen range, and the name and game are only
+// replaced, when given, if seen is the most recent sighting of the server.
+func getOrCreateServer(tx *sql.Tx, address string, port int, name, game string, seen time.Time) (int64, error) {
 	query := `
 	INSERT INTO servers (address, port, name, game, first_seen, last_seen)
-	VALUES (?, ?, ?, ?, datetime('now'), datetime('now'))
+	VALUES (?, ?, ?, ?, ?, ?)
 	ON CONFLICT(address, port) DO UPDATE SET
-		name = excluded.name,
-		game = excluded.game,
-		last_seen = datetime('now');
+		name = CASE WHEN excluded.last_seen >= COALESCE(servers.last_seen, '') AND excluded.name <> '' THEN excluded.name ELSE servers.name END,
+		game = CASE WHEN excluded.last_seen >= COALESCE(servers.last_seen, '') AND excluded.game <> '' THEN excluded.game ELSE servers.game END,
+		first_seen = MIN(COALESCE(servers.first_seen, excluded.first_seen), excluded.first_seen),
+		last_seen = MAX(COALESCE(servers.last_seen, excluded.last_seen), excluded.last_seen);
 	`
-	_, err := tx.Exec(query, address, port, name, game)
+	_, err := tx.Exec(query, address, port, name, game, formatTime(seen), formatTime(seen))
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert/update server: %w", err)
 	}
@@ -309,7 +447,14 @@ func getOrCreatePlayer(tx *sql.Tx, name string) (int64, error) {
 	return id, nil
 }
 
-func getActiveServerSighting(address string, port int) (int64, error) {
+// queryRower is satisfied by both *sql.DB and *sql.Tx. The write connection
+// pool holds a single connection, so helpers used inside a transaction must
+// query through that transaction rather than DB.
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func getActiveServerSighting(q queryRower, address string, port int) (int64, error) {
 	var sightingID int64
 	query := `
 	SELECT ss.id
@@ -318,7 +463,7 @@ func getActiveServerSighting(address string, port int) (int64, error) {
 	WHERE s.address = ? AND s.port = ? AND ss.disconnected_at IS NULL
 	LIMIT 1
 	`
-	err := DB.QueryRow(query, address, port).Scan(&sightingID)
+	err := q.QueryRow(query, address, port).Scan(&sightingID)
 	if err == sql.ErrNoRows {
 		return 0, nil // no active sighting
 	}
@@ -329,14 +474,14 @@ func getActiveServerSighting(address string, port int) (int64, error) {
 }
 
 // startServerSightingIfNeeded starts a new sighting only if no active sighting exists and returns sighting ID.
-func startServerSightingIfNeeded(address string, port int, name, game string) (int64, error) {
+func startServerSightingIfNeeded(address string, port int, name, game string, at time.Time) (int64, error) {
 	tx, err := DB.Begin()
 	if err != nil {
 		return 0, err
 	}
 	defer tx.Rollback()
 
-	serverID, err := getOrCreateServer(tx, address, port, name, game)
+	serverID, err := getOrCreateServer(tx, address, port, name, game, at)
 	if err != nil {
 		return 0, err
 	}
@@ -351,8 +496,8 @@ func startServerSightingIfNeeded(address string, port int, name, game string) (i
 	if err == sql.ErrNoRows {
 		// no active sighting, create one
 		res, err := tx.Exec(`
-			INSERT INTO server_sightings (server_id, seen_at) VALUES (?, datetime('now'))
-		`, serverID)
+			INSERT INTO server_sightings (server_id, seen_at) VALUES (?, ?)
+		`, serverID, formatTime(at))
 		if err != nil {
 			return 0, err
 		}
@@ -372,28 +517,17 @@ func startServerSightingIfNeeded(address string, port int, name, game string) (i
 }
 
 // stopServerSighting closes sighting and optionally closes all open player sightings for that server sighting.
-func stopServerSightingAndPlayers(serverSightingID int64) error {
+func stopServerSightingAndPlayers(serverSightingID int64, at time.Time) error {
 	tx, err := DB.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	_, err = tx.Exec(`
-		UPDATE server_sightings
-		SET disconnected_at = datetime('now')
-		WHERE id = ? AND disconnected_at IS NULL
-	`, serverSightingID)
-	if err != nil {
+	if err := closeServerSighting(tx, serverSightingID, at); err != nil {
 		return err
 	}
-
-	_, err = tx.Exec(`
-		UPDATE player_sightings
-		SET disconnected_at = datetime('now')
-		WHERE server_sighting_id = ? AND disconnected_at IS NULL
-	`, serverSightingID)
-	if err != nil {
+	if err := closePlayerSightings(tx, at, "ps.server_sighting_id = ?", serverSightingID); err != nil {
 		return err
 	}
 
@@ -401,14 +535,14 @@ func stopServerSightingAndPlayers(serverSightingID int64) error {
 }
 
 // startPlayerSighting creates a player sighting for given player and active server sighting.
-func startPlayerSighting(address string, port int, playerName string) (int64, error) {
+func startPlayerSighting(address string, port int, playerName string, at time.Time) (int64, error) {
 	tx, err := DB.Begin()
 	if err != nil {
 		return 0, err
 	}
 	defer tx.Rollback()
 
-	sightingID, err := getActiveServerSighting(address, port)
+	sightingID, err := getActiveServerSighting(tx, address, port)
 	if err != nil {
 		return 0, err
 	}
@@ -423,8 +557,8 @@ func startPlayerSighting(address string, port int, playerName string) (int64, er
 
 	res, err := tx.Exec(`
 		INSERT INTO player_sightings (server_sighting_id, player_id, seen_at)
-		VALUES (?, ?, datetime('now'))
-	`, sightingID, playerID)
+		VALUES (?, ?, ?)
+	`, sightingID, playerID, formatTime(at))
 	if err != nil {
 		return 0, err
 	}
@@ -434,12 +568,22 @@ func startPlayerSighting(address string, port int, playerName string) (int64, er
 		return 0, err
 	}
 
+	if err := recordPeak(tx, sightingID, at); err != nil {
+		return 0, err
+	}
+
 	return playerSightingID, tx.Commit()
 }
 
 // stopPlayerSighting closes the player's sighting for current server sighting.
-func stopPlayerSighting(address string, port int, playerName string) error {
-	sightingID, err := getActiveServerSighting(address, port)
+func stopPlayerSighting(address string, port int, playerName string, at time.Time) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sightingID, err := getActiveServerSighting(tx, address, port)
 	if err != nil {
 		return err
 	}
@@ -448,46 +592,70 @@ func stopPlayerSighting(address string, port int, playerName string) error {
 	}
 
 	var playerID int64
-	err = DB.QueryRow("SELECT id FROM players WHERE name = ?", playerName).Scan(&playerID)
+	err = tx.QueryRow("SELECT id FROM players WHERE name = ?", playerName).Scan(&playerID)
 	if err != nil {
 		return err
 	}
 
-	_, err = DB.Exec(`
-		UPDATE player_sightings
-		SET disconnected_at = datetime('now')
-		WHERE server_sighting_id = ? AND player_id = ? AND disconnected_at IS NULL
-	`, sightingID, playerID)
-	return err
+	err = closePlayerSightings(tx, at, "ps.server_sighting_id = ? AND ps.player_id = ?", sightingID, playerID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
+// HandleEvent records a tracking event, queued behind any other pending writes.
 func HandleEvent(event models.TrackingEvent) error {
+	return write(func() error {
+		return handleEvent(event)
+	})
+}
+
+func handleEvent(event models.TrackingEvent) error {
+	at := eventTime(event.Timestamp)
+
 	switch event.Type {
 	case "serverOnline":
-		_, err := startServerSightingIfNeeded(event.Server, event.Port, event.Name, event.Game)
+		_, err := startServerSightingIfNeeded(event.Server, event.Port, event.Name, event.Game, at)
 		return err
 
 	case "serverOffline":
-		sightingID, err := getActiveServerSighting(event.Server, event.Port)
+		sightingID, err := getActiveServerSighting(DB, event.Server, event.Port)
 		if err != nil || sightingID == 0 {
 			return err
 		}
-		return stopServerSightingAndPlayers(sightingID)
+		return stopServerSightingAndPlayers(sightingID, at)
 
 	case "playerJoin":
-		_, err := startPlayerSighting(event.Server, event.Port, event.Player)
+		_, err := startPlayerSighting(event.Server, event.Port, event.Player, at)
 		return err
 
 	case "playerLeave":
-		return stopPlayerSighting(event.Server, event.Port, event.Player)
+		return stopPlayerSighting(event.Server, event.Port, event.Player, at)
 	}
 
 	return nil
 }
 
 func GetPlayerHistory(name string) ([]models.PlayerSighting, error) {
+	var history []models.PlayerSighting
+	err := EachPlayerSighting(name, func(sighting models.PlayerSighting) error {
+		history = append(history, sighting)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// EachPlayerSighting calls fn for every sighting of a player, newest first,
+// as they are read from the database. An error from fn stops the iteration
+// and is returned.
+func EachPlayerSighting(name string, fn func(models.PlayerSighting) error) error {
 	query := `
-	SELECT ps.seen_at, ps.disconnected_at, s.address, s.port
+	SELECT ps.seen_at, ps.disconnected_at, s.address, s.port, COALESCE(s.name, ''), COALESCE(s.game, '')
 	FROM player_sightings ps
 	JOIN players p ON ps.player_id = p.id
 	JOIN server_sightings ss ON ps.server_sighting_id = ss.id
@@ -495,30 +663,31 @@ func GetPlayerHistory(name string) ([]models.PlayerSighting, error) {
 	WHERE LOWER(p.name) = LOWER(?)
 	ORDER BY ps.seen_at DESC
 	`
-	rows, err := DB.Query(query, name)
+	rows, err := ReadDB.Query(query, name)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var history []models.PlayerSighting
 	for rows.Next() {
 		var event models.PlayerSighting
 		event.Player = name
 		var disconnectedAt sql.NullTime
-		err := rows.Scan(&event.ConnectedAt, &disconnectedAt, &event.Address, &event.Port)
+		err := rows.Scan(&event.ConnectedAt, &disconnectedAt, &event.Address, &event.Port, &event.ServerName, &event.Game)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if disconnectedAt.Valid {
 			event.DisconnectedAt = &disconnectedAt.Time
 		} else {
 			event.DisconnectedAt = nil
 		}
-		history = append(history, event)
+		if err := fn(event); err != nil {
+			return err
+		}
 	}
 
-	return history, nil
+	return rows.Err()
 }
 
 func GetServerHistory(address string, port int) ([]models.ServerSighting, error) {
@@ -529,7 +698,7 @@ func GetServerHistory(address string, port int) ([]models.ServerSighting, error)
 	WHERE s.address = ? AND s.port = ?
 	ORDER BY ss.seen_at DESC
 	`
-	rows, err := DB.Query(query, address, port)
+	rows, err := ReadDB.Query(query, address, port)
 	if err != nil {
 		return nil, err
 	}
@@ -554,15 +723,36 @@ func GetServerHistory(address string, port int) ([]models.ServerSighting, error)
 	return history, nil
 }
 
+// SaveSnapshot stores a copy of the server list, queued behind any other pending writes.
 func SaveSnapshot(snapshot models.Snapshot) error {
+	return write(func() error {
+		return saveSnapshot(snapshot)
+	})
+}
+
+func saveSnapshot(snapshot models.Snapshot) error {
 	tx, err := DB.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	if err := insertSnapshot(tx, snapshot); err != nil {
+		return err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// insertSnapshot writes a snapshot and its servers inside tx.
+func insertSnapshot(tx *sql.Tx, snapshot models.Snapshot) error {
 	// Insert the snapshot timestamp
-	res, err := tx.Exec(`INSERT INTO snapshots (timestamp) VALUES (?)`, snapshot.Time)
+	res, err := tx.Exec(`INSERT INTO snapshots (timestamp) VALUES (?)`, formatTime(snapshot.Time))
 	if err != nil {
 		return fmt.Errorf("failed to insert snapshot: %w", err)
 	}
@@ -595,31 +785,39 @@ func SaveSnapshot(snapshot models.Snapshot) error {
 		}
 	}
 
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit snapshot: %w", err)
-	}
-
 	return nil
 }
 
 func GetSnapshotHistoryForServer(address string, port int) ([]models.Snapshot, error) {
+	var snapshots []models.Snapshot
+	err := EachServerSnapshot(address, port, func(snapshot models.Snapshot) error {
+		snapshots = append(snapshots, snapshot)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// EachServerSnapshot calls fn for every snapshot listing a server, newest
+// first, with the server as its only entry. An error from fn stops the
+// iteration and is returned.
+func EachServerSnapshot(address string, port int, fn func(models.Snapshot) error) error {
 	query := `
-	SELECT snap.timestamp, s.name, s.game, s.mods, s.clients, s.player_list
+	SELECT snap.timestamp, s.name, s.game, s.clients, s.player_list
 	FROM snapshot_servers s
 	JOIN snapshots snap ON s.snapshot_id = snap.id
 	WHERE s.address = ? AND s.port = ?
 	ORDER BY snap.timestamp DESC
 	`
 
-	rows, err := DB.Query(query, address, port)
+	rows, err := ReadDB.Query(query, address, port)
 	if err != nil {
-		return nil, fmt.Errorf("query failed: %w", err)
+		return fmt.Errorf("query failed: %w", err)
 	}
 	defer rows.Close()
 
-	var snapshots []models.Snapshot
-
 	for rows.Next() {
 		var snapshot models.Snapshot
 		var server models.Server
@@ -633,7 +831,7 @@ func GetSnapshotHistoryForServer(address string, port int) ([]models.Snapshot, e
 			&playerListJSON,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("row scan failed: %w", err)
+			return fmt.Errorf("row scan failed: %w", err)
 		}
 
 		server.Address = address
@@ -641,77 +839,59 @@ func GetSnapshotHistoryForServer(address string, port int) ([]models.Snapshot, e
 
 		err = json.Unmarshal([]byte(playerListJSON), &server.PlayerList)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse player list JSON: %w", err)
+			return fmt.Errorf("failed to parse player list JSON: %w", err)
 		}
 
 		snapshot.Servers = []models.Server{server}
-		snapshots = append(snapshots, snapshot)
+		if err := fn(snapshot); err != nil {
+			return err
+		}
 	}
 
-	return snapshots, nil
+	return rows.Err()
 }
 
+// GetSnapshotByTime returns the latest snapshot taken at or before t, wrapping
+// ErrNotFound when there is none.
 func GetSnapshotByTime(t time.Time) (models.Snapshot, error) {
-	query := `
-	SELECT s.address, s.port, s.name, s.game, s.clients, s.player_list
-	FROM snapshot_servers s
-	JOIN snapshots snap ON s.snapshot_id = snap.id
-	WHERE snap.timestamp = ?
-	`
-
-	rows, err := DB.Query(query, t)
+	var snapshotID int64
+	var snapshotTime time.Time
+	err := ReadDB.QueryRow(`
+		SELECT id, timestamp FROM snapshots WHERE timestamp <= ? ORDER BY timestamp DESC LIMIT 1
+	`, formatTime(t)).Scan(&snapshotID, &snapshotTime)
+	if err == sql.ErrNoRows {
+		return models.Snapshot{}, fmt.Errorf("no snapshot at or before %s: %w", formatTime(t), ErrNotFound)
+	}
 	if err != nil {
 		return models.Snapshot{}, fmt.Errorf("query failed: %w", err)
 	}
-	defer rows.Close()
-
-	var servers []models.Server
-
-	for rows.Next() {
-		var server models.Server
-		var playerListJSON string
 
-		err := rows.Scan(
-			&server.Address,
-			&server.Port,
-			&server.Name,
-			&server.Game,
-			&server.Clients,
-			&playerListJSON,
-		)
-		if err != nil {
-			return models.Snapshot{}, fmt.Errorf("row scan failed: %w", err)
-		}
-
-		err = json.Unmarshal([]byte(playerListJSON), &server.PlayerList)
-		if err != nil {
-			return models.Snapshot{}, fmt.Errorf("failed to parse player list JSON: %w", err)
-		}
-
-		servers = append(servers, server)
-	}
-
-	return models.Snapshot{
-		Time:    t,
-		Servers: servers,
-	}, nil
+	return getSnapshot(snapshotID, snapshotTime)
 }
 
 func GetLatestSnapshot() (models.Snapshot, error) {
 	var snapshotID int64
 	var snapshotTime time.Time
-	err := DB.QueryRow(`SELECT id, timestamp FROM snapshots ORDER BY timestamp DESC LIMIT 1`).Scan(&snapshotID, &snapshotTime)
+	err := ReadDB.QueryRow(`SELECT id, timestamp FROM snapshots ORDER BY timestamp DESC LIMIT 1`).Scan(&snapshotID, &snapshotTime)
+	if err == sql.ErrNoRows {
+		return models.Snapshot{}, fmt.Errorf("no snapshots taken yet: %w", ErrNotFound)
+	}
 	if err != nil {
 		return models.Snapshot{}, fmt.Errorf("query failed: %w", err)
 	}
 
+	return getSnapshot(snapshotID, snapshotTime)
+}
+
+// getSnapshot loads the servers of the snapshot with the given id.
+func getSnapshot(snapshotID int64, snapshotTime time.Time) (models.Snapshot, error) {
 	query := `
 	SELECT address, port, name, game, clients, player_list
 	FROM snapshot_servers
 	WHERE snapshot_id = ?
 	`
 
-	rows, err := DB.Query(query, snapshotID)
+	rows, err := ReadDB.Query(query, snapshotID)
 	if err != nil {
 		return models.Snapshot{}, fmt.Errorf("query failed: %w", err)
 	}
@@ -746,7 +926,7 @@ func GetLatestSnapshot() (models.Snapshot, error) {
 	return models.Snapshot{
 		Time:    snapshotTime,
 		Servers: servers,
-	}, nil
+	}, rows.Err()
 }
 
 func GetServerInfo(address string, port int) (models.Server, error) {
@@ -756,10 +936,10 @@ func GetServerInfo(address string, port int) (models.Server, error) {
 	FROM servers
 	WHERE address = ? AND port = ?
 	`
-	err := DB.QueryRow(query, address, port).Scan(&server.Name, &server.Game)
+	err := ReadDB.QueryRow(query, address, port).Scan(&server.Name, &server.Game)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return models.Server{}, fmt.Errorf("server not found: %s:%d", address, port)
+			return models.Server{}, fmt.Errorf("server %s:%d: %w", address, port, ErrNotFound)
 		}
 		return models.Server{}, fmt.Errorf("query failed: %w", err)
 	}
@@ -0,0 +1,348 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// DoctorIssue describes one class of integrity problem found by RunDoctor.
+type DoctorIssue struct {
+	Check       string
+	Description string
+	IDs         []int64 // Offending row ids in the table the check inspects
+	Fixed       int64   // Rows changed by the repair, only set in fix mode
+}
+
+// DoctorReport is the result of a RunDoctor pass.
+type DoctorReport struct {
+	Issues []DoctorIssue
+	Fixed  bool
+}
+
+// HasIssues reports whether any check found at least one offending row.
+func (r DoctorReport) HasIssues() bool {
+	for _, issue := range r.Issues {
+		if len(issue.IDs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// doctorCheck is a single integrity check. detect selects the ids of the
+// offending rows; every statement in fix is formatted with detect so it can
+// use it as a subquery, and is run in order inside the repair transaction.
+type doctorCheck struct {
+	name        string
+	description string
+	detect      string
+	fix         []string
+}
+
+// doctorChecks are run in order. Orphans are removed first so the later checks
+// only ever look at rows that belong to something, and open player sightings
+// are closed last so they pick up the server sighting end times set above.
+// Times are compared through julianday() rather than as text so rows the
+// timestamp migration could not normalise are still ordered correctly.
+var doctorChecks = []doctorCheck{
+	{
+		name:        "orphan_server_sightings",
+		description: "server sightings pointing at a server that does not exist",
+		detect: `
+		SELECT ss.id FROM server_sightings ss
+		LEFT JOIN servers s ON ss.server_id = s.id
+		WHERE s.id IS NULL`,
+		fix: []string{
+			`DELETE FROM player_sightings WHERE server_sighting_id IN (%s)`,
+			`DELETE FROM server_sightings WHERE id IN (%s)`,
+		},
+	},
+	{
+		name:        "orphan_player_sightings",
+		description: "player sightings pointing at a missing player or server sighting",
+		detect: `
+		SELECT ps.id FROM player_sightings ps
+		LEFT JOIN server_sightings ss ON ps.server_sighting_id = ss.id
+		LEFT JOIN players p ON ps.player_id = p.id
+		WHERE ss.id IS NULL OR p.id IS NULL`,
+		fix: []string{
+			`DELETE FROM player_sightings WHERE id IN (%s)`,
+		},
+	},
+	{
+		name:        "orphan_snapshot_servers",
+		description: "snapshot rows pointing at a snapshot that does not exist",
+		detect: `
+		SELECT s.id FROM snapshot_servers s
+		LEFT JOIN snapshots snap ON s.snapshot_id = snap.id
+		WHERE snap.id IS NULL`,
+		fix: []string{
+			`DELETE FROM snapshot_servers WHERE id IN (%s)`,
+		},
+	},
+	{
+		name:        "reversed_server_sightings",
+		description: "server sightings with disconnected_at before seen_at",
+		detect: `
+		SELECT id FROM server_sightings
+		WHERE disconnected_at IS NOT NULL AND julianday(disconnected_at) < julianday(seen_at)`,
+		fix: []string{
+			`UPDATE server_sightings SET disconnected_at = seen_at WHERE id IN (%s)`,
+		},
+	},
+	{
+		name:        "reversed_player_sightings",
+		description: "player sightings with disconnected_at before seen_at",
+		detect: `
+		SELECT id FROM player_sightings
+		WHERE disconnected_at IS NOT NULL AND julianday(disconnected_at) < julianday(seen_at)`,
+		fix: []string{
+			`UPDATE player_sightings SET disconnected_at = seen_at WHERE id IN (%s)`,
+		},
+	},
+	{
+		name:        "duplicate_open_server_sightings",
+		description: "open server sightings superseded by a newer open sighting of the same server",
+		detect: `
+		SELECT ss.id FROM server_sightings ss
+		WHERE ss.disconnected_at IS NULL AND EXISTS (
+			SELECT 1 FROM server_sightings n
+			WHERE n.server_id = ss.server_id AND n.disconnected_at IS NULL AND n.id > ss.id
+		)`,
+		fix: []string{
+			`UPDATE server_sightings SET disconnected_at = COALESCE((
+				SELECT n.seen_at FROM server_sightings n
+				WHERE n.server_id = server_sightings.server_id AND n.disconnected_at IS NULL AND n.id > server_sightings.id
+				ORDER BY julianday(n.seen_at) LIMIT 1
+			), seen_at)
+			WHERE id IN (%s)`,
+		},
+	},
+	{
+		name:        "overlapping_server_sightings",
+		description: "server sightings overlapping a later sighting of the same server",
+		detect: `
+		SELECT DISTINCT a.id FROM server_sightings a
+		JOIN server_sightings b ON b.server_id = a.server_id AND b.id <> a.id
+		WHERE (julianday(b.seen_at) > julianday(a.seen_at) OR (julianday(b.seen_at) = julianday(a.seen_at) AND b.id > a.id))
+			AND (a.disconnected_at IS NULL OR julianday(b.seen_at) < julianday(a.disconnected_at))`,
+		fix: []string{
+			`UPDATE server_sightings SET disconnected_at = (
+				SELECT b.seen_at FROM server_sightings b
+				WHERE b.server_id = server_sightings.server_id AND b.id <> server_sightings.id
+					AND (julianday(b.seen_at) > julianday(server_sightings.seen_at)
+						OR (julianday(b.seen_at) = julianday(server_sightings.seen_at) AND b.id > server_sightings.id))
+				ORDER BY julianday(b.seen_at) LIMIT 1
+			)
+			WHERE id IN (%s)`,
+		},
+	},
+	{
+		name:        "stale_open_server_sightings",
+		description: "open server sightings for servers missing from the latest snapshot",
+		detect: `
+		SELECT ss.id FROM server_sightings ss
+		JOIN servers s ON ss.server_id = s.id
+		JOIN (SELECT id, timestamp FROM snapshots ORDER BY julianday(timestamp) DESC LIMIT 1) latest
+		WHERE ss.disconnected_at IS NULL
+			AND julianday(ss.seen_at) < julianday(latest.timestamp)
+			AND NOT EXISTS (
+				SELECT 1 FROM snapshot_servers sv
+				WHERE sv.snapshot_id = latest.id AND sv.address = s.address AND sv.port = s.port
+			)`,
+		fix: []string{
+			// Close at the last snapshot that still listed the server, or
+			// at seen_at when no snapshot after the sighting started did.
+			`UPDATE server_sightings SET disconnected_at = COALESCE((
+				SELECT snap.timestamp FROM snapshots snap
+				JOIN snapshot_servers sv ON sv.snapshot_id = snap.id
+				JOIN servers s ON sv.address = s.address AND sv.port = s.port
+				WHERE s.id = server_sightings.server_id
+					AND julianday(snap.timestamp) >= julianday(server_sightings.seen_at)
+				ORDER BY julianday(snap.timestamp) DESC LIMIT 1
+			), seen_at)
+			WHERE id IN (%s)`,
+		},
+	},
+	{
+		name:        "open_player_sightings_on_closed_server",
+		description: "open player sightings whose server sighting is already closed",
+		detect: `
+		SELECT ps.id FROM player_sightings ps
+		JOIN server_sightings ss ON ps.server_sighting_id = ss.id
+		WHERE ps.disconnected_at IS NULL AND ss.disconnected_at IS NOT NULL`,
+		fix: []string{
+			// Never close before the player was seen, that would only
+			// trade this problem for a reversed sighting.
+			`UPDATE player_sightings SET disconnected_at = (
+				SELECT CASE WHEN julianday(ss.disconnected_at) < julianday(player_sightings.seen_at)
+					THEN player_sightings.seen_at ELSE ss.disconnected_at END
+				FROM server_sightings ss
+				WHERE ss.id = player_sightings.server_sighting_id
+			)
+			WHERE id IN (%s)`,
+		},
+	},
+	{
+		name:        "overlapping_player_sightings",
+		description: "player sightings overlapping a later sighting of the same player on the same server sighting",
+		detect: `
+		SELECT DISTINCT a.id FROM player_sightings a
+		JOIN player_sightings b ON b.player_id = a.player_id AND b.server_sighting_id = a.server_sighting_id AND b.id <> a.id
+		WHERE (julianday(b.seen_at) > julianday(a.seen_at) OR (julianday(b.seen_at) = julianday(a.seen_at) AND b.id > a.id))
+			AND (a.disconnected_at IS NULL OR julianday(b.seen_at) < julianday(a.disconnected_at))`,
+		fix: []string{
+			`UPDATE player_sightings SET disconnected_at = (
+				SELECT b.seen_at FROM player_sightings b
+				WHERE b.player_id = player_sightings.player_id
+					AND b.server_sighting_id = player_sightings.server_sighting_id
+					AND b.id <> player_sightings.id
+					AND (julianday(b.seen_at) > julianday(player_sightings.seen_at)
+						OR (julianday(b.seen_at) = julianday(player_sightings.seen_at) AND b.id > player_sightings.id))
+				ORDER BY julianday(b.seen_at) LIMIT 1
+			)
+			WHERE id IN (%s)`,
+		},
+	},
+}
+
+// RunDoctor checks the database for integrity problems. With fix unset it only
+// reports what it finds; with fix set every check is repaired in a single
+// transaction, each one re-detecting its rows after the previous repairs.
+func RunDoctor(fix bool) (DoctorReport, error) {
+	report := DoctorReport{Fixed: fix}
+
+	if !fix {
+		for _, check := range doctorChecks {
+			ids, err := detectIDs(check.detect)
+			if err != nil {
+				return report, fmt.Errorf("check %s failed: %w", check.name, err)
+			}
+			report.Issues = append(report.Issues, DoctorIssue{
+				Check:       check.name,
+				Description: check.description,
+				IDs:         ids,
+			})
+		}
+		return report, nil
+	}
+
+	err := write(func() error {
+		var err error
+		report.Issues, err = repair()
+		return err
+	})
+	return report, err
+}
+
+// repair runs every check and its fix inside one transaction on the writer goroutine.
+func repair() ([]DoctorIssue, error) {
+	var issues []DoctorIssue
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, check := range doctorChecks {
+		rows, err := tx.Query(check.detect)
+		if err != nil {
+			return nil, fmt.Errorf("check %s failed: %w", check.name, err)
+		}
+		ids, err := scanIDs(rows)
+		if err != nil {
+			return nil, fmt.Errorf("check %s failed: %w", check.name, err)
+		}
+
+		issue := DoctorIssue{
+			Check:       check.name,
+			Description: check.description,
+			IDs:         ids,
+		}
+		if len(ids) > 0 {
+			for _, stmt := range check.fix {
+				res, err := tx.Exec(fmt.Sprintf(stmt, check.detect))
+				if err != nil {
+					return nil, fmt.Errorf("repair %s failed: %w", check.name, err)
+				}
+				n, err := res.RowsAffected()
+				if err != nil {
+					return nil, fmt.Errorf("repair %s failed: %w", check.name, err)
+				}
+				issue.Fixed += n
+			}
+		}
+		issues = append(issues, issue)
+	}
+
+	// Repairs close, shorten and delete sightings the aggregates were built from
+	if err := rebuildLeaderboards(tx); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit repairs: %w", err)
+	}
+	return issues, nil
+}
+
+func detectIDs(query string) ([]int64, error) {
+	rows, err := ReadDB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	return scanIDs(rows)
+}
+
+func scanIDs(rows *sql.Rows) ([]int64, error) {
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// FormatDoctorReport renders a report as human readable text.
+func FormatDoctorReport(report DoctorReport) string {
+	var b strings.Builder
+	for _, issue := range report.Issues {
+		status := "ok"
+		if len(issue.IDs) > 0 {
+			status = fmt.Sprintf("%d found", len(issue.IDs))
+			if report.Fixed {
+				status += fmt.Sprintf(", %d rows repaired", issue.Fixed)
+			}
+		}
+		fmt.Fprintf(&b, "%-40s %s\n", issue.Check, status)
+		if len(issue.IDs) > 0 {
+			fmt.Fprintf(&b, "    %s\n", issue.Description)
+			fmt.Fprintf(&b, "    ids: %s\n", formatIDs(issue.IDs, 20))
+		}
+	}
+	if !report.HasIssues() {
+		b.WriteString("No problems found.\n")
+	} else if !report.Fixed {
+		b.WriteString("Dry run, nothing was changed. Run again with -fix to repair.\n")
+	}
+	return b.String()
+}
+
+func formatIDs(ids []int64, limit int) string {
+	parts := make([]string, 0, limit)
+	for i, id := range ids {
+		if i == limit {
+			parts = append(parts, fmt.Sprintf("... (%d more)", len(ids)-limit))
+			break
+		}
+		parts = append(parts, fmt.Sprint(id))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// openTestDB points the package at a fresh database in a temporary directory.
+func openTestDB(t *testing.T) {
+	t.Helper()
+	if err := InitDB(filepath.Join(t.TempDir(), "test.db"), 1000); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+}
+
+// mustExec runs fixture statements directly, with foreign keys off so orphans
+// can be inserted.
+func mustExec(t *testing.T, stmts ...string) {
+	t.Helper()
+	if _, err := DB.Exec("PRAGMA foreign_keys = OFF"); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range stmts {
+		if _, err := DB.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	cases := []struct {
+		name  string
+		setup []string
+		ids   []int64 // Rows the check must report
+	}{
+		{"orphan_server_sightings", []string{
+			`INSERT INTO server_sightings (id, server_id, seen_at, disconnected_at) VALUES (10, 99, '2025-01-01 10:00:00.000', '2025-01-01 11:00:00.000')`,
+			`INSERT INTO player_sightings (id, server_sighting_id, player_id, seen_at, disconnected_at) VALUES (20, 10, 1, '2025-01-01 10:00:00.000', '2025-01-01 11:00:00.000')`,
+		}, []int64{10}},
+		{"orphan_player_sightings", []string{
+			`INSERT INTO player_sightings (id, server_sighting_id, player_id, seen_at, disconnected_at) VALUES (20, 99, 1, '2025-01-01 10:00:00.000', '2025-01-01 11:00:00.000')`,
+		}, []int64{20}},
+		{"orphan_snapshot_servers", []string{
+			`INSERT INTO snapshot_servers (id, snapshot_id, address, port) VALUES (30, 99, 'a', 1)`,
+		}, []int64{30}},
+		{"reversed_server_sightings", []string{
+			`INSERT INTO server_sightings (id, server_id, seen_at, disconnected_at) VALUES (10, 1, '2025-01-01 11:00:00.000', '2025-01-01 10:00:00.000')`,
+		}, []int64{10}},
+		{"reversed_player_sightings", []string{
+			`INSERT INTO server_sightings (id, server_id, seen_at, disconnected_at) VALUES (10, 1, '2025-01-01 10:00:00.000', '2025-01-01 12:00:00.000')`,
+			`INSERT INTO player_sightings (id, server_sighting_id, player_id, seen_at, disconnected_at) VALUES (20, 10, 1, '2025-01-01 11:00:00.000', '2025-01-01 10:30:00.000')`,
+		}, []int64{20}},
+		{"duplicate_open_server_sightings", []string{
+			`INSERT INTO server_sightings (id, server_id, seen_at) VALUES (10, 1, '2025-01-01 10:00:00.000')`,
+			`INSERT INTO server_sightings (id, server_id, seen_at) VALUES (11, 1, '2025-01-01 11:00:00.000')`,
+		}, []int64{10}},
+		{"overlapping_server_sightings", []string{
+			`INSERT INTO server_sightings (id, server_id, seen_at, disconnected_at) VALUES (10, 1, '2025-01-01 10:00:00.000', '2025-01-01 12:00:00.000')`,
+			`INSERT INTO server_sightings (id, server_id, seen_at, disconnected_at) VALUES (11, 1, '2025-01-01 11:00:00.000', '2025-01-01 13:00:00.000')`,
+		}, []int64{10}},
+		{"stale_open_server_sightings", []string{
+			`INSERT INTO server_sightings (id, server_id, seen_at) VALUES (10, 1, '2025-01-01 10:00:00.000')`,
+			`INSERT INTO snapshots (id, timestamp) VALUES (1, '2025-01-01 10:05:00.000'), (2, '2025-01-01 10:10:00.000')`,
+			`INSERT INTO snapshot_servers (snapshot_id, address, port) VALUES (1, 'a', 1)`,
+		}, []int64{10}},
+		{"open_player_sightings_on_closed_server", []string{
+			`INSERT INTO server_sightings (id, server_id, seen_at, disconnected_at) VALUES (10, 1, '2025-01-01 10:00:00.000', '2025-01-01 12:00:00.000')`,
+			`INSERT INTO player_sightings (id, server_sighting_id, player_id, seen_at) VALUES (20, 10, 1, '2025-01-01 11:00:00.000')`,
+		}, []int64{20}},
+		{"overlapping_player_sightings", []string{
+			`INSERT INTO server_sightings (id, server_id, seen_at, disconnected_at) VALUES (10, 1, '2025-01-01 10:00:00.000', '2025-01-01 12:00:00.000')`,
+			`INSERT INTO player_sightings (id, server_sighting_id, player_id, seen_at, disconnected_at) VALUES (20, 10, 1, '2025-01-01 10:00:00.000', '2025-01-01 11:30:00.000')`,
+			`INSERT INTO player_sightings (id, server_sighting_id, player_id, seen_at, disconnected_at) VALUES (21, 10, 1, '2025-01-01 11:00:00.000', '2025-01-01 12:00:00.000')`,
+		}, []int64{20}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			openTestDB(t)
+			mustExec(t,
+				`INSERT INTO servers (id, address, port, name, game) VALUES (1, 'a', 1, 'A', 'minetest')`,
+				`INSERT INTO players (id, name) VALUES (1, 'alice')`,
+			)
+			mustExec(t, c.setup...)
+
+			report, err := RunDoctor(false)
+			if err != nil {
+				t.Fatalf("RunDoctor: %v", err)
+			}
+			found := false
+			for _, issue := range report.Issues {
+				if issue.Check == c.name {
+					found = true
+					if !slices.Equal(issue.IDs, c.ids) {
+						t.Errorf("%s reported %v, want %v", c.name, issue.IDs, c.ids)
+					}
+				}
+			}
+			if !found {
+				t.Fatalf("no %s check in the report", c.name)
+			}
+
+			if _, err := RunDoctor(true); err != nil {
+				t.Fatalf("RunDoctor(fix): %v", err)
+			}
+			report, err = RunDoctor(false)
+			if err != nil {
+				t.Fatalf("RunDoctor: %v", err)
+			}
+			if report.HasIssues() {
+				t.Errorf("issues left after the repair:\n%s", FormatDoctorReport(report))
+			}
+		})
+	}
+}
+
+func TestDoctorClean(t *testing.T) {
+	openTestDB(t)
+	mustExec(t,
+		`INSERT INTO servers (id, address, port) VALUES (1, 'a', 1)`,
+		`INSERT INTO players (id, name) VALUES (1, 'alice')`,
+		`INSERT INTO server_sightings (id, server_id, seen_at, disconnected_at) VALUES (10, 1, '2025-01-01 10:00:00.000', '2025-01-01 12:00:00.000')`,
+		`INSERT INTO server_sightings (id, server_id, seen_at) VALUES (11, 1, '2025-01-01 12:00:00.000')`,
+		`INSERT INTO player_sightings (id, server_sighting_id, player_id, seen_at, disconnected_at) VALUES (20, 10, 1, '2025-01-01 10:00:00.000', '2025-01-01 11:00:00.000')`,
+		`INSERT INTO player_sightings (id, server_sighting_id, player_id, seen_at) VALUES (21, 11, 1, '2025-01-01 12:00:00.000')`,
+	)
+
+	report, err := RunDoctor(false)
+	if err != nil {
+		t.Fatalf("RunDoctor: %v", err)
+	}
+	if report.HasIssues() {
+		t.Errorf("issues in a consistent database:\n%s", FormatDoctorReport(report))
+	}
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// Game ids are compared lowercased, servers do not always agree on case.
+
+// GetGameCounts returns the all-time totals of every game reported by a
+// server, most servers first.
+func GetGameCounts() ([]models.GameCounts, error) {
+	rows, err := ReadDB.Query(`
+		WITH games AS (
+			SELECT LOWER(game) AS game, COUNT(*) AS servers
+			FROM servers WHERE COALESCE(game, '') != ''
+			GROUP BY LOWER(game)
+		),
+		players AS (
+			SELECT LOWER(s.game) AS game, COUNT(DISTINCT a.player_id) AS players
+			FROM player_daily_playtime a
+			JOIN servers s ON a.server_id = s.id
+			WHERE COALESCE(s.game, '') != ''
+			GROUP BY LOWER(s.game)
+		)
+		SELECT g.game, g.servers, COALESCE(p.players, 0)
+		FROM games g LEFT JOIN players p ON p.game = g.game
+		ORDER BY g.servers DESC, g.game ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []models.GameCounts
+	for rows.Next() {
+		var c models.GameCounts
+		if err := rows.Scan(&c.Game, &c.ServersTotal, &c.PlayersTotal); err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
+
+// GetGameTrends returns, per game, the daily averages of servers and players
+// across the snapshots taken since the given time, oldest day first. Days
+// without any snapshot are left out; days a game was absent from are zero.
+// An empty game returns every game.
+func GetGameTrends(since time.Time, game string) (map[string][]models.GameDay, error) {
+	rows, err := ReadDB.Query(`
+		WITH days AS (
+			SELECT substr(timestamp, 1, 10) AS day, COUNT(*) AS snapshots
+			FROM snapshots WHERE timestamp >= ?1
+			GROUP BY day
+		),
+		totals AS (
+			SELECT substr(sn.timestamp, 1, 10) AS day, LOWER(ss.game) AS game,
+				COUNT(*) AS servers, SUM(COALESCE(ss.clients, 0)) AS players
+			FROM snapshots sn
+			JOIN snapshot_servers ss ON ss.snapshot_id = sn.id
+			WHERE sn.timestamp >= ?1 AND COALESCE(ss.game, '') != ''
+				AND (?2 = '' OR LOWER(ss.game) = LOWER(?2))
+			GROUP BY day, LOWER(ss.game)
+		),
+		games AS (SELECT DISTINCT game FROM totals)
+		SELECT g.game, d.day,
+			COALESCE(t.servers, 0) * 1.0 / d.snapshots,
+			COALESCE(t.players, 0) * 1.0 / d.snapshots
+		FROM games g
+		CROSS JOIN days d
+		LEFT JOIN totals t ON t.game = g.game AND t.day = d.day
+		ORDER BY g.game, d.day
+	`, formatTime(since), game)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	trends := map[string][]models.GameDay{}
+	for rows.Next() {
+		var name, day string
+		var gameDay models.GameDay
+		if err := rows.Scan(&name, &day, &gameDay.Servers, &gameDay.Players); err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		if gameDay.Day, err = time.Parse(dayLayout, day); err != nil {
+			return nil, fmt.Errorf("invalid day %q: %w", day, err)
+		}
+		trends[name] = append(trends[name], gameDay)
+	}
+
+	return trends, rows.Err()
+}
+
+// migrateServerGames fills servers.game, which earlier versions never
+// stored, from the latest snapshot that reported a game for the server.
+func migrateServerGames(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		WITH latest AS (
+			SELECT address, port, game FROM (
+				SELECT ss.address, ss.port, ss.game,
+					ROW_NUMBER() OVER (PARTITION BY ss.address, ss.port ORDER BY snap.timestamp DESC, ss.id DESC) AS rn
+				FROM snapshot_servers ss
+				JOIN snapshots snap ON ss.snapshot_id = snap.id
+				WHERE ss.game IS NOT NULL AND ss.game != ''
+			)
+			WHERE rn = 1
+		)
+		UPDATE servers SET game = latest.game
+		FROM latest
+		WHERE latest.address = servers.address AND latest.port = servers.port
+			AND (servers.game IS NULL OR servers.game = '')
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to backfill server games: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// errDryRun rolls back an import transaction once the summary is complete.
+var errDryRun = errors.New("dry run")
+
+// ImportSummary reports what an import did, or would do in a dry run.
+type ImportSummary struct {
+	Records                int // Records handed to the import
+	Imported               int // Records written
+	Duplicates             int // Records overlapping a session already present and skipped
+	OptedOut               int // Records skipped because the player opted out
+	ServersCreated         int
+	PlayersCreated         int
+	ServerSightingsCreated int
+	ServerSightingsMerged  int // Server sightings joined because a record spans the gap between them
+	DryRun                 bool
+}
+
+// ImportSightings loads historical player sightings. Players and servers are
+// resolved through getOrCreatePlayer and getOrCreateServer, and each sighting
+// is attached to a server sighting covering it, see coveringServerSighting. A
+// sighting of the same player on the same server overlapping one already
+// stored, including one still open, is a duplicate.
+// Everything runs in one transaction, which a dry run rolls back at the end.
+func ImportSightings(sightings []models.ImportedSighting, dryRun bool) (ImportSummary, error) {
+	summary := ImportSummary{Records: len(sightings), DryRun: dryRun}
+
+	err := runImport(dryRun, func(tx *sql.Tx, optedOut map[string]bool) error {
+		for _, sighting := range sightings {
+			if optedOut[strings.ToLower(sighting.Player)] {
+				summary.OptedOut++
+				continue
+			}
+
+			serverID, created, err := importServer(tx, sighting.Address, sighting.Port, sighting.ServerName, sighting.Game, sighting.ConnectedAt, sighting.DisconnectedAt)
+			if err != nil {
+				return err
+			}
+			if created {
+				summary.ServersCreated++
+			}
+
+			playerID, created, err := importPlayer(tx, sighting.Player)
+			if err != nil {
+				return err
+			}
+			if created {
+				summary.PlayersCreated++
+			}
+
+			start, end := formatTime(sighting.ConnectedAt), formatTime(sighting.DisconnectedAt)
+
+			// A player is on a server only once at a time, so any stored
+			// session overlapping the record, or one still open, covers it
+			var duplicate bool
+			err = tx.QueryRow(`
+				SELECT EXISTS(
+					SELECT 1 FROM player_sightings ps
+					JOIN server_sightings ss ON ps.server_sighting_id = ss.id
+					WHERE ps.player_id = ? AND ss.server_id = ?
+						AND (ps.seen_at = ? OR (ps.seen_at < ? AND COALESCE(ps.disconnected_at, ?) > ?))
+				)
+			`, playerID, serverID, start, end, formatTime(time.Now()), start).Scan(&duplicate)
+			if err != nil {
+				return fmt.Errorf("failed to check for duplicate: %w", err)
+			}
+			if duplicate {
+				summary.Duplicates++
+				continue
+			}
+
+			serverSightingID, created, merged, err := coveringServerSighting(tx, serverID, start, end)
+			if err != nil {
+				return err
+			}
+			if created {
+				summary.ServerSightingsCreated++
+			}
+			summary.ServerSightingsMerged += merged
+
+			_, err = tx.Exec(`
+				INSERT INTO player_sightings (server_sighting_id, player_id, seen_at, disconnected_at)
+				VALUES (?, ?, ?, ?)
+			`, serverSightingID, playerID, start, end)
+			if err != nil {
+				return fmt.Errorf("failed to insert player sighting: %w", err)
+			}
+			summary.Imported++
+		}
+		return nil
+	})
+
+	return summary, err
+}
+
+// ImportSnapshots loads historical snapshots. A snapshot taken at the same
+// time as an existing one is a duplicate. Servers and players in the snapshot
+// are registered like live ones, and opted-out players are dropped from the
+// stored player lists.
+func ImportSnapshots(snapshots []models.Snapshot, dryRun bool) (ImportSummary, error) {
+	summary := ImportSummary{Records: len(snapshots), DryRun: dryRun}
+
+	err := runImport(dryRun, func(tx *sql.Tx, optedOut map[string]bool) error {
+		for _, snapshot := range snapshots {
+			var duplicate bool
+			err := tx.QueryRow(`
+				SELECT EXISTS(SELECT 1 FROM snapshots WHERE timestamp = ?)
+			`, formatTime(snapshot.Time)).Scan(&duplicate)
+			if err != nil {
+				return fmt.Errorf("failed to check for duplicate: %w", err)
+			}
+			if duplicate {
+				summary.Duplicates++
+				continue
+			}
+
+			servers := make([]models.Server, len(snapshot.Servers))
+			for i, server := range snapshot.Servers {
+				_, created, err := importServer(tx, server.Address, server.Port, server.Name, server.Game, snapshot.Time, snapshot.Time)
+				if err != nil {
+					return err
+				}
+				if created {
+					summary.ServersCreated++
+				}
+
+				players := make([]string, 0, len(server.PlayerList))
+				for _, player := range server.PlayerList {
+					if optedOut[strings.ToLower(player)] {
+						continue
+					}
+					_, created, err := importPlayer(tx, player)
+					if err != nil {
+						return err
+					}
+					if created {
+						summary.PlayersCreated++
+					}
+					players = append(players, player)
+				}
+				server.PlayerList = players
+				servers[i] = server
+			}
+
+			if err := insertSnapshot(tx, models.Snapshot{Time: snapshot.Time, Servers: servers}); err != nil {
+				return err
+			}
+			summary.Imported++
+		}
+		return nil
+	})
+
+	return summary, err
+}
+
+// runImport runs fn in a transaction on the writer goroutine, committing it
+// unless this is a dry run. Imported history can land on any day, so the
+// leaderboards are rebuilt rather than updated.
+func runImport(dryRun bool, fn func(tx *sql.Tx, optedOut map[string]bool) error) error {
+	optedOut, err := GetOptedOutNames()
+	if err != nil {
+		return err
+	}
+
+	err = write(func() error {
+		tx, err := DB.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		if err := fn(tx, optedOut); err != nil {
+			return err
+		}
+		if err := rebuildLeaderboards(tx); err != nil {
+			return err
+		}
+		if dryRun {
+			return errDryRun
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit import: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+	return err
+}
+
+// importServer registers a server seen between first and last through
+// getOrCreateServer, reporting whether it was new.
+func importServer(tx *sql.Tx, address string, port int, name, game string, first, last time.Time) (int64, bool, error) {
+	var exists bool
+	err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM servers WHERE address = ? AND port = ?)`, address, port).Scan(&exists)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to look up server: %w", err)
+	}
+
+	if _, err := getOrCreateServer(tx, address, port, name, game, first); err != nil {
+		return 0, false, err
+	}
+	id, err := getOrCreateServer(tx, address, port, name, game, last)
+	if err != nil {
+		return 0, false, err
+	}
+	return id, !exists, nil
+}
+
+// importPlayer resolves a player through getOrCreatePlayer, reporting whether it was new.
+func importPlayer(tx *sql.Tx, name string) (int64, bool, error) {
+	var exists bool
+	err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM players WHERE name = ?)`, name).Scan(&exists)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to look up player: %w", err)
+	}
+
+	id, err := getOrCreatePlayer(tx, name)
+	return id, !exists, err
+}
+
+// coveringServerSighting returns the server sighting a player sighting over
+// [start, end] belongs to. The player shows the server was online throughout,
+// so every sighting of the server overlapping the range is merged into one
+// widened to cover it, keeping the open sighting if among them; without any a
+// new closed sighting is created. It reports whether one was created and how
+// many were merged away.
+func coveringServerSighting(tx *sql.Tx, serverID int64, start, end string) (int64, bool, int, error) {
+	rows, err := tx.Query(`
+		SELECT id FROM server_sightings
+		WHERE server_id = ? AND seen_at <= ? AND (disconnected_at IS NULL OR disconnected_at >= ?)
+		ORDER BY disconnected_at IS NULL DESC, seen_at ASC
+	`, serverID, end, start)
+	if err != nil {
+		return 0, false, 0, fmt.Errorf("failed to look up server sightings: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, false, 0, fmt.Errorf("failed to scan server sighting: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, false, 0, fmt.Errorf("failed to look up server sightings: %w", err)
+	}
+
+	if len(ids) == 0 {
+		res, err := tx.Exec(`
+			INSERT INTO server_sightings (server_id, seen_at, disconnected_at) VALUES (?, ?, ?)
+		`, serverID, start, end)
+		if err != nil {
+			return 0, false, 0, fmt.Errorf("failed to insert server sighting: %w", err)
+		}
+		id, err := res.LastInsertId()
+		return id, true, 0, err
+	}
+
+	id := ids[0]
+	for _, other := range ids[1:] {
+		// MAX() is NULL when either sighting is still open, keeping it open
+		_, err := tx.Exec(`
+			UPDATE server_sightings SET
+				seen_at = MIN(seen_at, (SELECT seen_at FROM server_sightings WHERE id = ?)),
+				disconnected_at = MAX(disconnected_at, (SELECT disconnected_at FROM server_sightings WHERE id = ?))
+			WHERE id = ?
+		`, other, other, id)
+		if err != nil {
+			return 0, false, 0, fmt.Errorf("failed to merge server sightings: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE player_sightings SET server_sighting_id = ? WHERE server_sighting_id = ?`, id, other); err != nil {
+			return 0, false, 0, fmt.Errorf("failed to move player sightings: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM server_sightings WHERE id = ?`, other); err != nil {
+			return 0, false, 0, fmt.Errorf("failed to delete merged server sighting: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE server_sightings SET
+			seen_at = MIN(seen_at, ?),
+			disconnected_at = CASE WHEN disconnected_at IS NULL THEN NULL ELSE MAX(disconnected_at, ?) END
+		WHERE id = ?
+	`, start, end, id)
+	if err != nil {
+		return 0, false, 0, fmt.Errorf("failed to extend server sighting: %w", err)
+	}
+	return id, false, len(ids) - 1, nil
+}
+
+// FormatImportSummary renders a summary as human readable text.
+func FormatImportSummary(summary ImportSummary) string {
+	var b strings.Builder
+	if summary.DryRun {
+		b.WriteString("Dry run, nothing was written.\n")
+	}
+	fmt.Fprintf(&b, "Records:                  %d\n", summary.Records)
+	fmt.Fprintf(&b, "Imported:                 %d\n", summary.Imported)
+	fmt.Fprintf(&b, "Duplicates skipped:       %d\n", summary.Duplicates)
+	fmt.Fprintf(&b, "Opted-out skipped:        %d\n", summary.OptedOut)
+	fmt.Fprintf(&b, "Servers created:          %d\n", summary.ServersCreated)
+	fmt.Fprintf(&b, "Players created:          %d\n", summary.PlayersCreated)
+	fmt.Fprintf(&b, "Server sightings created: %d\n", summary.ServerSightingsCreated)
+	fmt.Fprintf(&b, "Server sightings merged:  %d\n", summary.ServerSightingsMerged)
+	return b.String()
+}
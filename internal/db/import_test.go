@@ -0,0 +1,112 @@
+package db
+
+import (
+	"teamacedia/minestalker/internal/models"
+	"testing"
+	"time"
+)
+
+// importedAt is a sighting of player on server a:port between two times of
+// 2025-01-01, given as hours.
+func importedAt(player string, port int, from, to float64) models.ImportedSighting {
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	return models.ImportedSighting{
+		Player:         player,
+		Address:        "a",
+		Port:           port,
+		ConnectedAt:    day.Add(time.Duration(from * float64(time.Hour))),
+		DisconnectedAt: day.Add(time.Duration(to * float64(time.Hour))),
+	}
+}
+
+func TestImportSightingsDedupe(t *testing.T) {
+	cases := []struct {
+		name     string
+		existing []models.ImportedSighting
+		records  []models.ImportedSighting
+		want     ImportSummary
+	}{
+		{"into an empty database", nil,
+			[]models.ImportedSighting{importedAt("alice", 1, 10, 11)},
+			ImportSummary{Records: 1, Imported: 1, ServersCreated: 1, PlayersCreated: 1, ServerSightingsCreated: 1}},
+		{"same session again", []models.ImportedSighting{importedAt("alice", 1, 10, 11)},
+			[]models.ImportedSighting{importedAt("alice", 1, 10, 11)},
+			ImportSummary{Records: 1, Duplicates: 1}},
+		{"same start", []models.ImportedSighting{importedAt("alice", 1, 10, 11)},
+			[]models.ImportedSighting{importedAt("alice", 1, 10, 10.5)},
+			ImportSummary{Records: 1, Duplicates: 1}},
+		{"overlapping the end", []models.ImportedSighting{importedAt("alice", 1, 10, 11)},
+			[]models.ImportedSighting{importedAt("alice", 1, 10.5, 12)},
+			ImportSummary{Records: 1, Duplicates: 1}},
+		{"inside a longer session", []models.ImportedSighting{importedAt("alice", 1, 10, 14)},
+			[]models.ImportedSighting{importedAt("alice", 1, 11, 12)},
+			ImportSummary{Records: 1, Duplicates: 1}},
+		{"right after", []models.ImportedSighting{importedAt("alice", 1, 10, 11)},
+			[]models.ImportedSighting{importedAt("alice", 1, 11, 12)},
+			ImportSummary{Records: 1, Imported: 1}},
+		{"another player", []models.ImportedSighting{importedAt("alice", 1, 10, 11)},
+			[]models.ImportedSighting{importedAt("bob", 1, 10, 11)},
+			ImportSummary{Records: 1, Imported: 1, PlayersCreated: 1}},
+		{"another server", []models.ImportedSighting{importedAt("alice", 1, 10, 11)},
+			[]models.ImportedSighting{importedAt("alice", 2, 10, 11)},
+			ImportSummary{Records: 1, Imported: 1, ServersCreated: 1, ServerSightingsCreated: 1}},
+		{"twice in one file", nil,
+			[]models.ImportedSighting{importedAt("alice", 1, 10, 11), importedAt("alice", 1, 10, 11)},
+			ImportSummary{Records: 2, Imported: 1, Duplicates: 1, ServersCreated: 1, PlayersCreated: 1, ServerSightingsCreated: 1}},
+		{"spanning two server sightings", []models.ImportedSighting{importedAt("alice", 1, 10, 11), importedAt("bob", 1, 12, 13)},
+			[]models.ImportedSighting{importedAt("carol", 1, 10.5, 12.5)},
+			ImportSummary{Records: 1, Imported: 1, PlayersCreated: 1, ServerSightingsMerged: 1}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			openTestDB(t)
+			if _, err := ImportSightings(c.existing, false); err != nil {
+				t.Fatalf("importing the existing sightings: %v", err)
+			}
+			got, err := ImportSightings(c.records, false)
+			if err != nil {
+				t.Fatalf("ImportSightings: %v", err)
+			}
+			if got != c.want {
+				t.Errorf("summary %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	openTestDB(t)
+	got, err := ImportSightings([]models.ImportedSighting{importedAt("alice", 1, 10, 11)}, true)
+	if err != nil {
+		t.Fatalf("ImportSightings: %v", err)
+	}
+	if got.Imported != 1 {
+		t.Errorf("dry run reports %d imported, want 1", got.Imported)
+	}
+
+	var rows int
+	if err := DB.QueryRow(`SELECT (SELECT COUNT(*) FROM servers) + (SELECT COUNT(*) FROM players) + (SELECT COUNT(*) FROM player_sightings)`).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 0 {
+		t.Errorf("dry run wrote %d rows", rows)
+	}
+}
+
+func TestImportSnapshotsDedupe(t *testing.T) {
+	openTestDB(t)
+	at := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	snapshot := models.Snapshot{Time: at, Servers: []models.Server{{Address: "a", Port: 1, PlayerList: []string{"alice"}}}}
+
+	if _, err := ImportSnapshots([]models.Snapshot{snapshot}, false); err != nil {
+		t.Fatalf("ImportSnapshots: %v", err)
+	}
+	got, err := ImportSnapshots([]models.Snapshot{snapshot, {Time: at.Add(time.Minute)}}, false)
+	if err != nil {
+		t.Fatalf("ImportSnapshots: %v", err)
+	}
+	if want := (ImportSummary{Records: 2, Imported: 1, Duplicates: 1}); got != want {
+		t.Errorf("summary %+v, want %+v", got, want)
+	}
+}
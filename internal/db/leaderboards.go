@@ -0,0 +1,417 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// Leaderboards read from two aggregate tables maintained as sightings close,
+// so requests never scan the sightings themselves:
+//
+//   - player_daily_playtime: seconds played per UTC day, player and server,
+//     added when a player sighting closes and split at midnight.
+//   - server_daily_stats: per UTC day and server, the most players online at
+//     once (updated on every join) and the longest online streak that ended
+//     that day (updated when a server sighting closes).
+//
+// Batch writers such as import and doctor rebuild both tables from scratch.
+
+// dayLayout is the text form of the day column of the aggregate tables.
+const dayLayout = "2006-01-02"
+
+// openPlayerSighting is an open player sighting about to be closed.
+type openPlayerSighting struct {
+	id       int64
+	playerID int64
+	serverID int64
+	seenAt   time.Time
+}
+
+// closePlayerSightings closes the open player sightings matching condition,
+// a WHERE clause over player_sightings ps and server_sightings ss, and adds
+// their playtime to player_daily_playtime.
+func closePlayerSightings(tx *sql.Tx, at time.Time, condition string, args ...any) error {
+	rows, err := tx.Query(`
+		SELECT ps.id, ps.player_id, ss.server_id, ps.seen_at
+		FROM player_sightings ps
+		JOIN server_sightings ss ON ps.server_sighting_id = ss.id
+		WHERE ps.disconnected_at IS NULL AND (`+condition+`)
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to find open player sightings: %w", err)
+	}
+	var open []openPlayerSighting
+	for rows.Next() {
+		var s openPlayerSighting
+		if err := rows.Scan(&s.id, &s.playerID, &s.serverID, &s.seenAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("row scan failed: %w", err)
+		}
+		open = append(open, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, s := range open {
+		_, err := tx.Exec(`UPDATE player_sightings SET disconnected_at = ? WHERE id = ?`, formatTime(at), s.id)
+		if err != nil {
+			return fmt.Errorf("failed to close player sighting: %w", err)
+		}
+		if err := addPlaytime(tx, s.playerID, s.serverID, s.seenAt, at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeServerSighting closes an open server sighting and records its length
+// as a streak on the day it ended.
+func closeServerSighting(tx *sql.Tx, serverSightingID int64, at time.Time) error {
+	var serverID int64
+	var seenAt time.Time
+	err := tx.QueryRow(`
+		SELECT server_id, seen_at FROM server_sightings WHERE id = ? AND disconnected_at IS NULL
+	`, serverSightingID).Scan(&serverID, &seenAt)
+	if err == sql.ErrNoRows {
+		return nil // already closed
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up server sighting: %w", err)
+	}
+
+	_, err = tx.Exec(`UPDATE server_sightings SET disconnected_at = ? WHERE id = ?`, formatTime(at), serverSightingID)
+	if err != nil {
+		return fmt.Errorf("failed to close server sighting: %w", err)
+	}
+	return addStreak(tx, serverID, seenAt, at)
+}
+
+// addPlaytime adds a session from start to end to player_daily_playtime,
+// split at UTC midnight.
+func addPlaytime(tx *sql.Tx, playerID, serverID int64, start, end time.Time) error {
+	start, end = start.UTC(), end.UTC()
+	for start.Before(end) {
+		day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+		stop := day.AddDate(0, 0, 1)
+		if end.Before(stop) {
+			stop = end
+		}
+		_, err := tx.Exec(`
+			INSERT INTO player_daily_playtime (day, player_id, server_id, seconds)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT(day, player_id, server_id) DO UPDATE SET seconds = seconds + excluded.seconds
+		`, day.Format(dayLayout), playerID, serverID, stop.Sub(start).Seconds())
+		if err != nil {
+			return fmt.Errorf("failed to record playtime: %w", err)
+		}
+		start = stop
+	}
+	return nil
+}
+
+// addStreak records a server online streak on the day it ended.
+func addStreak(tx *sql.Tx, serverID int64, start, end time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO server_daily_stats (day, server_id, longest_streak_seconds)
+		VALUES (?, ?, ?)
+		ON CONFLICT(day, server_id) DO UPDATE SET
+			longest_streak_seconds = MAX(longest_streak_seconds, excluded.longest_streak_seconds)
+	`, end.UTC().Format(dayLayout), serverID, end.Sub(start).Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to record streak: %w", err)
+	}
+	return nil
+}
+
+// addPeak records players online at once on a server, keeping the day's maximum.
+func addPeak(tx *sql.Tx, serverID int64, at time.Time, players int) error {
+	_, err := tx.Exec(`
+		INSERT INTO server_daily_stats (day, server_id, peak_players)
+		VALUES (?, ?, ?)
+		ON CONFLICT(day, server_id) DO UPDATE SET
+			peak_players = MAX(peak_players, excluded.peak_players)
+	`, at.UTC().Format(dayLayout), serverID, players)
+	if err != nil {
+		return fmt.Errorf("failed to record peak: %w", err)
+	}
+	return nil
+}
+
+// recordPeak counts the players now online in a server sighting after a join.
+func recordPeak(tx *sql.Tx, serverSightingID int64, at time.Time) error {
+	var serverID int64
+	var players int
+	err := tx.QueryRow(`
+		SELECT ss.server_id, (
+			SELECT COUNT(*) FROM player_sightings ps
+			WHERE ps.server_sighting_id = ss.id AND ps.disconnected_at IS NULL
+		)
+		FROM server_sightings ss WHERE ss.id = ?
+	`, serverSightingID).Scan(&serverID, &players)
+	if err != nil {
+		return fmt.Errorf("failed to count players: %w", err)
+	}
+	return addPeak(tx, serverID, at, players)
+}
+
+// rebuildLeaderboards recomputes both aggregate tables from the sightings.
+func rebuildLeaderboards(tx *sql.Tx) error {
+	for _, table := range []string{"player_daily_playtime", "server_daily_stats"} {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", table, err)
+		}
+	}
+
+	// Playtime of closed player sightings
+	rows, err := tx.Query(`
+		SELECT ps.player_id, ss.server_id, ps.seen_at, ps.disconnected_at
+		FROM player_sightings ps
+		JOIN server_sightings ss ON ps.server_sighting_id = ss.id
+		WHERE ps.disconnected_at IS NOT NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to read player sightings: %w", err)
+	}
+	type session struct {
+		playerID, serverID int64
+		start, end         time.Time
+	}
+	var sessions []session
+	for rows.Next() {
+		var s session
+		if err := rows.Scan(&s.playerID, &s.serverID, &s.start, &s.end); err != nil {
+			rows.Close()
+			return fmt.Errorf("row scan failed: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		if err := addPlaytime(tx, s.playerID, s.serverID, s.start, s.end); err != nil {
+			return err
+		}
+	}
+
+	// Streaks of closed server sightings
+	rows, err = tx.Query(`
+		SELECT server_id, seen_at, disconnected_at FROM server_sightings WHERE disconnected_at IS NOT NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to read server sightings: %w", err)
+	}
+	var streaks []session
+	for rows.Next() {
+		var s session
+		if err := rows.Scan(&s.serverID, &s.start, &s.end); err != nil {
+			rows.Close()
+			return fmt.Errorf("row scan failed: %w", err)
+		}
+		streaks = append(streaks, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, s := range streaks {
+		if err := addStreak(tx, s.serverID, s.start, s.end); err != nil {
+			return err
+		}
+	}
+
+	return rebuildPeaks(tx)
+}
+
+// rebuildPeaks sweeps the joins and leaves of every server sighting in time
+// order to find the most players online at once per day.
+func rebuildPeaks(tx *sql.Tx) error {
+	rows, err := tx.Query(`
+		SELECT ss.server_id, ps.server_sighting_id, ps.seen_at, ps.disconnected_at
+		FROM player_sightings ps
+		JOIN server_sightings ss ON ps.server_sighting_id = ss.id
+		ORDER BY ps.server_sighting_id
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to read player sightings: %w", err)
+	}
+
+	type change struct {
+		at    time.Time
+		delta int
+	}
+	type dayKey struct {
+		day      string
+		serverID int64
+	}
+	peaks := map[dayKey]int{}
+
+	var serverID, sightingID int64 = 0, -1
+	var changes []change
+	sweep := func() {
+		// Leaves sort before joins at the same instant
+		sort.Slice(changes, func(i, j int) bool {
+			if !changes[i].at.Equal(changes[j].at) {
+				return changes[i].at.Before(changes[j].at)
+			}
+			return changes[i].delta < changes[j].delta
+		})
+		online := 0
+		for _, c := range changes {
+			online += c.delta
+			if c.delta > 0 {
+				key := dayKey{c.at.UTC().Format(dayLayout), serverID}
+				peaks[key] = max(peaks[key], online)
+			}
+		}
+		changes = changes[:0]
+	}
+
+	for rows.Next() {
+		var sid, ssid int64
+		var seenAt time.Time
+		var disconnectedAt sql.NullTime
+		if err := rows.Scan(&sid, &ssid, &seenAt, &disconnectedAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("row scan failed: %w", err)
+		}
+		if ssid != sightingID {
+			sweep()
+			serverID, sightingID = sid, ssid
+		}
+		changes = append(changes, change{seenAt, 1})
+		if disconnectedAt.Valid {
+			changes = append(changes, change{disconnectedAt.Time, -1})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	sweep()
+
+	for key, players := range peaks {
+		day, _ := time.Parse(dayLayout, key.day)
+		if err := addPeak(tx, key.serverID, day, players); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateLeaderboards fills the leaderboard aggregates from existing history.
+func migrateLeaderboards(tx *sql.Tx) error {
+	return rebuildLeaderboards(tx)
+}
+
+// Leaderboard names accepted by GetLeaderboard.
+const (
+	BoardPlaytime       = "playtime"
+	BoardServersVisited = "servers_visited"
+	BoardPeakPlayers    = "peak_players"
+	BoardUptimeStreak   = "uptime_streak"
+)
+
+// LeaderboardFilter selects the entries a leaderboard ranks. Since is
+// truncated to its UTC day; a zero Since covers all time.
+type LeaderboardFilter struct {
+	Since   time.Time
+	Game    string // Only servers running this gameid, case-insensitive
+	Address string // Only this server, with Port
+	Port    int
+	Limit   int
+}
+
+// GetLeaderboard ranks players (playtime, servers_visited) or servers
+// (peak_players, uptime_streak) by the board's value, highest first. Players
+// who opted out are left out. Ongoing uptime streaks count as ending now.
+func GetLeaderboard(board string, filter LeaderboardFilter) ([]models.LeaderboardEntry, error) {
+	since := ""
+	if !filter.Since.IsZero() {
+		since = filter.Since.UTC().Format(dayLayout)
+	}
+	serverFilter := `
+		(? = '' OR LOWER(COALESCE(s.game, '')) = LOWER(?))
+		AND (? = '' OR (s.address = ? AND s.port = ?))
+	`
+	args := []any{since, filter.Game, filter.Game, filter.Address, filter.Address, filter.Port}
+
+	var query string
+	switch board {
+	case BoardPlaytime, BoardServersVisited:
+		value := "SUM(a.seconds)"
+		if board == BoardServersVisited {
+			value = "COUNT(DISTINCT a.server_id)"
+		}
+		query = `
+		SELECT p.name, '', 0, '', ` + value + ` AS value
+		FROM player_daily_playtime a
+		JOIN players p ON a.player_id = p.id
+		JOIN servers s ON a.server_id = s.id
+		WHERE a.day >= ? AND ` + serverFilter + `
+			AND NOT EXISTS (SELECT 1 FROM opted_out_players o WHERE o.player_name = p.name COLLATE NOCASE)
+		GROUP BY a.player_id
+		ORDER BY value DESC, p.name ASC
+		LIMIT ?`
+
+	case BoardPeakPlayers:
+		query = `
+		SELECT '', s.address, s.port, COALESCE(s.name, ''), MAX(a.peak_players) AS value
+		FROM server_daily_stats a
+		JOIN servers s ON a.server_id = s.id
+		WHERE a.day >= ? AND ` + serverFilter + `
+		GROUP BY a.server_id
+		HAVING value > 0
+		ORDER BY value DESC, s.address ASC, s.port ASC
+		LIMIT ?`
+
+	case BoardUptimeStreak:
+		query = `
+		WITH streaks AS (
+			SELECT server_id, longest_streak_seconds AS seconds
+			FROM server_daily_stats WHERE day >= ?
+			UNION ALL
+			SELECT server_id, (julianday(?) - julianday(seen_at)) * 86400
+			FROM server_sightings WHERE disconnected_at IS NULL
+		)
+		SELECT '', s.address, s.port, COALESCE(s.name, ''), MAX(a.seconds) AS value
+		FROM streaks a
+		JOIN servers s ON a.server_id = s.id
+		WHERE ` + serverFilter + `
+		GROUP BY a.server_id
+		HAVING value > 0
+		ORDER BY value DESC, s.address ASC, s.port ASC
+		LIMIT ?`
+		args = append([]any{since, formatTime(time.Now())}, args[1:]...)
+
+	default:
+		return nil, fmt.Errorf("unknown leaderboard %q", board)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1 // SQLite: no limit
+	}
+	rows, err := ReadDB.Query(query, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []models.LeaderboardEntry
+	for rows.Next() {
+		var entry models.LeaderboardEntry
+		if err := rows.Scan(&entry.Player, &entry.Address, &entry.Port, &entry.Name, &entry.Value); err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
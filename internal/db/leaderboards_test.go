@@ -0,0 +1,110 @@
+package db
+
+import (
+	"maps"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAddPlaytimeSplitsAtMidnight(t *testing.T) {
+	openTestDB(t)
+	utc := func(day, hour, min int) time.Time {
+		return time.Date(2025, 1, day, hour, min, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		name       string
+		start, end time.Time
+		want       map[string]float64 // Seconds per day
+	}{
+		{"within a day", utc(1, 10, 0), utc(1, 11, 0), map[string]float64{"2025-01-01": 3600}},
+		{"across midnight", utc(1, 23, 30), utc(2, 0, 45), map[string]float64{"2025-01-01": 1800, "2025-01-02": 2700}},
+		{"ending at midnight", utc(1, 23, 0), utc(2, 0, 0), map[string]float64{"2025-01-01": 3600}},
+		{"over a whole day", utc(1, 23, 0), utc(3, 1, 0), map[string]float64{"2025-01-01": 3600, "2025-01-02": 86400, "2025-01-03": 3600}},
+		{"local times split at UTC midnight",
+			time.Date(2025, 1, 2, 0, 30, 0, 0, time.FixedZone("CET", 3600)), time.Date(2025, 1, 2, 1, 30, 0, 0, time.FixedZone("CET", 3600)),
+			map[string]float64{"2025-01-01": 1800, "2025-01-02": 1800}},
+		{"empty", utc(1, 10, 0), utc(1, 10, 0), map[string]float64{}},
+	}
+
+	for _, c := range cases {
+		tx, err := DB.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := addPlaytime(tx, 1, 1, c.start, c.end); err != nil {
+			t.Fatalf("%s: addPlaytime: %v", c.name, err)
+		}
+		rows, err := tx.Query(`SELECT day, seconds FROM player_daily_playtime`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]float64{}
+		for rows.Next() {
+			var day string
+			var seconds float64
+			if err := rows.Scan(&day, &seconds); err != nil {
+				t.Fatal(err)
+			}
+			got[day] = seconds
+		}
+		rows.Close()
+		tx.Rollback()
+
+		if !maps.Equal(got, c.want) {
+			t.Errorf("%s: playtime %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestUptimeStreaks(t *testing.T) {
+	openTestDB(t)
+	open := time.Now().UTC().Add(-time.Hour)
+	mustExec(t,
+		`INSERT INTO servers (id, address, port) VALUES (1, 'a', 1), (2, 'b', 2), (3, 'c', 3)`,
+		// a's longest streak ended on the 3rd, a shorter one on the 5th
+		`INSERT INTO server_sightings (server_id, seen_at, disconnected_at) VALUES
+			(1, '2025-01-01 00:00:00.000', '2025-01-03 00:00:00.000'),
+			(1, '2025-01-05 00:00:00.000', '2025-01-05 06:00:00.000'),
+			(2, '2025-01-04 00:00:00.000', '2025-01-04 12:00:00.000')`,
+		`INSERT INTO server_sightings (server_id, seen_at) VALUES (3, '`+formatTime(open)+`')`,
+	)
+	tx, err := DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rebuildLeaderboards(tx); err != nil {
+		t.Fatalf("rebuildLeaderboards: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name  string
+		since time.Time
+		want  []float64 // Streak seconds, highest first
+		order string    // Addresses of the entries, one letter each
+	}{
+		{"all time", time.Time{}, []float64{172800, 43200, 3600}, "abc"},
+		// Streaks count on the day they ended, ongoing ones always
+		{"since the 4th", time.Date(2025, 1, 4, 12, 0, 0, 0, time.UTC), []float64{43200, 21600, 3600}, "bac"},
+		{"since the 6th", time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC), []float64{3600}, "c"},
+	}
+
+	for _, c := range cases {
+		entries, err := GetLeaderboard(BoardUptimeStreak, LeaderboardFilter{Since: c.since})
+		if err != nil {
+			t.Fatalf("%s: GetLeaderboard: %v", c.name, err)
+		}
+		if len(entries) != len(c.want) {
+			t.Fatalf("%s: %d entries, want %d: %+v", c.name, len(entries), len(c.want), entries)
+		}
+		for i, entry := range entries {
+			if entry.Address != c.order[i:i+1] || math.Abs(entry.Value-c.want[i]) > 60 {
+				t.Errorf("%s: entry %d is %s with %v, want %s with %v", c.name, i, entry.Address, entry.Value, c.order[i:i+1], c.want[i])
+			}
+		}
+	}
+}
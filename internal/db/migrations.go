@@ -0,0 +1,107 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migrations transform existing data when the schema alone is not enough.
+// They run in order, each in its own transaction, and the number applied is
+// kept in PRAGMA user_version so every migration runs exactly once.
+var migrations = []func(tx *sql.Tx) error{
+	migrateCanonicalTimestamps,
+	migrateScrapeCoverage,
+	migrateLeaderboards,
+	migrateServerGames,
+}
+
+func runMigrations() error {
+	var version int
+	if err := DB.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := DB.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin migration %d: %w", i+1, err)
+		}
+		if err := migrations[i](tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d failed: %w", i+1, err)
+		}
+		// PRAGMA does not accept bound parameters
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// migrateCanonicalTimestamps rewrites every stored timestamp into timeLayout.
+// Sightings used to be written by SQLite's datetime('now') and snapshots by
+// the Go driver with a nanosecond fraction and zone offset, so the two could
+// not be compared as text. strftime() understands both forms and normalises
+// offsets to UTC; values it cannot parse are left alone for doctor to report.
+func migrateCanonicalTimestamps(tx *sql.Tx) error {
+	columns := []struct{ table, column string }{
+		{"servers", "first_seen"},
+		{"servers", "last_seen"},
+		{"server_sightings", "seen_at"},
+		{"server_sightings", "disconnected_at"},
+		{"player_sightings", "seen_at"},
+		{"player_sightings", "disconnected_at"},
+		{"snapshots", "timestamp"},
+	}
+
+	for _, c := range columns {
+		_, err := tx.Exec(fmt.Sprintf(`
+			UPDATE %[1]s SET %[2]s = strftime('%%Y-%%m-%%d %%H:%%M:%%f', %[2]s)
+			WHERE %[2]s IS NOT NULL AND strftime('%%Y-%%m-%%d %%H:%%M:%%f', %[2]s) IS NOT NULL
+		`, c.table, c.column))
+		if err != nil {
+			return fmt.Errorf("failed to convert %s.%s: %w", c.table, c.column, err)
+		}
+	}
+
+	return nil
+}
+
+// snapshotCoverageGap is the longest gap between two snapshots still taken as
+// continuous scraping when backfilling coverage, three default snapshot intervals.
+const snapshotCoverageGap = 15 * time.Minute
+
+// migrateScrapeCoverage backfills scrape_coverage from snapshot times, the
+// only trace earlier versions left of when the scraper was running.
+func migrateScrapeCoverage(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT timestamp FROM snapshots ORDER BY timestamp ASC`)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot times: %w", err)
+	}
+	var times []time.Time
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			rows.Close()
+			return fmt.Errorf("row scan failed: %w", err)
+		}
+		times = append(times, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, t := range times {
+		if err := recordCoverage(tx, t, snapshotCoverageGap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
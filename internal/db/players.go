@@ -0,0 +1,248 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// playerSightingColumns are scanned by scanPlayerSighting.
+const playerSightingColumns = `
+	p.name, ps.seen_at, ps.disconnected_at, s.address, s.port, COALESCE(s.name, ''), COALESCE(s.game, '')
+	FROM player_sightings ps
+	JOIN players p ON ps.player_id = p.id
+	JOIN server_sightings ss ON ps.server_sighting_id = ss.id
+	JOIN servers s ON ss.server_id = s.id
+`
+
+func scanPlayerSighting(rows *sql.Rows) (models.PlayerSighting, error) {
+	var sighting models.PlayerSighting
+	var disconnectedAt sql.NullTime
+	err := rows.Scan(&sighting.Player, &sighting.ConnectedAt, &disconnectedAt,
+		&sighting.Address, &sighting.Port, &sighting.ServerName, &sighting.Game)
+	if err != nil {
+		return sighting, fmt.Errorf("row scan failed: %w", err)
+	}
+	if disconnectedAt.Valid {
+		sighting.DisconnectedAt = &disconnectedAt.Time
+	}
+	return sighting, nil
+}
+
+// GetOpenPlayerSightings returns the sessions in progress of the given players
+// keyed by lowercased name. Players who are offline are absent; a player with
+// several open sightings gets the newest.
+func GetOpenPlayerSightings(names []string) (map[string]models.PlayerSighting, error) {
+	open := make(map[string]models.PlayerSighting, len(names))
+	if len(names) == 0 {
+		return open, nil
+	}
+
+	placeholders := make([]string, len(names))
+	args := make([]any, len(names))
+	for i, name := range names {
+		placeholders[i] = "?"
+		args[i] = strings.ToLower(name)
+	}
+	rows, err := ReadDB.Query(`
+		SELECT `+playerSightingColumns+`
+		WHERE ps.disconnected_at IS NULL AND LOWER(p.name) IN (`+strings.Join(placeholders, ", ")+`)
+		ORDER BY ps.seen_at
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		sighting, err := scanPlayerSighting(rows)
+		if err != nil {
+			return nil, err
+		}
+		open[strings.ToLower(sighting.Player)] = sighting
+	}
+	return open, rows.Err()
+}
+
+// GetOpenServerSightings returns the sessions in progress on the given servers,
+// earliest join first. Servers without players online are absent.
+func GetOpenServerSightings(keys []ServerKey) (map[ServerKey][]models.PlayerSighting, error) {
+	open := make(map[ServerKey][]models.PlayerSighting, len(keys))
+	if len(keys) == 0 {
+		return open, nil
+	}
+
+	values := make([]string, len(keys))
+	args := make([]any, 0, 2*len(keys))
+	for i, key := range keys {
+		values[i] = "(?, ?)"
+		args = append(args, key.Address, key.Port)
+	}
+	rows, err := ReadDB.Query(`
+		SELECT `+playerSightingColumns+`
+		WHERE ps.disconnected_at IS NULL AND (s.address, s.port) IN (VALUES `+strings.Join(values, ", ")+`)
+		ORDER BY ps.seen_at
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		sighting, err := scanPlayerSighting(rows)
+		if err != nil {
+			return nil, err
+		}
+		key := ServerKey{sighting.Address, sighting.Port}
+		open[key] = append(open[key], sighting)
+	}
+	return open, rows.Err()
+}
+
+// SightingsPage is a page of sightings and the number of them on all pages.
+type SightingsPage struct {
+	Sightings []models.PlayerSighting
+	Total     int
+}
+
+// GetPlayerSightingsPages returns the same page of the sightings of each of
+// the given players, newest first, keyed by
+// lowercased name. Players without sightings are absent.
+func GetPlayerSightingsPages(names []string, limit, offset int) (map[string]SightingsPage, error) {
+	pages := make(map[string]SightingsPage, len(names))
+	if len(names) == 0 {
+		return pages, nil
+	}
+
+	placeholders := make([]string, len(names))
+	args := make([]any, len(names))
+	for i, name := range names {
+		placeholders[i] = "?"
+		args[i] = strings.ToLower(name)
+	}
+	where := "LOWER(p.name) IN (" + strings.Join(placeholders, ", ") + ")"
+
+	rows, err := ReadDB.Query(`
+		SELECT LOWER(p.name), COUNT(*) FROM player_sightings ps
+		JOIN players p ON ps.player_id = p.id
+		WHERE `+where+`
+		GROUP BY LOWER(p.name)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("count failed: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var page SightingsPage
+		if err := rows.Scan(&name, &page.Total); err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		pages[name] = page
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sightings, err := rankedSightings(where, args, "LOWER(p.name)", "ps.seen_at DESC, ps.id DESC", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	for _, sighting := range sightings {
+		key := strings.ToLower(sighting.Player)
+		page := pages[key]
+		page.Sightings = append(page.Sightings, sighting)
+		pages[key] = page
+	}
+	return pages, nil
+}
+
+// GetServerSightingsPages returns the same page of the sightings overlapping
+// [from, to] on each of the given servers, oldest first like
+// GetServerPlayerSightingsBetween. Servers without any are absent.
+func GetServerSightingsPages(keys []ServerKey, from, to time.Time, limit, offset int) (map[ServerKey]SightingsPage, error) {
+	pages := make(map[ServerKey]SightingsPage, len(keys))
+	if len(keys) == 0 {
+		return pages, nil
+	}
+
+	values := make([]string, len(keys))
+	args := make([]any, 0, 2*len(keys)+2)
+	for i, key := range keys {
+		values[i] = "(?, ?)"
+		args = append(args, key.Address, key.Port)
+	}
+	args = append(args, formatTime(to), formatTime(from))
+	where := "(s.address, s.port) IN (VALUES " + strings.Join(values, ", ") + `)
+		AND ps.seen_at <= ? AND (ps.disconnected_at IS NULL OR ps.disconnected_at >= ?)`
+
+	rows, err := ReadDB.Query(`
+		SELECT s.address, s.port, COUNT(*) FROM player_sightings ps
+		JOIN server_sightings ss ON ps.server_sighting_id = ss.id
+		JOIN servers s ON ss.server_id = s.id
+		WHERE `+where+`
+		GROUP BY s.id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("count failed: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key ServerKey
+		var page SightingsPage
+		if err := rows.Scan(&key.Address, &key.Port, &page.Total); err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		pages[key] = page
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sightings, err := rankedSightings(where, args, "s.id", "ps.seen_at ASC, ps.id ASC", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	for _, sighting := range sightings {
+		key := ServerKey{sighting.Address, sighting.Port}
+		page := pages[key]
+		page.Sightings = append(page.Sightings, sighting)
+		pages[key] = page
+	}
+	return pages, nil
+}
+
+// rankedSightings returns the sightings matching where numbered in order
+// within each partition, keeping numbers offset+1 to offset+limit of every
+// partition, which is the same page of each.
+func rankedSightings(where string, args []any, partition, order string, limit, offset int) ([]models.PlayerSighting, error) {
+	rows, err := ReadDB.Query(`
+		SELECT `+playerSightingColumns+`
+		JOIN (
+			SELECT ps.id, ROW_NUMBER() OVER (PARTITION BY `+partition+` ORDER BY `+order+`) AS n
+			FROM player_sightings ps
+			JOIN players p ON ps.player_id = p.id
+			JOIN server_sightings ss ON ps.server_sighting_id = ss.id
+			JOIN servers s ON ss.server_id = s.id
+			WHERE `+where+`
+		) ranked ON ranked.id = ps.id
+		WHERE ranked.n > ? AND ranked.n <= ?
+		ORDER BY `+order+`
+	`, append(append([]any{}, args...), offset, offset+limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var sightings []models.PlayerSighting
+	for rows.Next() {
+		sighting, err := scanPlayerSighting(rows)
+		if err != nil {
+			return nil, err
+		}
+		sightings = append(sightings, sighting)
+	}
+	return sightings, rows.Err()
+}
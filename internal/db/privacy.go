@@ -0,0 +1,219 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// AddOptOut adds a player to the opt-out list and closes any sightings of them
+// that are still open, so nothing more is recorded from this moment on.
+// Player names are matched case-insensitively.
+func AddOptOut(optOut models.OptOut) error {
+	return write(func() error {
+		tx, err := DB.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		at := time.Now()
+		_, err = tx.Exec(`
+			INSERT INTO opted_out_players (player_name, reason, added_by, created_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT(player_name) DO UPDATE SET
+				reason = excluded.reason,
+				added_by = excluded.added_by
+		`, optOut.PlayerName, optOut.Reason, optOut.AddedBy, formatTime(at))
+		if err != nil {
+			return fmt.Errorf("failed to add opt-out: %w", err)
+		}
+
+		err = closePlayerSightings(tx, at, `
+			ps.player_id IN (SELECT id FROM players WHERE LOWER(name) = LOWER(?))
+		`, optOut.PlayerName)
+		if err != nil {
+			return fmt.Errorf("failed to close open sightings: %w", err)
+		}
+
+		return tx.Commit()
+	})
+}
+
+// RemoveOptOut removes a player from the opt-out list. Tracking resumes the
+// next time they are seen joining a server.
+func RemoveOptOut(playerName string) error {
+	return write(func() error {
+		_, err := DB.Exec(`DELETE FROM opted_out_players WHERE player_name = ?`, playerName)
+		return err
+	})
+}
+
+// IsOptedOut checks if a player is on the opt-out list.
+func IsOptedOut(playerName string) bool {
+	var exists bool
+	err := ReadDB.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM opted_out_players WHERE player_name = ?)
+	`, playerName).Scan(&exists)
+	if err != nil {
+		fmt.Printf("Error checking opt-out: %v\n", err)
+		return false
+	}
+	return exists
+}
+
+// GetOptOuts retrieves the whole opt-out list.
+func GetOptOuts() ([]models.OptOut, error) {
+	rows, err := ReadDB.Query(`
+		SELECT player_name, COALESCE(reason, ''), COALESCE(added_by, ''), created_at
+		FROM opted_out_players
+		ORDER BY player_name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var optOuts []models.OptOut
+	for rows.Next() {
+		var optOut models.OptOut
+		if err := rows.Scan(&optOut.PlayerName, &optOut.Reason, &optOut.AddedBy, &optOut.CreatedAt); err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		optOuts = append(optOuts, optOut)
+	}
+
+	return optOuts, rows.Err()
+}
+
+// GetOptedOutNames returns the opt-out list as a set of lowercased names, the
+// form the tracker checks every scrape against.
+func GetOptedOutNames() (map[string]bool, error) {
+	optOuts, err := GetOptOuts()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(optOuts))
+	for _, optOut := range optOuts {
+		names[strings.ToLower(optOut.PlayerName)] = true
+	}
+	return names, nil
+}
+
+// ErasePlayer deletes everything stored about a player: their sightings, the
+// player rows themselves, their name in snapshot player lists and any tracking
+// alerts for them. An audit record of what was removed is kept.
+func ErasePlayer(playerName, requestedBy string) (models.PlayerErasure, error) {
+	erasure := models.PlayerErasure{
+		PlayerName:  playerName,
+		RequestedBy: requestedBy,
+		ErasedAt:    time.Now().UTC().Truncate(time.Millisecond),
+	}
+
+	err := write(func() error {
+		tx, err := DB.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Exec(`
+			DELETE FROM player_sightings
+			WHERE player_id IN (SELECT id FROM players WHERE LOWER(name) = LOWER(?))
+		`, playerName)
+		if erasure.SightingsDeleted, err = rowsAffected(res, err); err != nil {
+			return fmt.Errorf("failed to delete sightings: %w", err)
+		}
+
+		_, err = tx.Exec(`
+			DELETE FROM player_daily_playtime
+			WHERE player_id IN (SELECT id FROM players WHERE LOWER(name) = LOWER(?))
+		`, playerName)
+		if err != nil {
+			return fmt.Errorf("failed to delete playtime: %w", err)
+		}
+
+		if _, err = tx.Exec(`DELETE FROM players WHERE LOWER(name) = LOWER(?)`, playerName); err != nil {
+			return fmt.Errorf("failed to delete player: %w", err)
+		}
+
+		// Rebuild the JSON player list without the name. The client count is
+		// left as is, it is public data of the server rather than the player.
+		res, err = tx.Exec(`
+			UPDATE snapshot_servers SET player_list = (
+				SELECT json_group_array(value) FROM json_each(snapshot_servers.player_list)
+				WHERE LOWER(value) <> LOWER(?1)
+			)
+			WHERE json_valid(player_list) AND json_type(player_list) = 'array' AND EXISTS (
+				SELECT 1 FROM json_each(snapshot_servers.player_list) WHERE LOWER(value) = LOWER(?1)
+			)
+		`, playerName)
+		if erasure.SnapshotMentionsRemoved, err = rowsAffected(res, err); err != nil {
+			return fmt.Errorf("failed to remove snapshot mentions: %w", err)
+		}
+
+		res, err = tx.Exec(`DELETE FROM tracking_alerts WHERE LOWER(player_name) = LOWER(?)`, playerName)
+		if erasure.AlertsDeleted, err = rowsAffected(res, err); err != nil {
+			return fmt.Errorf("failed to delete tracking alerts: %w", err)
+		}
+
+		res, err = tx.Exec(`
+			INSERT INTO player_erasures
+			(player_name, requested_by, erased_at, sightings_deleted, snapshot_mentions_removed, alerts_deleted)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, erasure.PlayerName, erasure.RequestedBy, formatTime(erasure.ErasedAt),
+			erasure.SightingsDeleted, erasure.SnapshotMentionsRemoved, erasure.AlertsDeleted)
+		if err != nil {
+			return fmt.Errorf("failed to record erasure: %w", err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get erasure ID: %w", err)
+		}
+		erasure.ID = int(id)
+
+		return tx.Commit()
+	})
+	if err != nil {
+		return models.PlayerErasure{}, err
+	}
+
+	return erasure, nil
+}
+
+// GetErasures retrieves the erasure audit log, newest first.
+func GetErasures() ([]models.PlayerErasure, error) {
+	rows, err := ReadDB.Query(`
+		SELECT id, player_name, COALESCE(requested_by, ''), erased_at,
+			sightings_deleted, snapshot_mentions_removed, alerts_deleted
+		FROM player_erasures
+		ORDER BY erased_at DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var erasures []models.PlayerErasure
+	for rows.Next() {
+		var e models.PlayerErasure
+		err := rows.Scan(&e.ID, &e.PlayerName, &e.RequestedBy, &e.ErasedAt,
+			&e.SightingsDeleted, &e.SnapshotMentionsRemoved, &e.AlertsDeleted)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		erasures = append(erasures, e)
+	}
+
+	return erasures, rows.Err()
+}
+
+func rowsAffected(res sql.Result, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,205 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// Sightings and snapshots share the canonical timeLayout, so a window is
+// applied to both with plain text comparisons. A sighting overlaps [from, to]
+// when it started no later than to and had not ended before from; sightings
+// that are still open overlap every window after they started.
+
+// GetPlayerHistoryBetween returns the player's sightings that overlap [from, to], newest first.
+func GetPlayerHistoryBetween(name string, from, to time.Time) ([]models.PlayerSighting, error) {
+	query := `
+	SELECT ps.seen_at, ps.disconnected_at, s.address, s.port, COALESCE(s.name, ''), COALESCE(s.game, '')
+	FROM player_sightings ps
+	JOIN players p ON ps.player_id = p.id
+	JOIN server_sightings ss ON ps.server_sighting_id = ss.id
+	JOIN servers s ON ss.server_id = s.id
+	WHERE LOWER(p.name) = LOWER(?)
+		AND ps.seen_at <= ? AND (ps.disconnected_at IS NULL OR ps.disconnected_at >= ?)
+	ORDER BY ps.seen_at DESC
+	`
+	rows, err := ReadDB.Query(query, name, formatTime(to), formatTime(from))
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var history []models.PlayerSighting
+	for rows.Next() {
+		var event models.PlayerSighting
+		event.Player = name
+		var disconnectedAt sql.NullTime
+		err := rows.Scan(&event.ConnectedAt, &disconnectedAt, &event.Address, &event.Port, &event.ServerName, &event.Game)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		if disconnectedAt.Valid {
+			event.DisconnectedAt = &disconnectedAt.Time
+		}
+		history = append(history, event)
+	}
+
+	return history, rows.Err()
+}
+
+// GetServerHistoryBetween returns the server's sightings that overlap [from, to], newest first.
+func GetServerHistoryBetween(address string, port int, from, to time.Time) ([]models.ServerSighting, error) {
+	query := `
+	SELECT ss.seen_at, ss.disconnected_at
+	FROM server_sightings ss
+	JOIN servers s ON ss.server_id = s.id
+	WHERE s.address = ? AND s.port = ?
+		AND ss.seen_at <= ? AND (ss.disconnected_at IS NULL OR ss.disconnected_at >= ?)
+	ORDER BY ss.seen_at DESC
+	`
+	rows, err := ReadDB.Query(query, address, port, formatTime(to), formatTime(from))
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var history []models.ServerSighting
+	for rows.Next() {
+		var sighting models.ServerSighting
+		var disconnectedAt sql.NullTime
+		if err := rows.Scan(&sighting.SeenAt, &disconnectedAt); err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		if disconnectedAt.Valid {
+			sighting.DisconnectedAt = &disconnectedAt.Time
+		}
+		history = append(history, sighting)
+	}
+
+	return history, rows.Err()
+}
+
+// GetSnapshotTimes returns the times of all snapshots taken within [from, to], oldest first.
+func GetSnapshotTimes(from, to time.Time) ([]time.Time, error) {
+	rows, err := ReadDB.Query(`
+		SELECT timestamp FROM snapshots
+		WHERE timestamp >= ? AND timestamp <= ?
+		ORDER BY timestamp ASC
+	`, formatTime(from), formatTime(to))
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var times []time.Time
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		times = append(times, t)
+	}
+
+	return times, rows.Err()
+}
+
+// GetServerPlayerSightingsBetween returns the sightings of every player on the
+// server that overlap [from, to], oldest first.
+func GetServerPlayerSightingsBetween(address string, port int, from, to time.Time) ([]models.PlayerSighting, error) {
+	query := `
+	SELECT p.name, ps.seen_at, ps.disconnected_at, COALESCE(s.name, ''), COALESCE(s.game, '')
+	FROM player_sightings ps
+	JOIN players p ON ps.player_id = p.id
+	JOIN server_sightings ss ON ps.server_sighting_id = ss.id
+	JOIN servers s ON ss.server_id = s.id
+	WHERE s.address = ? AND s.port = ?
+		AND ps.seen_at <= ? AND (ps.disconnected_at IS NULL OR ps.disconnected_at >= ?)
+	ORDER BY ps.seen_at ASC
+	`
+	rows, err := ReadDB.Query(query, address, port, formatTime(to), formatTime(from))
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var history []models.PlayerSighting
+	for rows.Next() {
+		event := models.PlayerSighting{Address: address, Port: port}
+		var disconnectedAt sql.NullTime
+		err := rows.Scan(&event.Player, &event.ConnectedAt, &disconnectedAt, &event.ServerName, &event.Game)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		if disconnectedAt.Valid {
+			event.DisconnectedAt = &disconnectedAt.Time
+		}
+		history = append(history, event)
+	}
+
+	return history, rows.Err()
+}
+
+// GetServerPlayerCounts returns the player count of the server in every
+// snapshot taken during [from, to], oldest first. Snapshots the server is
+// missing from count as zero players, it was not listed and so offline.
+func GetServerPlayerCounts(address string, port int, from, to time.Time) ([]models.PlayerCount, error) {
+	rows, err := ReadDB.Query(`
+		SELECT sn.timestamp, COALESCE(ss.clients, 0)
+		FROM snapshots sn
+		LEFT JOIN snapshot_servers ss ON ss.snapshot_id = sn.id AND ss.address = ? AND ss.port = ?
+		WHERE sn.timestamp >= ? AND sn.timestamp <= ?
+		ORDER BY sn.timestamp ASC
+	`, address, port, formatTime(from), formatTime(to))
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []models.PlayerCount
+	for rows.Next() {
+		var count models.PlayerCount
+		if err := rows.Scan(&count.Time, &count.Players); err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
+
+// GetServerRosterAt returns the sightings of the players online on the server
+// at the given instant, earliest join first.
+func GetServerRosterAt(address string, port int, at time.Time) ([]models.PlayerSighting, error) {
+	query := `
+	SELECT p.name, ps.seen_at, ps.disconnected_at, COALESCE(s.name, ''), COALESCE(s.game, '')
+	FROM player_sightings ps
+	JOIN players p ON ps.player_id = p.id
+	JOIN server_sightings ss ON ps.server_sighting_id = ss.id
+	JOIN servers s ON ss.server_id = s.id
+	WHERE s.address = ? AND s.port = ?
+		AND ps.seen_at <= ? AND (ps.disconnected_at IS NULL OR ps.disconnected_at > ?)
+	ORDER BY ps.seen_at ASC
+	`
+	rows, err := ReadDB.Query(query, address, port, formatTime(at), formatTime(at))
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var roster []models.PlayerSighting
+	for rows.Next() {
+		event := models.PlayerSighting{Address: address, Port: port}
+		var disconnectedAt sql.NullTime
+		err := rows.Scan(&event.Player, &event.ConnectedAt, &disconnectedAt, &event.ServerName, &event.Game)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		if disconnectedAt.Valid {
+			event.DisconnectedAt = &disconnectedAt.Time
+		}
+		roster = append(roster, event)
+	}
+
+	return roster, rows.Err()
+}
@@ -0,0 +1,187 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// ServerFilter narrows and orders ListServers. Zero values disable a filter.
+type ServerFilter struct {
+	Online         *bool
+	Game           string // Exact gameid, case-insensitive
+	NameContains   string // Case-insensitive substring of the server name
+	MinPlayers     *int
+	MaxPlayers     *int
+	FirstSeenAfter *time.Time
+	Address        string      // Exact address
+	Port           int         // Exact port, with Address
+	Keys           []ServerKey // Any of these servers
+	Sort           string      // "players", "uptime", "name" or "last_seen"
+	Descending     bool
+	Limit          int
+	Offset         int
+}
+
+// ServerKey identifies a server.
+type ServerKey struct {
+	Address string
+	Port    int
+}
+
+// serverSortColumns maps ServerFilter.Sort to ORDER BY expressions. Uptime is
+// ordered by online_since, so its direction is inverted: the longest running
+// server started first. Offline servers have no uptime and sort last.
+var serverSortColumns = map[string]string{
+	"players":   "players %s",
+	"uptime":    "online_since IS NULL, online_since %s",
+	"name":      "name COLLATE NOCASE %s",
+	"last_seen": "last_seen %s",
+}
+
+// serverStatusQuery computes the current state of every known server. A server
+// is online while it has an open sighting; duplicate open sightings (see
+// doctor) are collapsed onto the newest one.
+const serverStatusQuery = `
+	WITH open AS (
+		SELECT server_id, MAX(id) AS id, MAX(seen_at) AS seen_at
+		FROM server_sightings
+		WHERE disconnected_at IS NULL
+		GROUP BY server_id
+	),
+	status AS (
+		SELECT
+			s.address,
+			s.port,
+			COALESCE(s.name, '') AS name,
+			COALESCE(s.game, '') AS game,
+			open.id IS NOT NULL AS online,
+			CASE WHEN open.id IS NULL THEN 0 ELSE (
+				SELECT COUNT(*) FROM player_sightings ps
+				WHERE ps.server_sighting_id = open.id AND ps.disconnected_at IS NULL
+			) END AS players,
+			open.seen_at AS online_since,
+			COALESCE(s.first_seen, '') AS first_seen,
+			CASE WHEN open.id IS NOT NULL THEN ? ELSE COALESCE(
+				(SELECT MAX(disconnected_at) FROM server_sightings WHERE server_id = s.id),
+				s.last_seen, ''
+			) END AS last_seen
+		FROM servers s
+		LEFT JOIN open ON open.server_id = s.id
+	)
+`
+
+// ListServers returns known servers with their current status matching the
+// filter, along with the total number of matches before Limit and Offset.
+func ListServers(filter ServerFilter) ([]models.ServerStatus, int, error) {
+	now := formatTime(time.Now())
+	args := []any{now}
+	var where []string
+
+	if filter.Online != nil {
+		where = append(where, "online = ?")
+		args = append(args, *filter.Online)
+	}
+	if filter.Game != "" {
+		where = append(where, "LOWER(game) = LOWER(?)")
+		args = append(args, filter.Game)
+	}
+	if filter.NameContains != "" {
+		where = append(where, "INSTR(LOWER(name), LOWER(?)) > 0")
+		args = append(args, filter.NameContains)
+	}
+	if filter.MinPlayers != nil {
+		where = append(where, "players >= ?")
+		args = append(args, *filter.MinPlayers)
+	}
+	if filter.MaxPlayers != nil {
+		where = append(where, "players <= ?")
+		args = append(args, *filter.MaxPlayers)
+	}
+	if filter.Address != "" {
+		where = append(where, "address = ?")
+		args = append(args, filter.Address)
+	}
+	if filter.Port != 0 {
+		where = append(where, "port = ?")
+		args = append(args, filter.Port)
+	}
+	if len(filter.Keys) > 0 {
+		values := make([]string, len(filter.Keys))
+		for i, key := range filter.Keys {
+			values[i] = "(?, ?)"
+			args = append(args, key.Address, key.Port)
+		}
+		where = append(where, "(address, port) IN (VALUES "+strings.Join(values, ", ")+")")
+	}
+	if filter.FirstSeenAfter != nil {
+		where = append(where, "first_seen > ?")
+		args = append(args, formatTime(*filter.FirstSeenAfter))
+	}
+
+	whereClause := ""
+	if len(where) > 0 {
+		whereClause = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	err := ReadDB.QueryRow(serverStatusQuery+"SELECT COUNT(*) FROM status "+whereClause, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query failed: %w", err)
+	}
+
+	sortExpr, ok := serverSortColumns[filter.Sort]
+	if !ok {
+		sortExpr = serverSortColumns["players"]
+	}
+	direction := "ASC"
+	if filter.Descending != (filter.Sort == "uptime") {
+		direction = "DESC"
+	}
+	orderClause := "ORDER BY " + fmt.Sprintf(sortExpr, direction) + ", address, port"
+
+	query := serverStatusQuery + `
+		SELECT address, port, name, game, online, players, online_since, first_seen, last_seen
+		FROM status
+		` + whereClause + `
+		` + orderClause + `
+		LIMIT ? OFFSET ?`
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1 // SQLite: no limit
+	}
+	rows, err := ReadDB.Query(query, append(args, limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var servers []models.ServerStatus
+	for rows.Next() {
+		var server models.ServerStatus
+		var onlineSince sql.NullString
+		var firstSeen, lastSeen string
+		err := rows.Scan(&server.Address, &server.Port, &server.Name, &server.Game, &server.Online,
+			&server.Players, &onlineSince, &firstSeen, &lastSeen)
+		if err != nil {
+			return nil, 0, fmt.Errorf("row scan failed: %w", err)
+		}
+
+		if onlineSince.Valid {
+			t, err := parseTime(onlineSince.String)
+			if err != nil {
+				return nil, 0, err
+			}
+			server.OnlineSince = &t
+		}
+		// Servers written before timestamps were tracked may lack these
+		server.FirstSeen, _ = parseTime(firstSeen)
+		server.LastSeen, _ = parseTime(lastSeen)
+
+		servers = append(servers, server)
+	}
+
+	return servers, total, rows.Err()
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"fmt"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// GetGlobalCounts computes network wide totals as of now, counting servers
+// and players first seen since the given time as new.
+func GetGlobalCounts(now, since time.Time) (models.GlobalCounts, error) {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	// Weekday counts from Sunday, weeks start on Monday
+	week := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+
+	var counts models.GlobalCounts
+	err := ReadDB.QueryRow(`
+		SELECT
+			(SELECT COUNT(DISTINCT server_id) FROM server_sightings WHERE disconnected_at IS NULL),
+			(SELECT COUNT(DISTINCT player_id) FROM player_sightings WHERE disconnected_at IS NULL),
+			(SELECT COUNT(DISTINCT player_id) FROM player_sightings
+				WHERE seen_at <= ?1 AND (disconnected_at IS NULL OR disconnected_at >= ?2)),
+			(SELECT COUNT(DISTINCT player_id) FROM player_sightings
+				WHERE seen_at <= ?1 AND (disconnected_at IS NULL OR disconnected_at >= ?3)),
+			(SELECT COUNT(*) FROM servers WHERE first_seen >= ?4),
+			(SELECT COUNT(*) FROM (
+				SELECT MIN(seen_at) AS first_seen FROM player_sightings GROUP BY player_id
+			) WHERE first_seen >= ?4)
+	`, formatTime(now), formatTime(today), formatTime(week), formatTime(since)).Scan(
+		&counts.ServersOnline,
+		&counts.PlayersOnline,
+		&counts.UniquePlayersToday,
+		&counts.UniquePlayersThisWeek,
+		&counts.NewServers,
+		&counts.NewPlayers,
+	)
+	if err != nil {
+		return counts, fmt.Errorf("query failed: %w", err)
+	}
+	return counts, nil
+}
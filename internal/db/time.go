@@ -0,0 +1,36 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// timeLayout is the canonical text form of every timestamp in the database:
+// UTC, fixed width and millisecond precision. Fixed width keeps plain string
+// comparison and ORDER BY consistent with time order, and the layout is one
+// both SQLite's date functions and the sqlite3 driver parse natively.
+const timeLayout = "2006-01-02 15:04:05.000"
+
+// formatTime converts t to the canonical stored representation.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// parseTime parses a canonical timestamp. Columns read through an aggregate
+// such as MIN() or MAX() lose their DATETIME type and come back as text.
+func parseTime(s string) (time.Time, error) {
+	t, err := time.ParseInLocation(timeLayout, s, time.UTC)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q: %w", s, err)
+	}
+	return t, nil
+}
+
+// eventTime returns the timestamp to store for an event, falling back to the
+// current time for events built without one.
+func eventTime(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now().UTC()
+	}
+	return t.UTC()
+}
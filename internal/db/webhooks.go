@@ -0,0 +1,224 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// webhookDeliveryLogSize is how many delivery attempts are kept per
+// subscription, older ones are pruned as new ones are recorded.
+const webhookDeliveryLogSize = 500
+
+const webhookColumns = `
+	id, url, secret, players, servers, types, enabled, consecutive_failures, disabled_reason, created_at
+`
+
+// CreateWebhook stores a new subscription and returns it with its ID set.
+func CreateWebhook(sub models.WebhookSubscription) (models.WebhookSubscription, error) {
+	sub.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	err := write(func() error {
+		res, err := DB.Exec(`
+			INSERT INTO webhook_subscriptions (url, secret, players, servers, types, enabled, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, sub.URL, sub.Secret, strings.Join(sub.Players, ","), strings.Join(sub.Servers, ","),
+			strings.Join(sub.Types, ","), sub.Enabled, formatTime(sub.CreatedAt))
+		if err != nil {
+			return fmt.Errorf("failed to insert webhook: %w", err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get webhook ID: %w", err)
+		}
+		sub.ID = int(id)
+		return nil
+	})
+	return sub, err
+}
+
+// UpdateWebhook saves the URL, secret, filters and state of a subscription, wrapping
+// ErrNotFound when it does not exist.
+func UpdateWebhook(sub models.WebhookSubscription) error {
+	return write(func() error {
+		res, err := DB.Exec(`
+			UPDATE webhook_subscriptions
+			SET url = ?, secret = ?, players = ?, servers = ?, types = ?, enabled = ?, consecutive_failures = ?, disabled_reason = ?
+			WHERE id = ?
+		`, sub.URL, sub.Secret, strings.Join(sub.Players, ","), strings.Join(sub.Servers, ","), strings.Join(sub.Types, ","),
+			sub.Enabled, sub.ConsecutiveFailures, sub.DisabledReason, sub.ID)
+		if n, err := rowsAffected(res, err); err != nil {
+			return fmt.Errorf("failed to update webhook: %w", err)
+		} else if n == 0 {
+			return fmt.Errorf("no webhook %d: %w", sub.ID, ErrNotFound)
+		}
+		return nil
+	})
+}
+
+// SetWebhookHealth records the outcome of a delivery on its subscription. A
+// non-empty disabledReason disables the subscription.
+func SetWebhookHealth(id, consecutiveFailures int, disabledReason string) error {
+	return write(func() error {
+		_, err := DB.Exec(`
+			UPDATE webhook_subscriptions
+			SET consecutive_failures = ?, disabled_reason = ?, enabled = enabled AND ? = ''
+			WHERE id = ?
+		`, consecutiveFailures, disabledReason, disabledReason, id)
+		if err != nil {
+			return fmt.Errorf("failed to update webhook health: %w", err)
+		}
+		return nil
+	})
+}
+
+// DeleteWebhook removes a subscription and its delivery log, wrapping
+// ErrNotFound when it does not exist.
+func DeleteWebhook(id int) error {
+	return write(func() error {
+		tx, err := DB.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Exec(`DELETE FROM webhook_subscriptions WHERE id = ?`, id)
+		if n, err := rowsAffected(res, err); err != nil {
+			return fmt.Errorf("failed to delete webhook: %w", err)
+		} else if n == 0 {
+			return fmt.Errorf("no webhook %d: %w", id, ErrNotFound)
+		}
+		if _, err := tx.Exec(`DELETE FROM webhook_deliveries WHERE subscription_id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete deliveries: %w", err)
+		}
+
+		return tx.Commit()
+	})
+}
+
+// GetWebhook retrieves one subscription, wrapping ErrNotFound when it does
+// not exist.
+func GetWebhook(id int) (models.WebhookSubscription, error) {
+	sub, err := scanWebhook(ReadDB.QueryRow(`SELECT `+webhookColumns+` FROM webhook_subscriptions WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return sub, fmt.Errorf("no webhook %d: %w", id, ErrNotFound)
+	}
+	return sub, err
+}
+
+// GetWebhooks retrieves every subscription, oldest first.
+func GetWebhooks() ([]models.WebhookSubscription, error) {
+	rows, err := ReadDB.Query(`SELECT ` + webhookColumns + ` FROM webhook_subscriptions ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []models.WebhookSubscription
+	for rows.Next() {
+		sub, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func scanWebhook(row interface{ Scan(...any) error }) (models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	var players, servers, types string
+	err := row.Scan(&sub.ID, &sub.URL, &sub.Secret, &players, &servers, &types,
+		&sub.Enabled, &sub.ConsecutiveFailures, &sub.DisabledReason, &sub.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return sub, err
+		}
+		return sub, fmt.Errorf("row scan failed: %w", err)
+	}
+	sub.Players = splitList(players)
+	sub.Servers = splitList(servers)
+	sub.Types = splitList(types)
+	return sub, nil
+}
+
+func splitList(list string) []string {
+	if list == "" {
+		return []string{}
+	}
+	return strings.Split(list, ",")
+}
+
+// RecordWebhookDelivery adds an attempt to the delivery log of its
+// subscription.
+func RecordWebhookDelivery(d models.WebhookDelivery) error {
+	return write(func() error {
+		tx, err := DB.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Exec(`
+			INSERT INTO webhook_deliveries
+			(subscription_id, event_id, event_type, attempt, status_code, error, duration_ms, delivered_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, d.SubscriptionID, int64(d.EventID), d.EventType, d.Attempt, d.StatusCode, d.Error,
+			d.Duration.Milliseconds(), formatTime(d.DeliveredAt))
+		if err != nil {
+			return fmt.Errorf("failed to insert delivery: %w", err)
+		}
+
+		_, err = tx.Exec(`
+			DELETE FROM webhook_deliveries
+			WHERE subscription_id = ?1 AND id <= (
+				SELECT id FROM webhook_deliveries WHERE subscription_id = ?1
+				ORDER BY id DESC LIMIT 1 OFFSET ?2
+			)
+		`, d.SubscriptionID, webhookDeliveryLogSize)
+		if err != nil {
+			return fmt.Errorf("failed to prune deliveries: %w", err)
+		}
+
+		return tx.Commit()
+	})
+}
+
+// GetWebhookDeliveries retrieves a page of the delivery log of a
+// subscription, newest first, and the number of logged attempts.
+func GetWebhookDeliveries(id, limit, offset int) ([]models.WebhookDelivery, int, error) {
+	var total int
+	err := ReadDB.QueryRow(`SELECT COUNT(*) FROM webhook_deliveries WHERE subscription_id = ?`, id).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("count failed: %w", err)
+	}
+
+	rows, err := ReadDB.Query(`
+		SELECT id, subscription_id, event_id, event_type, attempt, status_code, error, duration_ms, delivered_at
+		FROM webhook_deliveries
+		WHERE subscription_id = ?
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, id, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var eventID, durationMs int64
+		err := rows.Scan(&d.ID, &d.SubscriptionID, &eventID, &d.EventType, &d.Attempt,
+			&d.StatusCode, &d.Error, &durationMs, &d.DeliveredAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("row scan failed: %w", err)
+		}
+		d.EventID = uint64(eventID)
+		d.Duration = time.Duration(durationMs) * time.Millisecond
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, total, rows.Err()
+}
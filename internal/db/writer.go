@@ -0,0 +1,32 @@
+package db
+
+import (
+	"teamacedia/minestalker/internal/metrics"
+	"time"
+)
+
+// writeRequest is a mutation waiting for its turn on the writer goroutine.
+type writeRequest struct {
+	fn   func() error
+	done chan error
+}
+
+var writeQueue = make(chan writeRequest, 64)
+
+// runWriter executes queued mutations one at a time. It is the only goroutine
+// that touches DB once InitDB has returned, so SQLite never sees two writers
+// competing for the lock.
+func runWriter() {
+	for req := range writeQueue {
+		req.done <- req.fn()
+	}
+}
+
+// write runs fn on the writer goroutine and waits for its result. fn must not
+// call write itself, the queue is not reentrant and would deadlock.
+func write(fn func() error) error {
+	defer metrics.DBWriteDuration.Since(time.Now())
+	done := make(chan error, 1)
+	writeQueue <- writeRequest{fn: fn, done: done}
+	return <-done
+}
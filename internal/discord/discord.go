@@ -7,11 +7,15 @@ import (
 	"os/signal"
 	"syscall"
 	"teamacedia/minestalker/internal/db"
+	"teamacedia/minestalker/internal/metrics"
 	"teamacedia/minestalker/internal/models"
 
 	"github.com/bwmarrin/discordgo"
 )
 
+// adminPermission hides the admin commands from everyone but server administrators
+var adminPermission int64 = discordgo.PermissionAdministrator
+
 var (
 	session  *discordgo.Session
 	cmdIDs   []*discordgo.ApplicationCommand
@@ -120,6 +124,63 @@ var (
 				},
 			},
 		},
+		{
+			Name:                     "optout",
+			Description:              "Manage players who asked not to be tracked (admin only)",
+			DefaultMemberPermissions: &adminPermission,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "add",
+					Description: "Stop tracking a player",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "playername",
+							Description: "Name of the player",
+							Required:    true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "reason",
+							Description: "Why the player opted out",
+							Required:    false,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "remove",
+					Description: "Resume tracking a player",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "playername",
+							Description: "Name of the player",
+							Required:    true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "list",
+					Description: "List opted-out players",
+				},
+			},
+		},
+		{
+			Name:                     "erase",
+			Description:              "Delete all stored data about a player (admin only)",
+			DefaultMemberPermissions: &adminPermission,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "playername",
+					Description: "Name of the player",
+					Required:    true,
+				},
+			},
+		},
 	}
 )
 
@@ -130,6 +191,11 @@ func interactionHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
 
 	data := i.ApplicationCommandData()
 
+	if data.Name == "optout" || data.Name == "erase" {
+		adminInteractionHandler(s, i, data)
+		return
+	}
+
 	if data.Name == "playertracker" {
 		if len(data.Options) == 0 {
 			return
@@ -144,6 +210,16 @@ func interactionHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
 				DiscordID:  discordId,
 			}
 
+			if db.IsOptedOut(playerName) {
+				embed := &discordgo.MessageEmbed{
+					Title:       "Error",
+					Description: "Player **" + playerName + "** has opted out of tracking.",
+					Color:       0xFF0000, // Red
+				}
+				replyEmbed(s, i, embed)
+				return
+			}
+
 			if db.CheckIfTrackingAlertExists(alert) {
 				embed := &discordgo.MessageEmbed{
 					Title:       "Error",
@@ -412,10 +488,9 @@ func interactionHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
 			if sighting.DisconnectedAt != nil {
 				disconnected = fmt.Sprintf("<t:%d:f>", sighting.DisconnectedAt.Unix())
 			}
-			server, err := db.GetServerInfo(sighting.Address, sighting.Port)
 			serverName := "Unknown"
-			if server.Name != "" && err == nil {
-				serverName = server.Name
+			if sighting.ServerName != "" {
+				serverName = sighting.ServerName
 			}
 			response += fmt.Sprintf(
 				"- Server: **%s** ( %s:%d )\n  Connected at: <t:%d:f>\n  Disconnected at: %s\n",
@@ -436,6 +511,141 @@ func interactionHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	}
 }
 
+func adminInteractionHandler(s *discordgo.Session, i *discordgo.InteractionCreate, data discordgo.ApplicationCommandInteractionData) {
+	// Default member permissions can be overridden per guild, so check again here
+	if i.Member == nil || i.Member.Permissions&discordgo.PermissionAdministrator == 0 {
+		embed := &discordgo.MessageEmbed{
+			Title:       "Error",
+			Description: "This command is restricted to server administrators.",
+			Color:       0xFF0000, // Red
+		}
+		replyEmbed(s, i, embed)
+		return
+	}
+
+	if data.Name == "optout" {
+		if len(data.Options) == 0 {
+			return
+		}
+
+		switch data.Options[0].Name {
+		case "add":
+			optOut := models.OptOut{
+				PlayerName: data.Options[0].Options[0].StringValue(),
+				AddedBy:    "discord:" + i.Member.User.ID,
+			}
+			if len(data.Options[0].Options) > 1 {
+				optOut.Reason = data.Options[0].Options[1].StringValue()
+			}
+
+			err := db.AddOptOut(optOut)
+			if err != nil {
+				embed := &discordgo.MessageEmbed{
+					Title:       "Error",
+					Description: "Error adding player to opt-out list: " + err.Error(),
+					Color:       0xFF0000, // Red
+				}
+				replyEmbed(s, i, embed)
+				return
+			}
+			embed := &discordgo.MessageEmbed{
+				Title:       "Player Opted Out",
+				Description: "Player **" + optOut.PlayerName + "** will no longer be tracked or alerted on.\n Use `/erase` to also delete their existing data.",
+				Color:       0x00FF00, // Green
+			}
+			replyEmbed(s, i, embed)
+		case "remove":
+			playerName := data.Options[0].Options[0].StringValue()
+			if !db.IsOptedOut(playerName) {
+				embed := &discordgo.MessageEmbed{
+					Title:       "Error",
+					Description: "Player **" + playerName + "** is not opted out.",
+					Color:       0xFF0000, // Red
+				}
+				replyEmbed(s, i, embed)
+				return
+			}
+
+			err := db.RemoveOptOut(playerName)
+			if err != nil {
+				embed := &discordgo.MessageEmbed{
+					Title:       "Error",
+					Description: "Error removing player from opt-out list: " + err.Error(),
+					Color:       0xFF0000, // Red
+				}
+				replyEmbed(s, i, embed)
+				return
+			}
+			embed := &discordgo.MessageEmbed{
+				Title:       "Opt-Out Removed",
+				Description: "Player **" + playerName + "** will be tracked again.",
+				Color:       0x00FF00, // Green
+			}
+			replyEmbed(s, i, embed)
+		case "list":
+			optOuts, err := db.GetOptOuts()
+			if err != nil {
+				embed := &discordgo.MessageEmbed{
+					Title:       "Error",
+					Description: "Error retrieving opt-out list: " + err.Error(),
+					Color:       0xFF0000, // Red
+				}
+				replyEmbed(s, i, embed)
+				return
+			}
+			if len(optOuts) == 0 {
+				embed := &discordgo.MessageEmbed{
+					Title:       "No Opted-Out Players",
+					Description: "No players have opted out of tracking.",
+					Color:       0xFFFF00, // Yellow
+				}
+				replyEmbed(s, i, embed)
+				return
+			}
+			response := "Opted-out players:\n"
+			for _, optOut := range optOuts {
+				response += "- **" + optOut.PlayerName + "**"
+				if optOut.Reason != "" {
+					response += " (" + optOut.Reason + ")"
+				}
+				response += "\n"
+			}
+			embed := &discordgo.MessageEmbed{
+				Title:       "Opted-Out Players",
+				Description: response,
+				Color:       0x00FFFF, // Cyan
+			}
+			replyEmbed(s, i, embed)
+		default:
+			reply(s, i, "Unknown subcommand")
+			return
+		}
+	}
+
+	if data.Name == "erase" {
+		playerName := data.Options[0].StringValue()
+		erasure, err := db.ErasePlayer(playerName, "discord:"+i.Member.User.ID)
+		if err != nil {
+			embed := &discordgo.MessageEmbed{
+				Title:       "Error",
+				Description: "Error erasing player data: " + err.Error(),
+				Color:       0xFF0000, // Red
+			}
+			replyEmbed(s, i, embed)
+			return
+		}
+		embed := &discordgo.MessageEmbed{
+			Title: "Player Data Erased",
+			Description: fmt.Sprintf(
+				"Erased player **%s**:\n- %d sightings deleted\n- %d snapshot mentions removed\n- %d tracking alerts deleted",
+				playerName, erasure.SightingsDeleted, erasure.SnapshotMentionsRemoved, erasure.AlertsDeleted,
+			),
+			Color: 0x00FF00, // Green
+		}
+		replyEmbed(s, i, embed)
+	}
+}
+
 func reply(s *discordgo.Session, i *discordgo.InteractionCreate, msg string) {
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
@@ -460,7 +670,9 @@ func replyEmbed(s *discordgo.Session, i *discordgo.InteractionCreate, embed *dis
 	}
 }
 
-func DmUser(userID string, content string) error {
+func DmUser(userID string, content string) (err error) {
+	defer func() { metrics.DiscordDMs.Inc(metrics.Outcome(err)) }()
+
 	// Create or fetch DM channel
 	channel, err := session.UserChannelCreate(userID)
 	if err != nil {
@@ -476,7 +688,9 @@ func DmUser(userID string, content string) error {
 	return nil
 }
 
-func DmUserEmbed(userID string, embed *discordgo.MessageEmbed) error {
+func DmUserEmbed(userID string, embed *discordgo.MessageEmbed) (err error) {
+	defer func() { metrics.DiscordDMs.Inc(metrics.Outcome(err)) }()
+
 	// Create or fetch DM channel
 	channel, err := session.UserChannelCreate(userID)
 	if err != nil {
@@ -0,0 +1,124 @@
+package events
+
+import (
+	"sync"
+	"teamacedia/minestalker/internal/metrics"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// replaySize is how many of the latest events are kept for clients resuming
+// after a disconnect.
+const replaySize = 1000
+
+// subscriberBuffer is how many events a subscriber may fall behind before it
+// is dropped. Dropped clients reconnect and catch up from the replay buffer.
+const subscriberBuffer = 256
+
+// Event is a tracking event as published to subscribers. IDs increase
+// strictly, also across restarts, since they start from the boot time.
+type Event struct {
+	ID uint64
+	models.TrackingEvent
+}
+
+// Subscription receives every published event from the moment it was
+// created, until it is closed or falls too far behind, either way C is closed.
+type Subscription struct {
+	C <-chan Event
+
+	c chan Event
+}
+
+var (
+	mu          sync.Mutex
+	nextID      = uint64(time.Now().UnixMilli()) * 1000
+	replay      []Event
+	subscribers = map[*Subscription]bool{}
+	closed      bool
+)
+
+// Publish assigns IDs to events, stores them for replay and hands them to
+// every subscriber.
+func Publish(trackingEvents []models.TrackingEvent) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, te := range trackingEvents {
+		event := Event{ID: nextID, TrackingEvent: te}
+		nextID++
+		metrics.Events.Inc(te.Type)
+
+		replay = append(replay, event)
+		if len(replay) > replaySize {
+			replay = replay[len(replay)-replaySize:]
+		}
+
+		for sub := range subscribers {
+			select {
+			case sub.c <- event:
+			default:
+				unsubscribe(sub)
+			}
+		}
+	}
+}
+
+// Subscribe starts a subscription. With a non-zero lastID it also returns the
+// buffered events published after that ID, or all of them when lastID is older
+// than the buffer, in order and without gaps before the live events.
+func Subscribe(lastID uint64) (*Subscription, []Event) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	c := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: c, c: c}
+	if closed {
+		close(c)
+		return sub, nil
+	}
+	subscribers[sub] = true
+
+	var missed []Event
+	if lastID != 0 {
+		for _, event := range replay {
+			if event.ID > lastID {
+				missed = append(missed, event)
+			}
+		}
+	}
+	return sub, missed
+}
+
+// Close ends the subscription.
+func (sub *Subscription) Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	unsubscribe(sub)
+}
+
+func unsubscribe(sub *Subscription) {
+	if subscribers[sub] {
+		delete(subscribers, sub)
+		close(sub.c)
+	}
+}
+
+// Shutdown closes every subscription and refuses new ones, so long-lived
+// streams end when the HTTP server shuts down.
+func Shutdown() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	closed = true
+	for sub := range subscribers {
+		unsubscribe(sub)
+	}
+}
+
+// Closed reports whether Shutdown was called.
+func Closed() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return closed
+}
@@ -0,0 +1,131 @@
+package events
+
+import (
+	"teamacedia/minestalker/internal/models"
+	"testing"
+)
+
+// resetBus empties the replay buffer and subscribers, and restarts IDs at firstID.
+func resetBus(firstID uint64) {
+	mu.Lock()
+	defer mu.Unlock()
+	nextID = firstID
+	replay = nil
+	subscribers = map[*Subscription]bool{}
+	closed = false
+}
+
+func publishN(n int) {
+	batch := make([]models.TrackingEvent, n)
+	for i := range batch {
+		batch[i] = models.TrackingEvent{Type: "playerJoin", Server: "example.org", Port: 30000, Player: "alice"}
+	}
+	Publish(batch)
+}
+
+func TestReplay(t *testing.T) {
+	cases := []struct {
+		name      string
+		published int
+		lastID    uint64
+		first     uint64 // ID of the first missed event, 0 for none
+		missed    int
+	}{
+		{"no last ID", 5, 0, 0, 0},
+		{"resuming midway", 5, 102, 103, 2},
+		{"up to date", 5, 104, 0, 0},
+		{"older than the buffer", 5, 50, 100, 5},
+		{"from a later ID", 5, 200, 0, 0},
+		{"buffer overflowed", replaySize + 10, 100, 110, replaySize},
+	}
+
+	for _, c := range cases {
+		resetBus(100)
+		publishN(c.published)
+
+		sub, missed := Subscribe(c.lastID)
+		sub.Close()
+		if len(missed) != c.missed {
+			t.Errorf("%s: %d missed events, want %d", c.name, len(missed), c.missed)
+			continue
+		}
+		for i, event := range missed {
+			if event.ID != c.first+uint64(i) {
+				t.Errorf("%s: missed event %d has ID %d, want %d", c.name, i, event.ID, c.first+uint64(i))
+				break
+			}
+		}
+	}
+}
+
+func TestLiveEventsFollowReplay(t *testing.T) {
+	resetBus(100)
+	publishN(3)
+
+	sub, missed := Subscribe(100)
+	defer sub.Close()
+	publishN(2)
+
+	ids := []uint64{}
+	for _, event := range missed {
+		ids = append(ids, event.ID)
+	}
+	for range 2 {
+		ids = append(ids, (<-sub.C).ID)
+	}
+	for i, id := range ids {
+		if id != 101+uint64(i) {
+			t.Fatalf("received IDs %v, want 101 to 104 in order", ids)
+		}
+	}
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	resetBus(100)
+	sub, _ := Subscribe(0)
+	publishN(subscriberBuffer + 1)
+
+	n := 0
+	for range sub.C {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("received %d events before being dropped, want %d", n, subscriberBuffer)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	join := models.TrackingEvent{Type: "playerJoin", Server: "example.org", Port: 30000, Player: "Alice"}
+	online := models.TrackingEvent{Type: "serverOnline", Server: "example.org", Port: 30001}
+
+	cases := []struct {
+		players, servers, types string
+		join, online            bool
+	}{
+		{"", "", "", true, true},
+		{"alice", "", "", true, false},
+		{"bob", "", "", false, false},
+		{"", "example.org", "", true, true},
+		{"", "EXAMPLE.org:30001", "", false, true},
+		{"", "other.org", "", false, false},
+		{"", "", "serverOnline,serverOffline", false, true},
+		{"alice", "example.org:30000", "playerJoin", true, false},
+	}
+
+	for _, c := range cases {
+		filter, err := ParseFilter(c.players, c.servers, c.types)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q, %q, %q): %v", c.players, c.servers, c.types, err)
+		}
+		if got := filter.Match(join); got != c.join {
+			t.Errorf("%+v matches the join: %v, want %v", filter, got, c.join)
+		}
+		if got := filter.Match(online); got != c.online {
+			t.Errorf("%+v matches the server coming online: %v, want %v", filter, got, c.online)
+		}
+	}
+
+	if _, err := ParseFilter("", "", "playerJoin,teleport"); err == nil {
+		t.Error("ParseFilter accepted an unknown type")
+	}
+}
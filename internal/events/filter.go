@@ -0,0 +1,73 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+	"teamacedia/minestalker/internal/models"
+)
+
+// Types are the event types produced by the tracker.
+var Types = []string{"serverOnline", "serverOffline", "playerJoin", "playerLeave"}
+
+// Filter selects events. Empty lists match everything; otherwise an event
+// must match one entry of every non-empty list.
+type Filter struct {
+	Players []string // Case-insensitive player names, only player events match
+	Servers []string // "address" or "address:port"
+	Types   []string
+}
+
+// ParseFilter builds a filter from comma separated lists, validating types.
+func ParseFilter(players, servers, types string) (Filter, error) {
+	filter := Filter{
+		Players: splitList(players),
+		Servers: splitList(servers),
+		Types:   splitList(types),
+	}
+	for _, t := range filter.Types {
+		known := false
+		for _, k := range Types {
+			known = known || t == k
+		}
+		if !known {
+			return filter, fmt.Errorf("unknown event type %q", t)
+		}
+	}
+	return filter, nil
+}
+
+// Match reports whether the event passes the filter.
+func (f Filter) Match(event models.TrackingEvent) bool {
+	if len(f.Types) > 0 && !contains(f.Types, event.Type, false) {
+		return false
+	}
+	if len(f.Players) > 0 && (event.Player == "" || !contains(f.Players, event.Player, true)) {
+		return false
+	}
+	if len(f.Servers) > 0 {
+		address := fmt.Sprintf("%s:%d", event.Server, event.Port)
+		if !contains(f.Servers, event.Server, true) && !contains(f.Servers, address, true) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(list []string, value string, foldCase bool) bool {
+	for _, item := range list {
+		if item == value || (foldCase && strings.EqualFold(item, value)) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
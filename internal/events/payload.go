@@ -0,0 +1,32 @@
+package events
+
+// TimeLayout is RFC 3339 with a fixed millisecond fraction, the format of
+// every timestamp sent to API clients and webhooks.
+const TimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// Payload is an event as sent to clients of the event stream and the
+// WebSocket and posted to webhooks.
+type Payload struct {
+	ID            uint64 `json:"id"`
+	Type          string `json:"type"` // serverOnline, serverOffline, playerJoin or playerLeave
+	Time          string `json:"time"`
+	ServerAddress string `json:"server_address"`
+	ServerPort    int    `json:"server_port"`
+	ServerName    string `json:"server_name"`
+	Game          string `json:"game,omitempty"`   // Server events only
+	Player        string `json:"player,omitempty"` // Player events only
+}
+
+// Payload returns the event as sent to clients.
+func (e Event) Payload() Payload {
+	return Payload{
+		ID:            e.ID,
+		Type:          e.Type,
+		Time:          e.Timestamp.UTC().Format(TimeLayout),
+		ServerAddress: e.Server,
+		ServerPort:    e.Port,
+		ServerName:    e.Name,
+		Game:          e.Game,
+		Player:        e.Player,
+	}
+}
@@ -0,0 +1,233 @@
+// Package graph serves the tracked data as a GraphQL schema. Fields that
+// would otherwise cost a query per list item are resolved through per-query
+// loaders, see loader.go.
+package graph
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"teamacedia/minestalker/internal/db"
+	"teamacedia/minestalker/internal/models"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// maxDepth bounds the nesting of a query, player -> sightings -> server ->
+// playersOnline -> player and so on could otherwise be followed forever.
+const maxDepth = 10
+
+const schemaSource = `
+schema {
+	query: Query
+}
+
+scalar Time
+
+type Query {
+	# A player by name, players never seen have no sightings
+	player(name: String!): Player!
+	server(address: String!, port: Int!): Server
+	servers(online: Boolean, game: String, first: Int, after: String): ServerConnection!
+	# The latest snapshot taken at or before at, the latest overall without it
+	snapshot(at: Time): Snapshot
+	snapshotTimes(from: Time!, to: Time!): [Time!]!
+	games: [Game!]!
+	game(id: String!): Game
+}
+
+type Player {
+	name: String!
+	online: Boolean!
+	currentSession: Sighting
+	# Newest first
+	sightings(first: Int, after: String): SightingConnection!
+}
+
+type Sighting {
+	player: Player!
+	server: Server!
+	connectedAt: Time!
+	disconnectedAt: Time
+}
+
+type Server {
+	address: String!
+	port: Int!
+	name: String!
+	game: String!
+	online: Boolean!
+	players: Int!
+	onlineSince: Time
+	firstSeen: Time!
+	lastSeen: Time!
+	# Sessions in progress, earliest join first
+	playersOnline: [Sighting!]!
+	# Sessions overlapping [from, to], oldest first, over at most 31 days
+	sessions(from: Time!, to: Time!, first: Int, after: String): SightingConnection!
+}
+
+type Snapshot {
+	time: Time!
+	servers: [SnapshotServer!]!
+}
+
+type SnapshotServer {
+	server: Server!
+	name: String!
+	game: String!
+	clients: Int!
+	players: [Player!]!
+}
+
+type Game {
+	id: String!
+	serversTotal: Int!
+	playersTotal: Int!
+	servers(online: Boolean): [Server!]!
+}
+
+type ServerConnection {
+	totalCount: Int!
+	nodes: [Server!]!
+	pageInfo: PageInfo!
+}
+
+type SightingConnection {
+	totalCount: Int!
+	nodes: [Sighting!]!
+	pageInfo: PageInfo!
+}
+
+type PageInfo {
+	hasNextPage: Boolean!
+	endCursor: String
+}
+`
+
+// Every item of a page is resolved at once, so the loaders see all their keys
+// within one batch window instead of a handful at a time.
+var schema = graphql.MustParseSchema(schemaSource, &queryResolver{}, graphql.MaxDepth(maxDepth), graphql.MaxParallelism(maxPageSize))
+
+// Exec runs a query against the schema.
+func Exec(ctx context.Context, query, operationName string, variables map[string]any) *graphql.Response {
+	return schema.Exec(withLoaders(ctx), query, operationName, variables)
+}
+
+// loaders are shared by the resolvers of one query, as is its node count.
+type loaders struct {
+	nodes          atomic.Int64 // See spend
+	servers        *loader[db.ServerKey, models.ServerStatus]
+	sessions       *loader[string, models.PlayerSighting] // By lowercased name
+	rosters        *loader[db.ServerKey, []models.PlayerSighting]
+	gameServers    *loader[string, []models.ServerStatus] // By lowercased game
+	sightings      *loader[sightingsKey, db.SightingsPage]
+	serverSessions *loader[serverSessionsKey, db.SightingsPage]
+}
+
+// sightingsKey is a page of a player's sightings.
+type sightingsKey struct {
+	name          string // Lowercased
+	limit, offset int
+}
+
+// serverSessionsKey is a page of the sessions on a server during a window.
+type serverSessionsKey struct {
+	server        db.ServerKey
+	from, to      time.Time
+	limit, offset int
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loadersKey{}, &loaders{
+		servers:        newLoader(fetchServers),
+		sessions:       newLoader(db.GetOpenPlayerSightings),
+		rosters:        newLoader(db.GetOpenServerSightings),
+		gameServers:    newLoader(fetchGameServers),
+		sightings:      newLoader(fetchSightings),
+		serverSessions: newLoader(fetchServerSessions),
+	})
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+func fetchServers(keys []db.ServerKey) (map[db.ServerKey]models.ServerStatus, error) {
+	servers, _, err := db.ListServers(db.ServerFilter{Keys: keys})
+	if err != nil {
+		return nil, err
+	}
+	byKey := make(map[db.ServerKey]models.ServerStatus, len(servers))
+	for _, server := range servers {
+		byKey[db.ServerKey{Address: server.Address, Port: server.Port}] = server
+	}
+	return byKey, nil
+}
+
+// fetchGameServers lists every server once and groups them by game, there are
+// far fewer servers than the queries a game each would cost.
+func fetchGameServers(games []string) (map[string][]models.ServerStatus, error) {
+	servers, _, err := db.ListServers(db.ServerFilter{})
+	if err != nil {
+		return nil, err
+	}
+	byGame := make(map[string][]models.ServerStatus, len(games))
+	for _, server := range servers {
+		game := strings.ToLower(server.Game)
+		byGame[game] = append(byGame[game], server)
+	}
+	return byGame, nil
+}
+
+// fetchSightings queries the players asking for the same page together, which
+// is usually all of them.
+func fetchSightings(keys []sightingsKey) (map[sightingsKey]db.SightingsPage, error) {
+	type page struct{ limit, offset int }
+	names := map[page][]string{}
+	for _, key := range keys {
+		p := page{key.limit, key.offset}
+		names[p] = append(names[p], key.name)
+	}
+
+	pages := make(map[sightingsKey]db.SightingsPage, len(keys))
+	for p, names := range names {
+		found, err := db.GetPlayerSightingsPages(names, p.limit, p.offset)
+		if err != nil {
+			return nil, err
+		}
+		for name, sightings := range found {
+			pages[sightingsKey{name, p.limit, p.offset}] = sightings
+		}
+	}
+	return pages, nil
+}
+
+// fetchServerSessions queries the servers asking for the same window and page
+// together, which is usually all of them.
+func fetchServerSessions(keys []serverSessionsKey) (map[serverSessionsKey]db.SightingsPage, error) {
+	type page struct {
+		from, to      time.Time
+		limit, offset int
+	}
+	servers := map[page][]db.ServerKey{}
+	for _, key := range keys {
+		p := page{key.from, key.to, key.limit, key.offset}
+		servers[p] = append(servers[p], key.server)
+	}
+
+	pages := make(map[serverSessionsKey]db.SightingsPage, len(keys))
+	for p, servers := range servers {
+		found, err := db.GetServerSightingsPages(servers, p.from, p.to, p.limit, p.offset)
+		if err != nil {
+			return nil, err
+		}
+		for server, sessions := range found {
+			pages[serverSessionsKey{server, p.from, p.to, p.limit, p.offset}] = sessions
+		}
+	}
+	return pages, nil
+}
@@ -0,0 +1,115 @@
+package graph
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// batchWindow is how long a loader waits for sibling resolvers to ask for
+// more keys before querying. The executor resolves list items concurrently,
+// so a window of a few milliseconds collects most of them.
+const batchWindow = 2 * time.Millisecond
+
+// maxBatch bounds the keys of one fetch, keeping queries well below SQLite's
+// limit on bound parameters.
+const maxBatch = 500
+
+// loader batches and caches lookups by key for the lifetime of one query, so
+// that resolving a field on every item of a list costs one query instead of
+// one per item. List resolvers can queue the keys their items will need,
+// which then join the first batch any item dispatches.
+type loader[K comparable, V any] struct {
+	fetch func([]K) (map[K]V, error)
+
+	mu        sync.Mutex
+	results   map[K]*result[V]
+	queued    []K
+	scheduled bool
+}
+
+type result[V any] struct {
+	done  chan struct{}
+	value V
+	found bool
+	err   error
+}
+
+func newLoader[K comparable, V any](fetch func([]K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{fetch: fetch, results: make(map[K]*result[V])}
+}
+
+// queue adds keys to the next batch without dispatching it. Queued keys are
+// only fetched once something loads.
+func (l *loader[K, V]) queue(keys ...K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		l.entry(key)
+	}
+}
+
+// prime stores a value already known, sparing a fetch.
+func (l *loader[K, V]) prime(key K, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.results[key]; ok {
+		return
+	}
+	res := &result[V]{done: make(chan struct{}), value: value, found: true}
+	close(res.done)
+	l.results[key] = res
+}
+
+// load returns the value of key, found false when the fetch had none.
+func (l *loader[K, V]) load(ctx context.Context, key K) (V, bool, error) {
+	l.mu.Lock()
+	res := l.entry(key)
+	if len(l.queued) > 0 && !l.scheduled {
+		l.scheduled = true
+		time.AfterFunc(batchWindow, l.dispatch)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-res.done:
+		return res.value, res.found, res.err
+	case <-ctx.Done():
+		var zero V
+		return zero, false, ctx.Err()
+	}
+}
+
+// entry returns the result of key, queueing it when new. l.mu must be held.
+func (l *loader[K, V]) entry(key K) *result[V] {
+	res, ok := l.results[key]
+	if !ok {
+		res = &result[V]{done: make(chan struct{})}
+		l.results[key] = res
+		l.queued = append(l.queued, key)
+	}
+	return res
+}
+
+func (l *loader[K, V]) dispatch() {
+	l.mu.Lock()
+	keys := l.queued
+	l.queued = nil
+	l.scheduled = false
+	pending := make([]*result[V], len(keys))
+	for i, key := range keys {
+		pending[i] = l.results[key]
+	}
+	l.mu.Unlock()
+
+	for start := 0; start < len(keys); start += maxBatch {
+		end := min(start+maxBatch, len(keys))
+		values, err := l.fetch(keys[start:end])
+		for i := start; i < end; i++ {
+			res := pending[i]
+			res.value, res.found = values[keys[i]]
+			res.err = err
+			close(res.done)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// fetches records the batches a loader fetched. Odd keys are not found.
+type fetches struct {
+	mu      sync.Mutex
+	batches [][]int
+	err     error
+}
+
+func (f *fetches) fetch(keys []int) (map[int]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, slices.Clone(keys))
+	values := map[int]string{}
+	for _, key := range keys {
+		if key%2 == 0 {
+			values[key] = "v" + strconv.Itoa(key)
+		}
+	}
+	return values, f.err
+}
+
+// loadAll loads every key at once, as the executor resolves list items.
+func loadAll(l *loader[int, string], keys []int) ([]string, []bool, []error) {
+	values, found, errs := make([]string, len(keys)), make([]bool, len(keys)), make([]error, len(keys))
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i, key := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			values[i], found[i], errs[i] = l.load(context.Background(), key)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return values, found, errs
+}
+
+func TestLoaderBatching(t *testing.T) {
+	many := make([]int, 2*maxBatch+1)
+	for i := range many {
+		many[i] = 2 * i
+	}
+
+	cases := []struct {
+		name    string
+		keys    []int
+		queued  bool  // Queued by the list resolver before loading
+		batches []int // Size of every fetch
+	}{
+		{"one key", []int{2}, false, []int{1}},
+		{"siblings batched", []int{2, 4, 6, 8}, false, []int{4}},
+		{"duplicates fetched once", []int{2, 2, 4, 4, 2}, false, []int{2}},
+		{"split at maxBatch", many, true, []int{maxBatch, maxBatch, 1}},
+	}
+
+	for _, c := range cases {
+		f := &fetches{}
+		l := newLoader(f.fetch)
+		if c.queued {
+			l.queue(c.keys...)
+		}
+		_, found, errs := loadAll(l, c.keys)
+		for i := range c.keys {
+			if errs[i] != nil || !found[i] {
+				t.Fatalf("%s: key %d found %v, error %v", c.name, c.keys[i], found[i], errs[i])
+			}
+		}
+
+		var sizes []int
+		for _, batch := range f.batches {
+			sizes = append(sizes, len(batch))
+		}
+		slices.Sort(sizes)
+		slices.Reverse(sizes)
+		if !slices.Equal(sizes, c.batches) {
+			t.Errorf("%s: fetched batches of %v keys, want %v", c.name, sizes, c.batches)
+		}
+
+		// Every key is cached for the rest of the query
+		loadAll(l, c.keys)
+		if len(f.batches) != len(c.batches) {
+			t.Errorf("%s: loading again fetched %d more batches", c.name, len(f.batches)-len(c.batches))
+		}
+	}
+}
+
+func TestLoaderQueueAndPrime(t *testing.T) {
+	f := &fetches{}
+	l := newLoader(f.fetch)
+	l.prime(2, "primed")
+	l.queue(4, 6, 2)
+
+	value, found, err := l.load(context.Background(), 8)
+	if err != nil || !found || value != "v8" {
+		t.Fatalf("load(8) = %q, %v, %v", value, found, err)
+	}
+	if len(f.batches) != 1 || !slices.Equal(f.batches[0], []int{4, 6, 8}) {
+		t.Fatalf("fetched %v, want the queued keys with the loaded one in a single batch", f.batches)
+	}
+
+	// Queued and primed keys need no further fetch
+	for key, want := range map[int]string{2: "primed", 4: "v4", 6: "v6"} {
+		if value, _, _ := l.load(context.Background(), key); value != want {
+			t.Errorf("load(%d) = %q, want %q", key, value, want)
+		}
+	}
+	if len(f.batches) != 1 {
+		t.Errorf("%d fetches, want 1", len(f.batches))
+	}
+}
+
+func TestLoaderMissingAndErrors(t *testing.T) {
+	f := &fetches{}
+	l := newLoader(f.fetch)
+	if _, found, err := l.load(context.Background(), 3); found || err != nil {
+		t.Errorf("load of a missing key: found %v, error %v", found, err)
+	}
+
+	failure := errors.New("database is locked")
+	f = &fetches{err: failure}
+	l = newLoader(f.fetch)
+	_, _, errs := loadAll(l, []int{2, 4})
+	for i, err := range errs {
+		if !errors.Is(err, failure) {
+			t.Errorf("key %d: error %v, want the fetch error", i, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l = newLoader(func([]int) (map[int]string, error) { select {} })
+	if _, _, err := l.load(ctx, 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("load with a cancelled context: %v", err)
+	}
+}
+
+func TestNodeBudget(t *testing.T) {
+	ctx := withLoaders(context.Background())
+	if err := spend(ctx, maxNodes-1); err != nil {
+		t.Fatalf("spend below the budget: %v", err)
+	}
+	if err := spend(ctx, 1); err != nil {
+		t.Fatalf("spend up to the budget: %v", err)
+	}
+	if err := spend(ctx, 1); err == nil {
+		t.Error("spend beyond the budget succeeded")
+	}
+
+	// Each query has its own budget
+	if err := spend(withLoaders(context.Background()), defaultPageSize); err != nil {
+		t.Errorf("spend in a new query: %v", err)
+	}
+}
@@ -0,0 +1,484 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"teamacedia/minestalker/internal/db"
+	"teamacedia/minestalker/internal/models"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// Connections return 50 nodes unless asked for up to 500.
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// maxNodes bounds the nodes a query may return across all its lists. Nested
+// connections multiply, a page of 500 servers each with a page of 500
+// sessions would be 250000 sightings.
+const maxNodes = 50000
+
+// maxSessionsWindow bounds the window of Server.sessions.
+const maxSessionsWindow = 31 * 24 * time.Hour
+
+type queryResolver struct{}
+
+func (*queryResolver) Player(args struct{ Name string }) *playerResolver {
+	return &playerResolver{name: args.Name}
+}
+
+func (*queryResolver) Server(ctx context.Context, args struct {
+	Address string
+	Port    int32
+}) (*serverResolver, error) {
+	key := db.ServerKey{Address: args.Address, Port: int(args.Port)}
+	server, found, err := loadersFrom(ctx).servers.load(ctx, key)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &serverResolver{server}, nil
+}
+
+func (*queryResolver) Servers(ctx context.Context, args struct {
+	Online *bool
+	Game   *string
+	First  *int32
+	After  *string
+}) (*serverConnectionResolver, error) {
+	limit, offset, err := pageArgs(ctx, args.First, args.After)
+	if err != nil {
+		return nil, err
+	}
+	filter := db.ServerFilter{Online: args.Online, Limit: limit, Offset: offset}
+	if args.Game != nil {
+		filter.Game = *args.Game
+	}
+	servers, total, err := db.ListServers(filter)
+	if err != nil {
+		return nil, err
+	}
+	return &serverConnectionResolver{
+		nodes: serverResolvers(ctx, servers),
+		page:  newPageInfo(offset, len(servers), total),
+		total: total,
+	}, nil
+}
+
+func (*queryResolver) Snapshot(ctx context.Context, args struct{ At *graphql.Time }) (*snapshotResolver, error) {
+	var snapshot models.Snapshot
+	var err error
+	if args.At != nil {
+		snapshot, err = db.GetSnapshotByTime(args.At.Time)
+	} else {
+		snapshot, err = db.GetLatestSnapshot()
+	}
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Queue everything the servers and players of the snapshot may need, a
+	// snapshot lists more players than the executor resolves at once
+	l := loadersFrom(ctx)
+	for _, server := range snapshot.Servers {
+		l.servers.queue(db.ServerKey{Address: server.Address, Port: server.Port})
+		for _, player := range server.PlayerList {
+			l.sessions.queue(strings.ToLower(player))
+		}
+	}
+	return &snapshotResolver{snapshot}, nil
+}
+
+func (*queryResolver) SnapshotTimes(args struct{ From, To graphql.Time }) ([]graphql.Time, error) {
+	times, err := db.GetSnapshotTimes(args.From.Time, args.To.Time)
+	if err != nil {
+		return nil, err
+	}
+	return wrapTimes(times), nil
+}
+
+func (*queryResolver) Games(ctx context.Context) ([]*gameResolver, error) {
+	counts, err := db.GetGameCounts()
+	if err != nil {
+		return nil, err
+	}
+	if err := spend(ctx, len(counts)); err != nil {
+		return nil, err
+	}
+	games := make([]*gameResolver, len(counts))
+	for i, c := range counts {
+		games[i] = &gameResolver{c}
+	}
+	return games, nil
+}
+
+func (*queryResolver) Game(args struct{ ID string }) (*gameResolver, error) {
+	counts, err := db.GetGameCounts()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range counts {
+		if strings.EqualFold(c.Game, args.ID) {
+			return &gameResolver{c}, nil
+		}
+	}
+	return nil, nil
+}
+
+type playerResolver struct {
+	name string
+}
+
+func (p *playerResolver) Name() string {
+	return p.name
+}
+
+func (p *playerResolver) Online(ctx context.Context) (bool, error) {
+	_, found, err := loadersFrom(ctx).sessions.load(ctx, strings.ToLower(p.name))
+	return found, err
+}
+
+func (p *playerResolver) CurrentSession(ctx context.Context) (*sightingResolver, error) {
+	session, found, err := loadersFrom(ctx).sessions.load(ctx, strings.ToLower(p.name))
+	if err != nil || !found {
+		return nil, err
+	}
+	return &sightingResolver{session}, nil
+}
+
+func (p *playerResolver) Sightings(ctx context.Context, args struct {
+	First *int32
+	After *string
+}) (*sightingConnectionResolver, error) {
+	limit, offset, err := pageArgs(ctx, args.First, args.After)
+	if err != nil {
+		return nil, err
+	}
+	page, _, err := loadersFrom(ctx).sightings.load(ctx, sightingsKey{strings.ToLower(p.name), limit, offset})
+	if err != nil {
+		return nil, err
+	}
+	return newSightingConnection(ctx, page, offset), nil
+}
+
+// playerResolvers resolves players by name, queueing their sessions.
+func playerResolvers(ctx context.Context, names []string) []*playerResolver {
+	sessions := loadersFrom(ctx).sessions
+	players := make([]*playerResolver, len(names))
+	for i, name := range names {
+		sessions.queue(strings.ToLower(name))
+		players[i] = &playerResolver{name: name}
+	}
+	return players
+}
+
+type sightingResolver struct {
+	sighting models.PlayerSighting
+}
+
+func (s *sightingResolver) Player() *playerResolver {
+	return &playerResolver{name: s.sighting.Player}
+}
+
+func (s *sightingResolver) Server(ctx context.Context) (*serverResolver, error) {
+	key := db.ServerKey{Address: s.sighting.Address, Port: s.sighting.Port}
+	server, found, err := loadersFrom(ctx).servers.load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		// Every sighting has a server, this only covers a race with erasure
+		server = models.ServerStatus{Address: key.Address, Port: key.Port, Name: s.sighting.ServerName, Game: s.sighting.Game}
+	}
+	return &serverResolver{server}, nil
+}
+
+func (s *sightingResolver) ConnectedAt() graphql.Time {
+	return graphql.Time{Time: s.sighting.ConnectedAt}
+}
+
+func (s *sightingResolver) DisconnectedAt() *graphql.Time {
+	return wrapOptionalTime(s.sighting.DisconnectedAt)
+}
+
+// sightingResolvers resolves sightings, queueing their servers.
+func sightingResolvers(ctx context.Context, sightings []models.PlayerSighting) []*sightingResolver {
+	servers := loadersFrom(ctx).servers
+	resolvers := make([]*sightingResolver, len(sightings))
+	for i, sighting := range sightings {
+		servers.queue(db.ServerKey{Address: sighting.Address, Port: sighting.Port})
+		resolvers[i] = &sightingResolver{sighting}
+	}
+	return resolvers
+}
+
+type serverResolver struct {
+	server models.ServerStatus
+}
+
+func (s *serverResolver) Address() string         { return s.server.Address }
+func (s *serverResolver) Port() int32             { return int32(s.server.Port) }
+func (s *serverResolver) Name() string            { return s.server.Name }
+func (s *serverResolver) Game() string            { return s.server.Game }
+func (s *serverResolver) Online() bool            { return s.server.Online }
+func (s *serverResolver) Players() int32          { return int32(s.server.Players) }
+func (s *serverResolver) FirstSeen() graphql.Time { return graphql.Time{Time: s.server.FirstSeen} }
+func (s *serverResolver) LastSeen() graphql.Time  { return graphql.Time{Time: s.server.LastSeen} }
+
+func (s *serverResolver) OnlineSince() *graphql.Time {
+	return wrapOptionalTime(s.server.OnlineSince)
+}
+
+func (s *serverResolver) PlayersOnline(ctx context.Context) ([]*sightingResolver, error) {
+	key := db.ServerKey{Address: s.server.Address, Port: s.server.Port}
+	roster, _, err := loadersFrom(ctx).rosters.load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := spend(ctx, len(roster)); err != nil {
+		return nil, err
+	}
+	// A player's session in progress is the one on this roster
+	sessions := loadersFrom(ctx).sessions
+	resolvers := make([]*sightingResolver, len(roster))
+	for i, sighting := range roster {
+		sessions.prime(strings.ToLower(sighting.Player), sighting)
+		resolvers[i] = &sightingResolver{sighting}
+	}
+	return resolvers, nil
+}
+
+func (s *serverResolver) Sessions(ctx context.Context, args struct {
+	From, To graphql.Time
+	First    *int32
+	After    *string
+}) (*sightingConnectionResolver, error) {
+	if !args.From.Before(args.To.Time) {
+		return nil, fmt.Errorf("from must be before to")
+	}
+	if args.To.Sub(args.From.Time) > maxSessionsWindow {
+		return nil, fmt.Errorf("from and to must be at most %d days apart", maxSessionsWindow/(24*time.Hour))
+	}
+	limit, offset, err := pageArgs(ctx, args.First, args.After)
+	if err != nil {
+		return nil, err
+	}
+	key := serverSessionsKey{
+		server: db.ServerKey{Address: s.server.Address, Port: s.server.Port},
+		from:   args.From.UTC(),
+		to:     args.To.UTC(),
+		limit:  limit,
+		offset: offset,
+	}
+	page, _, err := loadersFrom(ctx).serverSessions.load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return newSightingConnection(ctx, page, offset), nil
+}
+
+// serverResolvers resolves servers already loaded, priming the loader with
+// them and queueing their rosters.
+func serverResolvers(ctx context.Context, servers []models.ServerStatus) []*serverResolver {
+	l := loadersFrom(ctx)
+	resolvers := make([]*serverResolver, len(servers))
+	for i, server := range servers {
+		key := db.ServerKey{Address: server.Address, Port: server.Port}
+		l.servers.prime(key, server)
+		if server.Online {
+			l.rosters.queue(key)
+		}
+		resolvers[i] = &serverResolver{server}
+	}
+	return resolvers
+}
+
+type snapshotResolver struct {
+	snapshot models.Snapshot
+}
+
+func (s *snapshotResolver) Time() graphql.Time {
+	return graphql.Time{Time: s.snapshot.Time}
+}
+
+func (s *snapshotResolver) Servers(ctx context.Context) ([]*snapshotServerResolver, error) {
+	if err := spend(ctx, len(s.snapshot.Servers)); err != nil {
+		return nil, err
+	}
+	servers := make([]*snapshotServerResolver, len(s.snapshot.Servers))
+	for i, server := range s.snapshot.Servers {
+		servers[i] = &snapshotServerResolver{server}
+	}
+	return servers, nil
+}
+
+type snapshotServerResolver struct {
+	server models.Server
+}
+
+func (s *snapshotServerResolver) Server(ctx context.Context) (*serverResolver, error) {
+	key := db.ServerKey{Address: s.server.Address, Port: s.server.Port}
+	server, found, err := loadersFrom(ctx).servers.load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		server = models.ServerStatus{Address: key.Address, Port: key.Port, Name: s.server.Name, Game: s.server.Game}
+	}
+	return &serverResolver{server}, nil
+}
+
+func (s *snapshotServerResolver) Name() string   { return s.server.Name }
+func (s *snapshotServerResolver) Game() string   { return s.server.Game }
+func (s *snapshotServerResolver) Clients() int32 { return int32(s.server.Clients) }
+
+func (s *snapshotServerResolver) Players(ctx context.Context) ([]*playerResolver, error) {
+	if err := spend(ctx, len(s.server.PlayerList)); err != nil {
+		return nil, err
+	}
+	return playerResolvers(ctx, s.server.PlayerList), nil
+}
+
+type gameResolver struct {
+	counts models.GameCounts
+}
+
+func (g *gameResolver) ID() string          { return g.counts.Game }
+func (g *gameResolver) ServersTotal() int32 { return int32(g.counts.ServersTotal) }
+func (g *gameResolver) PlayersTotal() int32 { return int32(g.counts.PlayersTotal) }
+
+func (g *gameResolver) Servers(ctx context.Context, args struct{ Online *bool }) ([]*serverResolver, error) {
+	all, _, err := loadersFrom(ctx).gameServers.load(ctx, strings.ToLower(g.counts.Game))
+	if err != nil {
+		return nil, err
+	}
+	var servers []models.ServerStatus
+	for _, server := range all {
+		if args.Online == nil || server.Online == *args.Online {
+			servers = append(servers, server)
+		}
+	}
+	if err := spend(ctx, len(servers)); err != nil {
+		return nil, err
+	}
+	return serverResolvers(ctx, servers), nil
+}
+
+type pageInfoResolver struct {
+	hasNext bool
+	end     *string
+}
+
+func (p *pageInfoResolver) HasNextPage() bool  { return p.hasNext }
+func (p *pageInfoResolver) EndCursor() *string { return p.end }
+
+// newPageInfo describes a page of count nodes starting at offset out of total.
+func newPageInfo(offset, count, total int) *pageInfoResolver {
+	page := &pageInfoResolver{hasNext: offset+count < total}
+	if count > 0 {
+		cursor := encodeCursor(offset + count)
+		page.end = &cursor
+	}
+	return page
+}
+
+type serverConnectionResolver struct {
+	nodes []*serverResolver
+	page  *pageInfoResolver
+	total int
+}
+
+func (c *serverConnectionResolver) TotalCount() int32           { return int32(c.total) }
+func (c *serverConnectionResolver) Nodes() []*serverResolver    { return c.nodes }
+func (c *serverConnectionResolver) PageInfo() *pageInfoResolver { return c.page }
+
+func newSightingConnection(ctx context.Context, page db.SightingsPage, offset int) *sightingConnectionResolver {
+	return &sightingConnectionResolver{
+		nodes: sightingResolvers(ctx, page.Sightings),
+		page:  newPageInfo(offset, len(page.Sightings), page.Total),
+		total: page.Total,
+	}
+}
+
+type sightingConnectionResolver struct {
+	nodes []*sightingResolver
+	page  *pageInfoResolver
+	total int
+}
+
+func (c *sightingConnectionResolver) TotalCount() int32           { return int32(c.total) }
+func (c *sightingConnectionResolver) Nodes() []*sightingResolver  { return c.nodes }
+func (c *sightingConnectionResolver) PageInfo() *pageInfoResolver { return c.page }
+
+// Cursors are opaque to clients but are offsets into the ordered list. An
+// endCursor points past the last node, so it is the offset of the next page.
+func encodeCursor(offset int) string {
+	return base64.StdEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	n, ok := strings.CutPrefix(string(raw), "offset:")
+	offset, err := strconv.Atoi(n)
+	if !ok || err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	return offset, nil
+}
+
+// pageArgs reads the first and after arguments of a connection and spends
+// the page size, before the page is loaded.
+func pageArgs(ctx context.Context, first *int32, after *string) (limit, offset int, err error) {
+	limit = defaultPageSize
+	if first != nil {
+		if *first < 1 || *first > maxPageSize {
+			return 0, 0, fmt.Errorf("first must be between 1 and %d", maxPageSize)
+		}
+		limit = int(*first)
+	}
+	if after != nil {
+		if offset, err = decodeCursor(*after); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := spend(ctx, limit); err != nil {
+		return 0, 0, err
+	}
+	return limit, offset, nil
+}
+
+// spend counts n more nodes returned by the query in ctx and fails once it
+// returned more than maxNodes.
+func spend(ctx context.Context, n int) error {
+	if loadersFrom(ctx).nodes.Add(int64(n)) > maxNodes {
+		return fmt.Errorf("query returns more than %d nodes, ask for smaller pages", maxNodes)
+	}
+	return nil
+}
+
+func wrapOptionalTime(t *time.Time) *graphql.Time {
+	if t == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *t}
+}
+
+func wrapTimes(times []time.Time) []graphql.Time {
+	wrapped := make([]graphql.Time, len(times))
+	for i, t := range times {
+		wrapped[i] = graphql.Time{Time: t}
+	}
+	return wrapped
+}
@@ -0,0 +1,326 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// RecordError is a validation failure on one input record. Line is the 1-based
+// line number in the input file.
+type RecordError struct {
+	Line    int
+	Message string
+}
+
+func (e RecordError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// sightingRecord is the JSONL form of a player sighting. The CSV form uses the
+// same names as header columns.
+type sightingRecord struct {
+	Player         string `json:"player"`
+	Address        string `json:"address"`
+	Port           int    `json:"port"`
+	ServerName     string `json:"server_name"`
+	Game           string `json:"game"`
+	ConnectedAt    string `json:"connected_at"`
+	DisconnectedAt string `json:"disconnected_at"`
+}
+
+// snapshotRecord is the JSONL form of a snapshot, one per line. Servers use
+// the servers.minetest.net list format so raw list dumps can be imported as is.
+type snapshotRecord struct {
+	Time    string          `json:"time"`
+	Servers []models.Server `json:"servers"`
+}
+
+// ReadSightings parses player sightings from CSV or JSONL. Records that fail
+// validation are returned as RecordErrors and left out of the result; an
+// error is only returned when the input itself cannot be read.
+func ReadSightings(r io.Reader, format string) ([]models.ImportedSighting, []RecordError, error) {
+	var sightings []models.ImportedSighting
+	var invalid []RecordError
+
+	add := func(line int, rec sightingRecord) {
+		sighting, err := validateSighting(rec)
+		if err != nil {
+			invalid = append(invalid, RecordError{Line: line, Message: err.Error()})
+			return
+		}
+		sightings = append(sightings, sighting)
+	}
+
+	switch format {
+	case "csv":
+		err := readCSV(r, []string{"player", "address", "port", "connected_at", "disconnected_at"}, func(line int, row map[string]string) {
+			port, err := strconv.Atoi(row["port"])
+			if err != nil {
+				invalid = append(invalid, RecordError{Line: line, Message: "invalid port " + strconv.Quote(row["port"])})
+				return
+			}
+			add(line, sightingRecord{
+				Player:         row["player"],
+				Address:        row["address"],
+				Port:           port,
+				ServerName:     row["server_name"],
+				Game:           row["game"],
+				ConnectedAt:    row["connected_at"],
+				DisconnectedAt: row["disconnected_at"],
+			})
+		})
+		return sightings, invalid, err
+
+	case "jsonl":
+		err := readJSONL(r, func(line int, raw []byte) {
+			var rec sightingRecord
+			if err := json.Unmarshal(raw, &rec); err != nil {
+				invalid = append(invalid, RecordError{Line: line, Message: "invalid JSON: " + err.Error()})
+				return
+			}
+			add(line, rec)
+		})
+		return sightings, invalid, err
+	}
+
+	return nil, nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// ReadSnapshots parses snapshots from CSV or JSONL. In CSV every row is one
+// server, rows sharing a time make up one snapshot, and players are separated
+// by semicolons. Validation works as in ReadSightings.
+func ReadSnapshots(r io.Reader, format string) ([]models.Snapshot, []RecordError, error) {
+	var invalid []RecordError
+
+	switch format {
+	case "csv":
+		byTime := map[time.Time]*models.Snapshot{}
+		err := readCSV(r, []string{"time", "address", "port"}, func(line int, row map[string]string) {
+			t, err := ParseTime(row["time"])
+			if err != nil {
+				invalid = append(invalid, RecordError{Line: line, Message: err.Error()})
+				return
+			}
+			server, err := csvServer(row)
+			if err == nil {
+				err = validateServer(server)
+			}
+			if err != nil {
+				invalid = append(invalid, RecordError{Line: line, Message: err.Error()})
+				return
+			}
+
+			if byTime[t] == nil {
+				byTime[t] = &models.Snapshot{Time: t}
+			}
+			byTime[t].Servers = append(byTime[t].Servers, server)
+		})
+
+		snapshots := make([]models.Snapshot, 0, len(byTime))
+		for _, snapshot := range byTime {
+			snapshots = append(snapshots, *snapshot)
+		}
+		sort.Slice(snapshots, func(i, j int) bool {
+			return snapshots[i].Time.Before(snapshots[j].Time)
+		})
+		return snapshots, invalid, err
+
+	case "jsonl":
+		var snapshots []models.Snapshot
+		err := readJSONL(r, func(line int, raw []byte) {
+			var rec snapshotRecord
+			if err := json.Unmarshal(raw, &rec); err != nil {
+				invalid = append(invalid, RecordError{Line: line, Message: "invalid JSON: " + err.Error()})
+				return
+			}
+			t, err := ParseTime(rec.Time)
+			if err != nil {
+				invalid = append(invalid, RecordError{Line: line, Message: err.Error()})
+				return
+			}
+			for _, server := range rec.Servers {
+				if err := validateServer(server); err != nil {
+					invalid = append(invalid, RecordError{Line: line, Message: err.Error()})
+					return
+				}
+			}
+			snapshots = append(snapshots, models.Snapshot{Time: t, Servers: rec.Servers})
+		})
+		return snapshots, invalid, err
+	}
+
+	return nil, nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// timeLayouts are the timestamp forms accepted in imported files, tried in order.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+}
+
+// ParseTime parses an imported timestamp: RFC 3339, "YYYY-MM-DD HH:MM:SS" with
+// optional fraction and offset, or Unix seconds. Times without an offset are UTC.
+func ParseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, errors.New("missing timestamp")
+	}
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+}
+
+func validateSighting(rec sightingRecord) (models.ImportedSighting, error) {
+	sighting := models.ImportedSighting{
+		Player:     strings.TrimSpace(rec.Player),
+		Address:    strings.TrimSpace(rec.Address),
+		Port:       rec.Port,
+		ServerName: rec.ServerName,
+		Game:       rec.Game,
+	}
+
+	if sighting.Player == "" {
+		return sighting, errors.New("missing player")
+	}
+	if err := validateServer(models.Server{Address: sighting.Address, Port: sighting.Port}); err != nil {
+		return sighting, err
+	}
+
+	var err error
+	if sighting.ConnectedAt, err = ParseTime(rec.ConnectedAt); err != nil {
+		return sighting, fmt.Errorf("connected_at: %w", err)
+	}
+	if sighting.DisconnectedAt, err = ParseTime(rec.DisconnectedAt); err != nil {
+		return sighting, fmt.Errorf("disconnected_at: %w", err)
+	}
+	if sighting.DisconnectedAt.Before(sighting.ConnectedAt) {
+		return sighting, errors.New("disconnected_at is before connected_at")
+	}
+	if sighting.ConnectedAt.After(time.Now()) {
+		return sighting, errors.New("connected_at is in the future")
+	}
+	if sighting.DisconnectedAt.After(time.Now()) {
+		return sighting, errors.New("disconnected_at is in the future")
+	}
+
+	return sighting, nil
+}
+
+func validateServer(server models.Server) error {
+	if strings.TrimSpace(server.Address) == "" {
+		return errors.New("missing address")
+	}
+	if server.Port < 1 || server.Port > 65535 {
+		return fmt.Errorf("port %d out of range", server.Port)
+	}
+	if server.Clients < 0 {
+		return fmt.Errorf("negative client count %d", server.Clients)
+	}
+	return nil
+}
+
+func csvServer(row map[string]string) (models.Server, error) {
+	server := models.Server{
+		Address: strings.TrimSpace(row["address"]),
+		Name:    row["name"],
+		Game:    row["game"],
+	}
+
+	var err error
+	if server.Port, err = strconv.Atoi(row["port"]); err != nil {
+		return server, fmt.Errorf("invalid port %q", row["port"])
+	}
+	for _, player := range strings.Split(row["players"], ";") {
+		if player = strings.TrimSpace(player); player != "" {
+			server.PlayerList = append(server.PlayerList, player)
+		}
+	}
+	server.Clients = len(server.PlayerList)
+	if clients := strings.TrimSpace(row["clients"]); clients != "" {
+		if server.Clients, err = strconv.Atoi(clients); err != nil {
+			return server, fmt.Errorf("invalid clients %q", clients)
+		}
+	}
+	return server, nil
+}
+
+// readCSV calls fn for every data row keyed by the lowercased header names.
+// The header must contain all required columns, extra columns are ignored.
+func readCSV(r io.Reader, required []string, fn func(line int, row map[string]string)) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+	for _, column := range required {
+		found := false
+		for _, h := range header {
+			found = found || h == column
+		}
+		if !found {
+			return fmt.Errorf("CSV header is missing column %q", column)
+		}
+	}
+
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		row := make(map[string]string, len(header))
+		for i, h := range header {
+			if i < len(fields) {
+				row[h] = fields[i]
+			}
+		}
+		fn(line, row)
+	}
+}
+
+// readJSONL calls fn for every non-empty line.
+func readJSONL(r io.Reader, fn func(line int, raw []byte)) error {
+	scanner := bufio.NewScanner(r)
+	// Snapshot lines hold a full server list and easily exceed the default 64KB
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := scanner.Bytes()
+		if len(strings.TrimSpace(string(raw))) == 0 {
+			continue
+		}
+		fn(line, raw)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read JSONL: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	want := time.Date(2025, 1, 2, 10, 4, 5, 0, time.UTC)
+	cases := []struct {
+		in   string
+		want time.Time
+		ok   bool
+	}{
+		{"2025-01-02T10:04:05Z", want, true},
+		{"2025-01-02T11:04:05+01:00", want, true},
+		{"2025-01-02 10:04:05", want, true},
+		{"2025-01-02 10:04:05.250", want.Add(250 * time.Millisecond), true},
+		{"2025-01-02T10:04:05", want, true},
+		{"1735812245", want, true},
+		{" 1735812245 ", want, true},
+		{"", time.Time{}, false},
+		{"02/01/2025", time.Time{}, false},
+	}
+
+	for _, c := range cases {
+		got, err := ParseTime(c.in)
+		if (err == nil) != c.ok {
+			t.Errorf("ParseTime(%q) error %v, want ok %v", c.in, err, c.ok)
+			continue
+		}
+		if !got.Equal(c.want) || got.Location() != time.UTC {
+			t.Errorf("ParseTime(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestReadSightingsValidation(t *testing.T) {
+	input := strings.Join([]string{
+		"player,address,port,connected_at,disconnected_at",
+		"alice,example.org,30000,2025-01-02 10:00:00,2025-01-02 11:00:00",
+		",example.org,30000,2025-01-02 10:00:00,2025-01-02 11:00:00",
+		"bob,,30000,2025-01-02 10:00:00,2025-01-02 11:00:00",
+		"bob,example.org,x,2025-01-02 10:00:00,2025-01-02 11:00:00",
+		"bob,example.org,70000,2025-01-02 10:00:00,2025-01-02 11:00:00",
+		"bob,example.org,30000,2025-01-02 11:00:00,2025-01-02 10:00:00",
+		"bob,example.org,30000,yesterday,2025-01-02 10:00:00",
+		"bob,example.org,30000,2999-01-01 00:00:00,2999-01-01 01:00:00",
+	}, "\n")
+
+	sightings, invalid, err := ReadSightings(strings.NewReader(input), "csv")
+	if err != nil {
+		t.Fatalf("ReadSightings: %v", err)
+	}
+	if len(sightings) != 1 || sightings[0].Player != "alice" {
+		t.Errorf("valid sightings %+v, want alice's only", sightings)
+	}
+
+	wantLines := []int{3, 4, 5, 6, 7, 8, 9}
+	if len(invalid) != len(wantLines) {
+		t.Fatalf("invalid records %v, want lines %v", invalid, wantLines)
+	}
+	for i, e := range invalid {
+		if e.Line != wantLines[i] {
+			t.Errorf("invalid record %d on line %d, want %d (%s)", i, e.Line, wantLines[i], e.Message)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Package metrics keeps counters, gauges and histograms of the running service
+// and writes them in the Prometheus text exposition format. Every metric is
+// declared below so the whole set can be read in one place.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bucket upper bounds in seconds.
+var (
+	requestBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	writeBuckets   = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+	scrapeBuckets  = []float64{0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+)
+
+var (
+	ScrapeDuration = NewHistogram("minestalker_scrape_duration_seconds",
+		"Time to fetch, track and store one scrape of the server list.", scrapeBuckets)
+	Scrapes = NewCounter("minestalker_scrapes_total",
+		"Scrapes of the server list by outcome.", "outcome")
+	ServersOnline = NewGauge("minestalker_servers_online",
+		"Servers on the server list at the last scrape.")
+	PlayersOnline = NewGauge("minestalker_players_online",
+		"Players on the servers of the server list at the last scrape.")
+	Events = NewCounter("minestalker_events_total",
+		"Tracking events emitted by type.", "type")
+	DBWriteDuration = NewHistogram("minestalker_db_write_duration_seconds",
+		"Time from queueing a database write to its completion.", writeBuckets)
+	DiscordDMs = NewCounter("minestalker_discord_dms_total",
+		"Discord direct messages sent by outcome.", "outcome")
+	WebhookSends = NewCounter("minestalker_webhook_sends_total",
+		"Webhook delivery attempts by outcome.", "outcome")
+	HTTPRequests = NewCounter("minestalker_http_requests_total",
+		"HTTP requests by method, route and status.", "method", "route", "status")
+	HTTPRequestDuration = NewHistogram("minestalker_http_request_duration_seconds",
+		"Time to serve an HTTP request by method and route. Streams count until they close.", requestBuckets, "method", "route")
+)
+
+// Outcome labels of Scrapes, DiscordDMs and WebhookSends.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Outcome returns OutcomeSuccess for a nil error and OutcomeFailure otherwise.
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeFailure
+	}
+	return OutcomeSuccess
+}
+
+// Both outcomes are reported from the start so rates over them are defined.
+func init() {
+	for _, c := range []*Counter{Scrapes, DiscordDMs, WebhookSends} {
+		c.Add(0, OutcomeSuccess)
+		c.Add(0, OutcomeFailure)
+	}
+}
+
+var registry []*metric
+
+// metric is a family of series sharing a name and label names.
+type metric struct {
+	name    string
+	help    string
+	kind    string // "counter", "gauge" or "histogram"
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64  // Counters and gauges
+	counts      []uint64 // Histograms, per bucket and not cumulative
+	count       uint64
+	sum         float64
+}
+
+func register(m *metric) *metric {
+	m.series = make(map[string]*series)
+	registry = append(registry, m)
+	return m
+}
+
+// get returns the series with the given label values. m.mu must be held.
+func (m *metric) get(labelValues []string) *series {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", m.name, len(m.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if m.kind == "histogram" {
+			s.counts = make([]uint64, len(m.buckets))
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+// Counter is a value that only goes up.
+type Counter struct{ m *metric }
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{register(&metric{name: name, help: help, kind: "counter", labels: labels})}
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+	c.m.get(labelValues).value += v
+}
+
+// Gauge is a value that is set to the current state.
+type Gauge struct{ m *metric }
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{register(&metric{name: name, help: help, kind: "gauge", labels: labels})}
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.m.mu.Lock()
+	defer g.m.mu.Unlock()
+	g.m.get(labelValues).value = v
+}
+
+// Histogram counts observations into buckets by upper bound.
+type Histogram struct{ m *metric }
+
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{register(&metric{name: name, help: help, kind: "histogram", labels: labels, buckets: buckets})}
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	s := h.m.get(labelValues)
+	if i := sort.SearchFloat64s(h.m.buckets, v); i < len(s.counts) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+// Since observes the seconds elapsed since start.
+func (h *Histogram) Since(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// WriteText writes every metric in the Prometheus text exposition format,
+// series ordered by label values so the output is stable.
+func WriteText(w io.Writer) error {
+	var b strings.Builder
+	for _, m := range registry {
+		m.writeText(&b)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (m *metric) writeText(b *strings.Builder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", m.name, escapeHelp(m.help), m.name, m.kind)
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	// Series without labels exist from the start, a scrape should not have
+	// to wait for the first observation to see them
+	if len(m.labels) == 0 && len(keys) == 0 {
+		m.get(nil)
+		keys = []string{""}
+	}
+
+	for _, key := range keys {
+		s := m.series[key]
+		if m.kind != "histogram" {
+			fmt.Fprintf(b, "%s%s %s\n", m.name, m.labelText(s.labelValues, ""), formatValue(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, bound := range m.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(b, "%s_bucket%s %d\n", m.name, m.labelText(s.labelValues, formatValue(bound)), cumulative)
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", m.name, m.labelText(s.labelValues, "+Inf"), s.count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", m.name, m.labelText(s.labelValues, ""), formatValue(s.sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", m.name, m.labelText(s.labelValues, ""), s.count)
+	}
+}
+
+// labelText renders the labels of a series, with the le label of a histogram
+// bucket when le is not empty.
+func (m *metric) labelText(values []string, le string) string {
+	var pairs []string
+	for i, name := range m.labels {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHistogramText(t *testing.T) {
+	cases := []struct {
+		name         string
+		labels       []string
+		observations []float64
+		labelValues  []string
+		want         string
+	}{
+		{"no observations", nil, nil, nil, `# HELP test_seconds Test.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.5"} 0
+test_seconds_bucket{le="1"} 0
+test_seconds_bucket{le="2.5"} 0
+test_seconds_bucket{le="+Inf"} 0
+test_seconds_sum 0
+test_seconds_count 0
+`},
+		// Bounds are inclusive, larger values only count towards +Inf
+		{"cumulative buckets", nil, []float64{0.1, 0.5, 0.75, 2.5, 3}, nil, `# HELP test_seconds Test.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.5"} 2
+test_seconds_bucket{le="1"} 3
+test_seconds_bucket{le="2.5"} 4
+test_seconds_bucket{le="+Inf"} 5
+test_seconds_sum 6.85
+test_seconds_count 5
+`},
+		{"labels before le", []string{"method", "route"}, []float64{0.75}, []string{"GET", `/api/"x"`}, `# HELP test_seconds Test.
+# TYPE test_seconds histogram
+test_seconds_bucket{method="GET",route="/api/\"x\"",le="0.5"} 0
+test_seconds_bucket{method="GET",route="/api/\"x\"",le="1"} 1
+test_seconds_bucket{method="GET",route="/api/\"x\"",le="2.5"} 1
+test_seconds_bucket{method="GET",route="/api/\"x\"",le="+Inf"} 1
+test_seconds_sum{method="GET",route="/api/\"x\""} 0.75
+test_seconds_count{method="GET",route="/api/\"x\""} 1
+`},
+	}
+
+	for _, c := range cases {
+		h := NewHistogram("test_seconds", "Test.", []float64{0.5, 1, 2.5}, c.labels...)
+		for _, v := range c.observations {
+			h.Observe(v, c.labelValues...)
+		}
+		var b strings.Builder
+		h.m.writeText(&b)
+		if got := b.String(); got != c.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCounterText(t *testing.T) {
+	c := NewCounter("test_total", "Test\\counter\nover two lines.", "outcome")
+	c.Inc("success")
+	c.Add(0.5, "success")
+	c.Inc("failure")
+	g := NewGauge("test_online", "Test.")
+	g.Set(3)
+	g.Set(2)
+
+	var b strings.Builder
+	c.m.writeText(&b)
+	g.m.writeText(&b)
+	want := `# HELP test_total Test\\counter\nover two lines.
+# TYPE test_total counter
+test_total{outcome="failure"} 1
+test_total{outcome="success"} 1.5
+# HELP test_online Test.
+# TYPE test_online gauge
+test_online 2
+`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
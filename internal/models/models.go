@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"net/netip"
+	"time"
+)
 
 type Server struct {
 	Address    string   `json:"address"`
@@ -28,6 +31,8 @@ type TrackingEvent struct {
 type PlayerSighting struct {
 	Address        string
 	Port           int
+	ServerName     string
+	Game           string
 	Player         string
 	ConnectedAt    time.Time
 	DisconnectedAt *time.Time // Optional, nil if still connected
@@ -38,11 +43,90 @@ type ServerSighting struct {
 	DisconnectedAt *time.Time // Optional, nil if still connected
 }
 
+// PlayerCount is the number of players a server reported in one snapshot
+type PlayerCount struct {
+	Time    time.Time
+	Players int
+}
+
+// Companion is a player who was online on the same server as another
+type Companion struct {
+	Player       string
+	Overlap      time.Duration // Total time both were online together
+	Sessions     int           // Sessions of the companion that overlapped
+	LastTogether time.Time
+}
+
+// GlobalCounts are network wide totals
+type GlobalCounts struct {
+	ServersOnline         int
+	PlayersOnline         int
+	UniquePlayersToday    int // Since midnight UTC
+	UniquePlayersThisWeek int // Since Monday midnight UTC
+	NewServers            int // First seen in the requested window
+	NewPlayers            int // First seen in the requested window
+}
+
+// LeaderboardEntry is one row of a leaderboard. Player boards set Player,
+// server boards Address, Port and Name.
+type LeaderboardEntry struct {
+	Player  string
+	Address string
+	Port    int
+	Name    string
+	Value   float64
+}
+
+// GameCounts are all-time totals of one gameid
+type GameCounts struct {
+	Game         string
+	ServersTotal int // Servers ever seen running the game
+	PlayersTotal int // Distinct players with a finished session on those servers
+}
+
+// GameDay is the average number of servers and players of a game across the
+// snapshots of one UTC day
+type GameDay struct {
+	Day     time.Time
+	Servers float64
+	Players float64
+}
+
+// Interval is a span of time from Start to End
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// ServerStatus is a known server with its current tracking state
+type ServerStatus struct {
+	Address     string
+	Port        int
+	Name        string
+	Game        string
+	Online      bool
+	Players     int        // Players currently online, 0 when offline
+	OnlineSince *time.Time // Start of the current sighting, nil when offline
+	FirstSeen   time.Time
+	LastSeen    time.Time // Now for online servers
+}
+
 type Snapshot struct {
 	Servers []Server
 	Time    time.Time
 }
 
+// ImportedSighting is one player session loaded from another tracker's export
+type ImportedSighting struct {
+	Player         string
+	Address        string
+	Port           int
+	ServerName     string // Optional
+	Game           string // Optional
+	ConnectedAt    time.Time
+	DisconnectedAt time.Time
+}
+
 type TrackingAlert struct {
 	ID         int
 	PlayerName string
@@ -56,12 +140,81 @@ type ServerTrackingAlert struct {
 	DiscordID     string
 }
 
+type OptOut struct {
+	PlayerName string    `json:"player_name"`
+	Reason     string    `json:"reason"`
+	AddedBy    string    `json:"added_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// PlayerErasure is the audit record left behind when a player's data is erased
+type PlayerErasure struct {
+	ID                      int       `json:"id"`
+	PlayerName              string    `json:"player_name"`
+	RequestedBy             string    `json:"requested_by"`
+	ErasedAt                time.Time `json:"erased_at"`
+	SightingsDeleted        int64     `json:"sightings_deleted"`
+	SnapshotMentionsRemoved int64     `json:"snapshot_mentions_removed"`
+	AlertsDeleted           int64     `json:"alerts_deleted"`
+}
+
+// WebhookSubscription is a URL that tracking events matching its filters are
+// posted to. Empty filters match everything.
+type WebhookSubscription struct {
+	ID                  int
+	URL                 string
+	Secret              string // Key of the HMAC-SHA256 signature of every payload
+	Players             []string
+	Servers             []string // "address" or "address:port"
+	Types               []string
+	Enabled             bool
+	ConsecutiveFailures int    // Deliveries that failed after all retries since the last success
+	DisabledReason      string // Why the subscription was disabled automatically
+	CreatedAt           time.Time
+}
+
+// WebhookDelivery is one attempt at posting an event to a subscription.
+type WebhookDelivery struct {
+	ID             int
+	SubscriptionID int
+	EventID        uint64
+	EventType      string
+	Attempt        int // 1 for the first try
+	StatusCode     int // 0 when no response was received
+	Error          string
+	Duration       time.Duration
+	DeliveredAt    time.Time
+}
+
+// Succeeded reports whether the receiver accepted the delivery.
+func (d WebhookDelivery) Succeeded() bool {
+	return d.Error == "" && d.StatusCode >= 200 && d.StatusCode < 300
+}
+
+// APIKey grants scopes of the HTTP API. Only a hash of the key itself is
+// stored, Prefix identifies it in listings.
+type APIKey struct {
+	ID        int
+	Name      string
+	Prefix    string
+	Scopes    []string
+	RateLimit int // Requests per minute, 0 for the configured default
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
 type Config struct {
 	Token                 string
 	AppID                 string
 	GuildID               string
-	UpdateInterval        int    // Interval in seconds for periodic updates
-	SnapshotInterval      int    // Interval in seconds for snapshot updates
-	LoggerWebhookUrl      string // Webhook URL for logging events
-	LoggerWebhookUsername string // Username to use when logging events via webhook url
+	UpdateInterval        int            // Interval in seconds for periodic updates
+	SnapshotInterval      int            // Interval in seconds for snapshot updates
+	LoggerWebhookUrl      string         // Webhook URL for logging events
+	LoggerWebhookUsername string         // Username to use when logging events via webhook url
+	BusyTimeout           int            // Milliseconds to wait on a locked database before giving up
+	AdminToken            string         // Bearer token with every scope, in addition to API keys
+	AnonymousScopes       []string       // Scopes of requests without an API key
+	AnonymousRateLimit    int            // Requests per minute per IP address without an API key
+	APIKeyRateLimit       int            // Requests per minute of API keys issued without their own limit
+	TrustedProxies        []netip.Prefix // Reverse proxies whose X-Forwarded-For and X-Real-IP headers are believed
 }
@@ -13,6 +13,8 @@ import (
 	"teamacedia/minestalker/internal/api"
 	"teamacedia/minestalker/internal/db"
 	"teamacedia/minestalker/internal/discord"
+	trackerevents "teamacedia/minestalker/internal/events"
+	"teamacedia/minestalker/internal/metrics"
 	"teamacedia/minestalker/internal/models"
 	"teamacedia/minestalker/internal/tracker"
 
@@ -21,13 +23,15 @@ import (
 
 var isFirstScrape = true
 var snapshot_interval_seconds = 300 // 5 minutes default ( configurable via config )
+var update_interval_seconds = 60
 var logger_webhook_url string
 var logger_username string
 
-func StartScheduler(update_interval_seconds int, snapshot_interval_seconds_ int, logger_webhook_url_, logger_username_ string) {
-	ticker := time.NewTicker(time.Duration(update_interval_seconds) * time.Second)
+func StartScheduler(update_interval_seconds_ int, snapshot_interval_seconds_ int, logger_webhook_url_, logger_username_ string) {
+	ticker := time.NewTicker(time.Duration(update_interval_seconds_) * time.Second)
 	defer ticker.Stop()
 
+	update_interval_seconds = update_interval_seconds_
 	snapshot_interval_seconds = snapshot_interval_seconds_
 	logger_webhook_url = logger_webhook_url_
 	logger_username = logger_username_
@@ -60,22 +64,30 @@ func sendEvent(message string) {
 
 func Scrape() {
 	log.Println("Starting scrape of servers.minetest.net list...")
+	start := time.Now()
+	failed := func() {
+		metrics.Scrapes.Inc(metrics.OutcomeFailure)
+		metrics.ScrapeDuration.Since(start)
+	}
 
 	resp, err := http.Get("https://servers.minetest.net/list")
 	if err != nil {
 		log.Printf("Failed to fetch server list: %v", err)
+		failed()
 		return
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		log.Printf("Non-200 response: %d", resp.StatusCode)
+		failed()
 		return
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Printf("Failed to read response body: %v", err)
+		failed()
 		return
 	}
 
@@ -84,10 +96,17 @@ func Scrape() {
 	if err != nil {
 		log.Printf("Failed to parse JSON: %v", err)
 		log.Println(string(body[:min(len(body), 1000)]))
+		failed()
 		return
 	}
 
 	log.Printf("Found %d servers", len(parsed.List))
+	players := 0
+	for _, server := range parsed.List {
+		players += len(server.PlayerList)
+	}
+	metrics.ServersOnline.Set(float64(len(parsed.List)))
+	metrics.PlayersOnline.Set(float64(players))
 	/*for i, s := range parsed.List {
 		log.Printf("[%d] Server: %s — %s — %d players", i+1, s.Address, s.Name, s.Clients)
 		if i >= 4 {
@@ -97,7 +116,13 @@ func Scrape() {
 
 	log.Println("Tracking player/server events...")
 
-	events := tracker.RefreshTracker(parsed, snapshot_interval_seconds)
+	events, err := tracker.RefreshTracker(parsed, snapshot_interval_seconds)
+	if err != nil {
+		// Nothing was tracked, so the time until the next scrape stays unknown
+		log.Printf("Failed to track events: %v", err)
+		failed()
+		return
+	}
 	sortEventsByType(events)
 
 	log.Println("Committing changes to database...")
@@ -107,11 +132,23 @@ func Scrape() {
 	}
 
 	log.Printf("Tracked %d events", len(events))
+
+	// Allow one missed tick before the gap counts as scraper downtime
+	err = db.RecordScrape(time.Now(), 2*time.Duration(update_interval_seconds)*time.Second)
+	if err != nil {
+		log.Printf("Failed to record scrape coverage: %v", err)
+	}
+	metrics.Scrapes.Inc(metrics.OutcomeSuccess)
+	metrics.ScrapeDuration.Since(start)
+
+	// The first scrape rediscovers everything online, none of it is news
 	if isFirstScrape {
-		log.Println("First scrape complete, skipping webhook notifications and discord alerts")
+		log.Println("First scrape complete, skipping live events, webhook notifications and discord alerts")
 		isFirstScrape = false
 		return
 	}
+	trackerevents.Publish(events)
+
 	for _, event := range events {
 		switch event.Type {
 		case "playerJoin":
@@ -3,6 +3,7 @@ package tracker
 import (
 	"fmt"
 	"log"
+	"strings"
 	"teamacedia/minestalker/internal/db"
 	"teamacedia/minestalker/internal/models"
 	"time"
@@ -11,10 +12,20 @@ import (
 var previousState = map[string]map[int]map[string]bool{} // map[serverAddr][serverPort][playerName]bool
 var lastSnapshotSave time.Time
 
-func RefreshTracker(current models.ServerListResponse, snapshot_interval_seconds int) []models.TrackingEvent {
-	now := time.Now()
+// RefreshTracker compares the server list with the previous one and returns
+// the events between them. On error nothing was tracked.
+func RefreshTracker(current models.ServerListResponse, snapshot_interval_seconds int) ([]models.TrackingEvent, error) {
+	now := time.Now().UTC()
 	var events []models.TrackingEvent
 
+	// Opted-out players must never be recorded, so skip the whole refresh
+	// rather than risk tracking them when the list cannot be loaded
+	optedOut, err := db.GetOptedOutNames()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load opt-out list: %w", err)
+	}
+	current = removeOptedOut(current, optedOut)
+
 	// Only save snapshot if specified interval has passed
 	if now.Sub(lastSnapshotSave) > time.Duration(snapshot_interval_seconds)*time.Second {
 		log.Println("Saving serverlist snapshot to DB...")
@@ -25,8 +36,7 @@ func RefreshTracker(current models.ServerListResponse, snapshot_interval_seconds
 
 		err := db.SaveSnapshot(snapshot)
 		if err != nil {
-			fmt.Printf("Error saving snapshot: %v\n", err)
-			return nil
+			return nil, fmt.Errorf("failed to save snapshot: %w", err)
 		}
 
 		lastSnapshotSave = now
@@ -76,6 +86,7 @@ func RefreshTracker(current models.ServerListResponse, snapshot_interval_seconds
 				Server:    server.Address,
 				Port:      server.Port,
 				Timestamp: now,
+				Game:      server.Game,
 				Name:      server.Name,
 			})
 		} else if _, ok := previousState[server.Address][server.Port]; !ok {
@@ -85,6 +96,7 @@ func RefreshTracker(current models.ServerListResponse, snapshot_interval_seconds
 				Server:    server.Address,
 				Port:      server.Port,
 				Timestamp: now,
+				Game:      server.Game,
 				Name:      server.Name,
 			})
 		}
@@ -139,7 +151,7 @@ func RefreshTracker(current models.ServerListResponse, snapshot_interval_seconds
 		}
 	}
 
-	return events
+	return events, nil
 }
 
 func serverInList(addr string, list []models.Server) bool {
@@ -159,3 +171,35 @@ func getPrevPlayers(state map[string]map[int]map[string]bool, addr string, port
 	}
 	return map[string]bool{}
 }
+
+// removeOptedOut returns a copy of the server list without opted-out players,
+// and forgets them in previousState so opting out while online does not
+// produce a playerLeave event either. AddOptOut closes their open sightings.
+func removeOptedOut(list models.ServerListResponse, optedOut map[string]bool) models.ServerListResponse {
+	if len(optedOut) == 0 {
+		return list
+	}
+
+	for _, ports := range previousState {
+		for _, players := range ports {
+			for player := range players {
+				if optedOut[strings.ToLower(player)] {
+					delete(players, player)
+				}
+			}
+		}
+	}
+
+	filtered := models.ServerListResponse{List: make([]models.Server, len(list.List))}
+	for i, server := range list.List {
+		players := make([]string, 0, len(server.PlayerList))
+		for _, player := range server.PlayerList {
+			if !optedOut[strings.ToLower(player)] {
+				players = append(players, player)
+			}
+		}
+		server.PlayerList = players
+		filtered.List[i] = server
+	}
+	return filtered
+}
@@ -0,0 +1,293 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"teamacedia/minestalker/internal/db"
+	"teamacedia/minestalker/internal/events"
+	"teamacedia/minestalker/internal/metrics"
+	"teamacedia/minestalker/internal/models"
+	"time"
+)
+
+// A delivery is tried maxAttempts times, waiting retryDelay before the first
+// retry and twice as long before each further one. A subscription whose
+// deliveries failed disableAfter times in a row is disabled.
+const (
+	maxAttempts    = 5
+	disableAfter   = 10
+	requestTimeout = 10 * time.Second
+)
+
+// retryDelay is a variable so tests need not wait for retries.
+var retryDelay = 10 * time.Second
+
+// queueSize is how many events a subscription may fall behind before new ones
+// are dropped for it.
+const queueSize = 1000
+
+// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the request
+// body keyed with the subscription secret.
+const SignatureHeader = "X-MineStalker-Signature"
+
+type worker struct {
+	sub    models.WebhookSubscription
+	filter events.Filter
+	queue  chan events.Event
+	ctx    context.Context
+	stop   context.CancelFunc
+}
+
+var (
+	mu      sync.Mutex
+	workers = map[int]*worker{}
+	client  = &http.Client{Timeout: requestTimeout}
+)
+
+// Start loads the subscriptions and delivers published events to them until
+// the event bus shuts down.
+func Start() {
+	if err := Reload(); err != nil {
+		log.Printf("Error loading webhooks: %v", err)
+	}
+
+	// A subscription that fell behind is closed by the bus, resume it from
+	// the replay buffer.
+	var lastID uint64
+	for !events.Closed() {
+		sub, missed := events.Subscribe(lastID)
+		for _, event := range missed {
+			dispatch(event)
+			lastID = event.ID
+		}
+		for event := range sub.C {
+			dispatch(event)
+			lastID = event.ID
+		}
+		sub.Close()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for id, w := range workers {
+		w.stop()
+		delete(workers, id)
+	}
+}
+
+// Reload starts delivering to new and re-enabled subscriptions and stops
+// delivering to deleted and disabled ones. Call it after changing them.
+func Reload() error {
+	subs, err := db.GetWebhooks()
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	enabled := map[int]bool{}
+	for _, sub := range subs {
+		if !sub.Enabled {
+			continue
+		}
+		enabled[sub.ID] = true
+		if w, ok := workers[sub.ID]; ok {
+			if sameTarget(w.sub, sub) {
+				continue
+			}
+			w.stop()
+		}
+		workers[sub.ID] = startWorker(sub)
+	}
+
+	for id, w := range workers {
+		if !enabled[id] {
+			w.stop()
+			delete(workers, id)
+		}
+	}
+	return nil
+}
+
+func sameTarget(a, b models.WebhookSubscription) bool {
+	return a.URL == b.URL && a.Secret == b.Secret && slices.Equal(a.Players, b.Players) &&
+		slices.Equal(a.Servers, b.Servers) && slices.Equal(a.Types, b.Types)
+}
+
+func dispatch(event events.Event) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, w := range workers {
+		if !w.filter.Match(event.TrackingEvent) {
+			continue
+		}
+		select {
+		case w.queue <- event:
+		default:
+			log.Printf("Webhook %d is %d events behind, dropping event %d", w.sub.ID, queueSize, event.ID)
+		}
+	}
+}
+
+func startWorker(sub models.WebhookSubscription) *worker {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &worker{
+		sub:    sub,
+		filter: events.Filter{Players: sub.Players, Servers: sub.Servers, Types: sub.Types},
+		queue:  make(chan events.Event, queueSize),
+		ctx:    ctx,
+		stop:   cancel,
+	}
+	go w.run()
+	return w
+}
+
+// run delivers queued events one at a time, so the receiver sees them in
+// order, until the worker is stopped.
+func (w *worker) run() {
+	failures := w.sub.ConsecutiveFailures
+	for {
+		var event events.Event
+		select {
+		case <-w.ctx.Done():
+			return
+		case event = <-w.queue:
+		}
+
+		if w.deliver(event) {
+			if failures == 0 {
+				continue
+			}
+			failures = 0
+		} else {
+			if w.ctx.Err() != nil {
+				return
+			}
+			// Enabling the webhook again resets the stored count while the
+			// worker keeps running, so count on from what is stored
+			if sub, err := db.GetWebhook(w.sub.ID); err == nil {
+				failures = sub.ConsecutiveFailures
+			}
+			failures++
+		}
+
+		var reason string
+		if failures >= disableAfter {
+			reason = fmt.Sprintf("%d deliveries in a row failed", failures)
+		}
+		if err := db.SetWebhookHealth(w.sub.ID, failures, reason); err != nil {
+			log.Printf("Error updating webhook %d: %v", w.sub.ID, err)
+		}
+		if reason != "" {
+			log.Printf("Disabled webhook %d: %s", w.sub.ID, reason)
+			mu.Lock()
+			if workers[w.sub.ID] == w {
+				delete(workers, w.sub.ID)
+			}
+			mu.Unlock()
+			w.stop()
+			return
+		}
+	}
+}
+
+// deliver posts the event, retrying with backoff, and logs every attempt.
+func (w *worker) deliver(event events.Event) bool {
+	body, err := json.Marshal(event.Payload())
+	if err != nil {
+		log.Printf("Error encoding webhook payload: %v", err)
+		return false
+	}
+	signature := sign(w.sub.Secret, body)
+
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		delivery := w.post(body, signature, event)
+		delivery.Attempt = attempt
+		if w.ctx.Err() != nil {
+			return false // Stopped mid-request, not the receiver's fault
+		}
+		if err := db.RecordWebhookDelivery(delivery); err != nil {
+			log.Printf("Error logging webhook delivery: %v", err)
+		}
+		if delivery.Succeeded() {
+			metrics.WebhookSends.Inc(metrics.OutcomeSuccess)
+		} else {
+			metrics.WebhookSends.Inc(metrics.OutcomeFailure)
+		}
+
+		if delivery.Succeeded() {
+			return true
+		}
+		if attempt == maxAttempts || !retryable(delivery.StatusCode) {
+			return false
+		}
+
+		select {
+		case <-w.ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// sign returns the SignatureHeader value of body.
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *worker) post(body []byte, signature string, event events.Event) models.WebhookDelivery {
+	delivery := models.WebhookDelivery{
+		SubscriptionID: w.sub.ID,
+		EventID:        event.ID,
+		EventType:      event.Type,
+		DeliveredAt:    time.Now().UTC(),
+	}
+
+	req, err := http.NewRequestWithContext(w.ctx, http.MethodPost, w.sub.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "MineStalker-Webhook")
+	req.Header.Set("X-MineStalker-Event", event.Type)
+	req.Header.Set("X-MineStalker-Delivery", fmt.Sprint(event.ID))
+	req.Header.Set(SignatureHeader, signature)
+
+	resp, err := client.Do(req)
+	delivery.Duration = time.Since(delivery.DeliveredAt)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	resp.Body.Close()
+
+	delivery.StatusCode = resp.StatusCode
+	if !delivery.Succeeded() {
+		delivery.Error = "unexpected status " + resp.Status
+	}
+	return delivery
+}
+
+// retryable reports whether a failure might go away on its own: network
+// errors, timeouts, rate limiting and server errors. Other client errors mean
+// the receiver rejects the payload and will keep doing so.
+func retryable(status int) bool {
+	return status == 0 || status == http.StatusRequestTimeout ||
+		status == http.StatusTooManyRequests || status >= 500
+}
@@ -0,0 +1,191 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"teamacedia/minestalker/internal/db"
+	"teamacedia/minestalker/internal/events"
+	"teamacedia/minestalker/internal/models"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	cases := []struct {
+		secret, body, want string
+	}{
+		// RFC 4231 test case 2
+		{"Jefe", "what do ya want for nothing?", "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+		{"key", "The quick brown fox jumps over the lazy dog", "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{"", "", "sha256=b613679a0814d9ec772f95d778c35fc5ff1697c493715653c6c712144292c5ad"},
+	}
+
+	for _, c := range cases {
+		if got := sign(c.secret, []byte(c.body)); got != c.want {
+			t.Errorf("sign(%q, %q) = %s, want %s", c.secret, c.body, got, c.want)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	cases := []struct {
+		status int
+		want   bool
+	}{
+		{0, true}, // No response at all
+		{http.StatusBadRequest, false},
+		{http.StatusUnauthorized, false},
+		{http.StatusNotFound, false},
+		{http.StatusRequestTimeout, true},
+		{http.StatusGone, false},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusBadGateway, true},
+		{http.StatusServiceUnavailable, true},
+	}
+
+	for _, c := range cases {
+		if got := retryable(c.status); got != c.want {
+			t.Errorf("retryable(%d) = %v, want %v", c.status, got, c.want)
+		}
+	}
+}
+
+// receiver answers webhook posts with the given statuses in turn, repeating
+// the last one, and remembers the requests.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bodies = append(r.bodies, body)
+	r.headers = append(r.headers, req.Header.Clone())
+	status := r.statuses[min(len(r.bodies), len(r.statuses))-1]
+	w.WriteHeader(status)
+}
+
+func openTestDB(t *testing.T) {
+	t.Helper()
+	if err := db.InitDB(filepath.Join(t.TempDir(), "test.db"), 1000); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	retryDelay = time.Millisecond
+}
+
+func testWorker(t *testing.T, url string, failures int) *worker {
+	t.Helper()
+	sub, err := db.CreateWebhook(models.WebhookSubscription{URL: url, Secret: "secret", Enabled: true})
+	if err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	if err := db.SetWebhookHealth(sub.ID, failures, ""); err != nil {
+		t.Fatal(err)
+	}
+	sub.ConsecutiveFailures = failures
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return &worker{sub: sub, queue: make(chan events.Event, queueSize), ctx: ctx, stop: cancel}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	openTestDB(t)
+	cases := []struct {
+		name     string
+		statuses []int
+		ok       bool
+		attempts int
+	}{
+		{"success", []int{200}, true, 1},
+		{"accepted", []int{202}, true, 1},
+		{"retried until success", []int{500, 503, 200}, true, 3},
+		{"timeouts and rate limits retried", []int{408, 429, 204}, true, 3},
+		{"rejected", []int{400}, false, 1},
+		{"gone after a retry", []int{502, 410}, false, 2},
+		{"out of attempts", []int{500}, false, maxAttempts},
+	}
+
+	for _, c := range cases {
+		rcv := &receiver{statuses: c.statuses}
+		srv := httptest.NewServer(rcv)
+		w := testWorker(t, srv.URL, 0)
+		event := events.Event{ID: 42, TrackingEvent: models.TrackingEvent{Type: "playerJoin", Server: "example.org", Port: 30000, Player: "alice"}}
+
+		ok := w.deliver(event)
+		srv.Close()
+		if ok != c.ok || len(rcv.bodies) != c.attempts {
+			t.Errorf("%s: delivered %v after %d attempts, want %v after %d", c.name, ok, len(rcv.bodies), c.ok, c.attempts)
+		}
+		for i, body := range rcv.bodies {
+			if got, want := rcv.headers[i].Get(SignatureHeader), sign("secret", body); got != want {
+				t.Errorf("%s: attempt %d signed %s, want %s", c.name, i+1, got, want)
+			}
+		}
+		if _, logged, err := db.GetWebhookDeliveries(w.sub.ID, 100, 0); err != nil || logged != c.attempts {
+			t.Errorf("%s: %d attempts logged (%v), want %d", c.name, logged, err, c.attempts)
+		}
+	}
+}
+
+func TestFailuresCountFromStore(t *testing.T) {
+	openTestDB(t)
+	srv := httptest.NewServer(&receiver{statuses: []int{400}})
+	defer srv.Close()
+	w := testWorker(t, srv.URL, disableAfter-2)
+	go w.run()
+
+	// failures waits for the stored count to reach want
+	failures := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			sub, err := db.GetWebhook(w.sub.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sub.ConsecutiveFailures == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%d failures stored, want %d", sub.ConsecutiveFailures, want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	w.queue <- events.Event{ID: 1}
+	failures(disableAfter - 1)
+
+	// As when the subscription is enabled again
+	if err := db.SetWebhookHealth(w.sub.ID, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	w.queue <- events.Event{ID: 2}
+	failures(1)
+	if w.ctx.Err() != nil {
+		t.Fatal("worker stopped after its count was reset")
+	}
+
+	if err := db.SetWebhookHealth(w.sub.ID, disableAfter-1, ""); err != nil {
+		t.Fatal(err)
+	}
+	w.queue <- events.Event{ID: 3}
+	failures(disableAfter)
+	if sub, err := db.GetWebhook(w.sub.ID); err != nil || sub.DisabledReason == "" {
+		t.Errorf("not disabled after %d failures: %+v, %v", disableAfter, sub, err)
+	}
+	select {
+	case <-w.ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Error("worker kept running after disabling the subscription")
+	}
+}
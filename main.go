@@ -13,10 +13,31 @@ import (
 	"teamacedia/minestalker/internal/config"
 	"teamacedia/minestalker/internal/db"
 	"teamacedia/minestalker/internal/discord"
+	"teamacedia/minestalker/internal/events"
 	"teamacedia/minestalker/internal/scraper"
+	"teamacedia/minestalker/internal/webhooks"
 )
 
+const dbPath = "minestalker.db"
+
 func main() {
+	// Subcommands run against the database and exit without starting the service
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "doctor":
+			runDoctor(os.Args[2:])
+			return
+		case "import":
+			runImport(os.Args[2:])
+			return
+		case "apikey":
+			runAPIKey(os.Args[2:])
+			return
+		default:
+			log.Fatalf("Unknown command %q", os.Args[1])
+		}
+	}
+
 	// Load config file
 	cfg, err := config.LoadConfig("config.ini")
 	if err != nil {
@@ -24,7 +45,7 @@ func main() {
 	}
 
 	// Initialize DB
-	err = db.InitDB("minestalker.db")
+	err = db.InitDB(dbPath, cfg.BusyTimeout)
 	if err != nil {
 		log.Fatalf("Failed to initialize DB: %v", err)
 	}
@@ -32,20 +53,22 @@ func main() {
 	// Start scraping job
 	go scraper.StartScheduler(cfg.UpdateInterval, cfg.SnapshotInterval, cfg.LoggerWebhookUrl, cfg.LoggerWebhookUsername)
 
+	// Start delivering events to webhook subscriptions
+	go webhooks.Start()
+
 	// Start the Discord bot
 
 	go discord.Start(cfg.Token, cfg.AppID, cfg.GuildID)
 
 	// Setup HTTP routes
-	mux := http.NewServeMux()
-	mux.HandleFunc("/api/player/", api.PlayerHistoryHandler)
-	mux.HandleFunc("/api/server/", api.ServerHistoryHandler)
-	mux.HandleFunc("/api/snapshot", api.SnapshotHandler)
+	handler := api.NewHandler(cfg)
 
 	srv := &http.Server{
 		Addr:    ":8080",
-		Handler: mux,
+		Handler: handler,
 	}
+	// Event streams never go idle on their own, end them so Shutdown can finish
+	srv.RegisterOnShutdown(events.Shutdown)
 
 	// Channel to listen for OS signals
 	stop := make(chan os.Signal, 1)